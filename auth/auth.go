@@ -33,47 +33,72 @@ func HashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+// Claims are the access token claims; Role is the user's role when the token was
+// issued and DeviceID the registered device it was issued to, if any
+type Claims struct {
+	jwt.RegisteredClaims
+	Role     string `json:"role,omitempty"`
+	DeviceID string `json:"device_id,omitempty"`
+}
+
+func MakeJWT(userID uuid.UUID, role string, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return MakeDeviceJWT(userID, role, uuid.Nil, tokenSecret, expiresIn)
+}
+
+// MakeDeviceJWT issues an access token bound to deviceID, which stops working
+// once the device is revoked
+func MakeDeviceJWT(userID uuid.UUID, role string, deviceID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Role: role,
+	}
+	if deviceID != uuid.Nil {
+		claims.DeviceID = deviceID.String()
+	}
+
 	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(signingKey)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(
+// ParseJWT validates an access token and returns its claims
+func ParseJWT(tokenString, tokenSecret string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(
 		tokenString,
-		&claimsStruct,
+		claims,
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
-	userIDString, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.Nil, err
+	if claims.Issuer != string(TokenTypeAccess) {
+		return nil, errors.New("invalid issuer")
 	}
 
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil {
-		return uuid.Nil, err
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
 	}
-
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+	if claims.DeviceID != "" {
+		if _, err := uuid.Parse(claims.DeviceID); err != nil {
+			return nil, fmt.Errorf("invalid device ID: %w", err)
+		}
 	}
+	return claims, nil
+}
 
-	id, err := uuid.Parse(userIDString)
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return uuid.Nil, err
 	}
-	return id, nil
+	return uuid.MustParse(claims.Subject), nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
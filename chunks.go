@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// The chunk store is enabled by CHUNK_SIZE. Uploads through the API are then
+// split into chunks of that size, stored once each under chunkDir by their
+// SHA-256, so the same ciphertext uploaded twice takes the space of one copy.
+// A chunked file's file_path is chunkedPathPrefix followed by the hash of the
+// whole ciphertext, and its chunks are listed in file_chunks. Gateway uploads
+// still store whole blobs.
+//
+// Chunk rows are locked from the moment an upload reuses them until it
+// commits, and the collector removes a chunk's blob only while holding that
+// lock, so an upload either sees the chunk gone and writes it again or keeps
+// it alive.
+
+const chunkedPathPrefix = "chunks:"
+
+// chunkCollectBatch is how many unreferenced chunks one transaction removes
+const chunkCollectBatch = 100
+
+var chunkDir = filepath.Join(uploadDir, "chunks")
+
+func isChunked(filePath string) bool {
+	return strings.HasPrefix(filePath, chunkedPathPrefix)
+}
+
+// chunkedPath is the file_path recorded for a chunked file
+func chunkedPath(sha256Hex string) string {
+	return chunkedPathPrefix + sha256Hex
+}
+
+func chunkPath(hash string) string {
+	return filepath.Join(chunkDir, hash)
+}
+
+// storeChunks splits the staged blob into chunks and records them as the
+// contents of fileID. Chunks not stored yet are written before qtx commits.
+func storeChunks(ctx context.Context, qtx *database.Queries, blob *stagedBlob, fileID uuid.UUID, chunkSize int64) error {
+	src, err := os.Open(blob.stagedPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	buf := make([]byte, chunkSize)
+	for seq := int32(0); ; seq++ {
+		n, err := io.ReadFull(src, buf)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		data := buf[:n]
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		// Takes the row lock that keeps the collector away from this chunk
+		err = qtx.UpsertChunk(ctx, database.UpsertChunkParams{
+			Hash:      hash,
+			Size:      int64(n),
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("recording chunk: %w", err)
+		}
+		if err := ensureChunk(ctx, hash, data); err != nil {
+			return err
+		}
+		err = qtx.AddFileChunk(ctx, database.AddFileChunkParams{
+			FileID:    fileID,
+			Seq:       seq,
+			ChunkHash: hash,
+		})
+		if err != nil {
+			return fmt.Errorf("recording chunk: %w", err)
+		}
+
+		if n < len(buf) {
+			return nil
+		}
+	}
+}
+
+// ensureChunk writes a chunk unless it is already stored
+func ensureChunk(ctx context.Context, hash string, data []byte) error {
+	if _, err := os.Stat(chunkPath(hash)); err == nil {
+		return nil
+	}
+
+	// Staged first so a crash never leaves a partial chunk under its hash
+	staged := filepath.Join(stagingDir, hash+".chunk")
+	if _, err := writeBlob(ctx, staged, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(chunkDir, 0755); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	if err := os.Rename(staged, chunkPath(hash)); err != nil {
+		os.Remove(staged)
+		return err
+	}
+
+	if replication != nil {
+		return replication.replicate(ctx, chunkPath(hash), blobName(chunkPath(hash)))
+	}
+	return nil
+}
+
+// openChunk opens a stored chunk, restoring it from the replica if it is gone
+func openChunk(ctx context.Context, hash string) (*os.File, error) {
+	file, err := os.Open(chunkPath(hash))
+	if errors.Is(err, fs.ErrNotExist) && replication != nil {
+		replication.restoreChunk(ctx, hash)
+		file, err = os.Open(chunkPath(hash))
+	}
+	return file, err
+}
+
+// chunkedFile reads the chunks of a file as one blob. Chunks are opened as
+// reads reach them. ReadAt may be called concurrently, as SFTP does.
+type chunkedFile struct {
+	mu      sync.Mutex
+	chunks  []database.GetFileChunksRow
+	offsets []int64
+	size    int64
+	pos     int64
+	open    func(hash string) (*os.File, error)
+
+	current    *os.File
+	currentIdx int
+}
+
+func newChunkedFile(chunks []database.GetFileChunksRow, open func(hash string) (*os.File, error)) *chunkedFile {
+	f := &chunkedFile{chunks: chunks, open: open, currentIdx: -1}
+	for _, c := range chunks {
+		f.offsets = append(f.offsets, f.size)
+		f.size += c.Size
+	}
+	return f
+}
+
+func (f *chunkedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	read := 0
+	for read < len(p) {
+		pos := off + int64(read)
+		if pos >= f.size {
+			return read, io.EOF
+		}
+		i := sort.Search(len(f.offsets), func(i int) bool { return f.offsets[i] > pos }) - 1
+
+		if i != f.currentIdx {
+			if f.current != nil {
+				f.current.Close()
+				f.current = nil
+			}
+			file, err := f.open(f.chunks[i].ChunkHash)
+			if err != nil {
+				return read, err
+			}
+			f.current, f.currentIdx = file, i
+		}
+
+		want := min(int64(len(p)-read), f.chunks[i].Size-(pos-f.offsets[i]))
+		n, err := f.current.ReadAt(p[read:read+int(want)], pos-f.offsets[i])
+		read += n
+		if err == io.EOF && int64(n) < want {
+			return read, io.ErrUnexpectedEOF
+		}
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+func (f *chunkedFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *chunkedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *chunkedFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.current != nil {
+		return f.current.Close()
+	}
+	return nil
+}
+
+// openChunkedFile opens the chunks of a chunked file
+func openChunkedFile(ctx context.Context, queries *database.Queries, fileID uuid.UUID) (*chunkedFile, error) {
+	chunks, err := queries.GetFileChunks(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return newChunkedFile(chunks, func(hash string) (*os.File, error) {
+		return openChunk(ctx, hash)
+	}), nil
+}
+
+// collectChunks removes the chunks no file refers to any more, and returns
+// their hashes. With dryRun the chunks are only listed.
+func collectChunks(ctx context.Context, db *sql.DB, queries *database.Queries, dryRun bool) ([]string, error) {
+	var collected []string
+	for {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return collected, err
+		}
+		qtx := queries.WithTx(tx)
+
+		hashes, err := qtx.LockUnreferencedChunks(ctx, chunkCollectBatch)
+		if err != nil {
+			tx.Rollback()
+			return collected, fmt.Errorf("listing unreferenced chunks: %w", err)
+		}
+		if dryRun {
+			tx.Rollback()
+			return append(collected, hashes...), nil
+		}
+
+		for _, hash := range hashes {
+			err := removeBlob(ctx, chunkPath(hash))
+			if err == nil || errors.Is(err, fs.ErrNotExist) {
+				err = qtx.DeleteChunk(ctx, hash)
+			}
+			if err != nil {
+				tx.Rollback()
+				return collected, fmt.Errorf("removing chunk %s: %w", hash, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return collected, err
+		}
+
+		collected = append(collected, hashes...)
+		if len(hashes) < chunkCollectBatch {
+			return collected, nil
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+)
+
+func TestChunkedFileReadsAcrossChunks(t *testing.T) {
+	dir := t.TempDir()
+	parts := []string{"vault", "drive", "!"}
+	var chunks []database.GetFileChunksRow
+	for i, part := range parts {
+		name := string(rune('a' + i))
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(part), 0644); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, database.GetFileChunksRow{ChunkHash: name, Size: int64(len(part))})
+	}
+	open := func(hash string) (*os.File, error) {
+		return os.Open(filepath.Join(dir, hash))
+	}
+
+	file := newChunkedFile(chunks, open)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil || string(data) != "vaultdrive!" {
+		t.Fatalf("ReadAll() = %q, %v", data, err)
+	}
+
+	buf := make([]byte, 4)
+	if n, err := file.ReadAt(buf, 3); err != nil || string(buf[:n]) != "ltdr" {
+		t.Errorf("ReadAt(3) = %q, %v", buf[:n], err)
+	}
+	if n, err := file.ReadAt(buf, 8); err != io.EOF || string(buf[:n]) != "ve!" {
+		t.Errorf("ReadAt(8) = %q, %v, want io.EOF", buf[:n], err)
+	}
+
+	if _, err := file.Seek(-6, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(file)
+	if err != nil || string(data) != "drive!" {
+		t.Errorf("ReadAll() after Seek = %q, %v", data, err)
+	}
+
+	os.Remove(filepath.Join(dir, "c"))
+	if _, err := newChunkedFile(chunks, open).ReadAt(make([]byte, 11), 0); !os.IsNotExist(err) {
+		t.Errorf("ReadAt() with a missing chunk error = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+)
+
+// runCommand runs a vaultdrive subcommand such as `vaultdrive admin create`
+func runCommand(ctx context.Context, db *sql.DB, queries *database.Queries, args []string) error {
+	if len(args) >= 2 && args[0] == "admin" && args[1] == "create" {
+		return runAdminCreate(ctx, db, queries, args[2:])
+	}
+	if args[0] == "reconcile" {
+		return runReconcile(ctx, db, queries, args[1:])
+	}
+	if args[0] == "fsck" {
+		return runFsck(ctx, queries, args[1:])
+	}
+	return fmt.Errorf("unknown command %q (available: admin create, reconcile, fsck)", strings.Join(args, " "))
+}
+
+// runAdminCreate promotes an existing account to admin, or registers a new admin
+// account with its own keypair when the email is not taken yet
+func runAdminCreate(ctx context.Context, db *sql.DB, queries *database.Queries, args []string) error {
+	flags := flag.NewFlagSet("admin create", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the admin account (required)")
+	username := flags.String("username", "", "username for a new account")
+	firstName := flags.String("first-name", "Admin", "first name for a new account")
+	lastName := flags.String("last-name", "", "last name for a new account")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+
+	user, err := queries.GetUserByEmail(ctx, *email)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	create := err == sql.ErrNoRows
+
+	var password string
+	if create {
+		if *username == "" {
+			return fmt.Errorf("no account uses %s; -username is required to create one", *email)
+		}
+
+		// The password is read from the environment or stdin so it stays out of shell history
+		password = os.Getenv("VAULTDRIVE_ADMIN_PASSWORD")
+		if password == "" {
+			fmt.Print("Password: ")
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("could not read password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+	}
+
+	// The account, its key log entries and the role change land together, and
+	// the key log lock is held until commit
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	txQueries := queries.WithTx(tx)
+
+	if create {
+		user, err = createAccount(ctx, txQueries, *firstName, *lastName, *username, *email, password)
+		if err != nil {
+			return err
+		}
+	}
+
+	user, err = txQueries.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:        user.ID,
+		Role:      roleAdmin,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s) is now an admin\n", user.Username, user.Email)
+	return nil
+}
+
+// createAccount registers a user with fresh keypairs, as /register does
+func createAccount(ctx context.Context, queries *database.Queries, firstName, lastName, username, email, password string) (database.User, error) {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	keys, err := newAccountKeys(keyWrapAlgorithm, password)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return createUserWithKeys(ctx, queries, database.CreateUserParams{
+		FirstName:    firstName,
+		LastName:     lastName,
+		Username:     username,
+		Email:        email,
+		PasswordHash: hashedPassword,
+	}, keys)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+func diskFreeBytes(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
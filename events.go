@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	EventFileShared     = "file.shared"
+	EventFileRevoked    = "file.revoked"
+	EventUploadComplete = "file.uploaded"
+	EventQuotaWarning   = "quota.warning"
+	EventFileRemoved    = "file.removed"
+
+	EventRecoveryRequested = "recovery.requested"
+)
+
+// eventsChannel is the Postgres NOTIFY channel the events table trigger publishes on
+const eventsChannel = "vaultdrive_events"
+
+// eventRetention is how long events are kept around for Last-Event-ID resumes
+const eventRetention = 7 * 24 * time.Hour
+
+// quotaWarningRatio is the share of the quota after which uploads emit a warning.
+// It is sent once per crossing; see checkQuota.
+const quotaWarningRatio = 0.9
+
+// eventBroker fans events out to the SSE streams connected to this replica
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan database.Event]struct{}
+
+	// done is closed at shutdown to end every stream
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[uuid.UUID]map[chan database.Event]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// close ends the streams; clients reconnect to another replica with Last-Event-ID
+func (b *eventBroker) close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+func (b *eventBroker) subscribe(userID uuid.UUID) chan database.Event {
+	ch := make(chan database.Event, 16)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan database.Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(userID uuid.UUID, ch chan database.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers[userID], ch)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+}
+
+func (b *eventBroker) dispatch(event database.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.UserID] {
+		select {
+		case ch <- event:
+		default:
+			// Slow client: it can catch up with Last-Event-ID on reconnect
+		}
+	}
+}
+
+// publishEvent stores an event for userID; delivery happens through LISTEN/NOTIFY
+// so that clients connected to any replica receive it.
+func (cfg *ApiConfig) publishEvent(ctx context.Context, userID uuid.UUID, eventType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error marshalling event", "event_type", eventType, "error", err)
+		return
+	}
+
+	if err := cfg.insertEvent(ctx, database.CreateEventParams{
+		UserID:    userID,
+		EventType: eventType,
+		Payload:   string(data),
+	}); err != nil {
+		slog.ErrorContext(ctx, "Error publishing event", "event_type", eventType, "error", err)
+	}
+}
+
+// insertEvent takes the user's event lock before the id is drawn and holds it
+// until commit. Ids are handed out at insert, so without it a lower id could
+// commit after a stream already resumed past a higher one.
+func (cfg *ApiConfig) insertEvent(ctx context.Context, params database.CreateEventParams) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	if err := queries.LockEvents(ctx, params.UserID.String()); err != nil {
+		return err
+	}
+	if _, err := queries.CreateEvent(ctx, params); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkQuota emits a quota warning for userID once their usage crosses
+// quotaWarningRatio. The warning is recorded so later uploads stay quiet, and
+// the record is cleared when usage falls back under the line, so it is called
+// after deletes as well as uploads.
+func (cfg *ApiConfig) checkQuota(ctx context.Context, userID uuid.UUID) {
+	quota, err := cfg.quotaFor(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error retrieving storage quota", "error", err)
+		return
+	}
+	if quota <= 0 {
+		return
+	}
+
+	used, err := cfg.dbQueries.GetTotalFileSizeByOwnerID(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error computing storage usage", "error", err)
+		return
+	}
+
+	if float64(used) < float64(quota)*quotaWarningRatio {
+		if err := cfg.dbQueries.ClearQuotaWarning(ctx, userID); err != nil {
+			slog.ErrorContext(ctx, "Error clearing quota warning", "error", err)
+		}
+		return
+	}
+
+	// Only the request that records the warning sends it
+	marked, err := cfg.dbQueries.MarkQuotaWarningSent(ctx, database.MarkQuotaWarningSentParams{
+		UserID: userID,
+		SentAt: time.Now().UTC(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording quota warning", "error", err)
+		return
+	}
+	if marked == 0 {
+		return
+	}
+
+	cfg.publishEvent(ctx, userID, EventQuotaWarning, map[string]int64{
+		"used_bytes":  used,
+		"quota_bytes": quota,
+	})
+}
+
+// listenForEvents forwards NOTIFY payloads from Postgres to the local broker
+// and periodically prunes events older than eventRetention.
+func (cfg *ApiConfig) listenForEvents(dbURL string) {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Event listener error", "error", err)
+		}
+	})
+
+	if err := listener.Listen(eventsChannel); err != nil {
+		slog.Error("Could not listen for events", "error", err)
+		return
+	}
+
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established
+			if n == nil {
+				continue
+			}
+
+			id, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				slog.Warn("Invalid event notification", "payload", n.Extra, "error", err)
+				continue
+			}
+
+			event, err := cfg.dbQueries.GetEventByID(context.Background(), id)
+			if err != nil {
+				slog.Error("Error loading event", "event_id", id, "error", err)
+				continue
+			}
+
+			cfg.events.dispatch(event)
+
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+
+		case <-cleanup.C:
+			err := cfg.dbQueries.DeleteEventsBefore(context.Background(), time.Now().UTC().Add(-eventRetention))
+			if err != nil {
+				slog.Error("Error pruning old events", "error", err)
+			}
+		}
+	}
+}
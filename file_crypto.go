@@ -0,0 +1,447 @@
+package main
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	fileAlgorithm    = "AES-256-GCM"
+	keyWrapAlgorithm = "RSA-OAEP-256"
+	x25519Algorithm  = "X25519"
+	signingAlgorithm = "Ed25519"
+
+	// x25519WrapInfo binds HKDF output to X25519 key wrapping
+	x25519WrapInfo = "vaultdrive x25519 key wrap"
+
+	// fileSignatureContext starts every signed file payload
+	fileSignatureContext = "vaultdrive-file-signature-v1"
+
+	// pbkdf2Iterations matches deriveKeyFromPassword in the web client
+	pbkdf2Iterations = 100000
+)
+
+var errFileKeyUnavailable = errors.New("no usable key to decrypt this file")
+
+var errInvalidFileName = errors.New("file names must be 1 to 512 bytes without NUL")
+
+// keyWrappingPreference lists the key wrapping schemes strongest first
+var keyWrappingPreference = []string{x25519Algorithm, keyWrapAlgorithm}
+
+// fileMetadata is the JSON stored in files.encrypted_metadata. Files uploaded by the
+// web client derive their key from the password and salt; files encrypted by the
+// server-side gateways use a random key wrapped with the owner's RSA or X25519 public key.
+type fileMetadata struct {
+	IV          string `json:"iv"`
+	Salt        string `json:"salt"`
+	Algorithm   string `json:"algorithm"`
+	KeyWrapping string `json:"key_wrapping,omitempty"`
+	// ETag is set on objects written through the S3 gateway
+	ETag string `json:"etag,omitempty"`
+	// EncryptedName is sealed with the file key, or with the gateway name key
+	// when NameKey is nameKeyGateway
+	EncryptedName string `json:"encrypted_name,omitempty"`
+	NameKey       string `json:"name_key,omitempty"`
+}
+
+func parsePublicKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// publicKeyAlgorithm reports which key wrapping a PEM public key is used with
+func publicKeyAlgorithm(publicKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return "", errors.New("invalid public key PEM")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return keyWrapAlgorithm, nil
+	case *ecdh.PublicKey:
+		if k.Curve() == ecdh.X25519() {
+			return x25519Algorithm, nil
+		}
+	}
+	return "", errors.New("public key must be an RSA or X25519 key")
+}
+
+// parsePrivateKey reads an RSA key in PKCS#1, as generated at registration, or
+// an RSA or X25519 key in PKCS#8
+func parsePrivateKey(privateKeyPEM string) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdh.PrivateKey:
+		if k.Curve() == ecdh.X25519() {
+			return k, nil
+		}
+	}
+	return nil, errors.New("private key must be an RSA or X25519 key")
+}
+
+// parseSigningPublicKey reads an Ed25519 public key in PKIX PEM
+func parseSigningPublicKey(publicKeyPEM string) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("signing key is not an Ed25519 key")
+	}
+	return edKey, nil
+}
+
+// encryptFileForRecipient encrypts plaintext with a fresh AES-256-GCM key and wraps
+// that key for the holder of publicKeyPEM. It returns the ciphertext, the metadata
+// JSON and the base64 wrapped key, in the same shape handlerCreateFiles stores.
+func encryptFileForRecipient(plaintext []byte, publicKeyPEM string) ([]byte, string, string, error) {
+	algorithm, err := publicKeyAlgorithm(publicKeyPEM)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	fileKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, "", "", err
+	}
+
+	gcm, err := newGCM(fileKey)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, "", "", err
+	}
+
+	ciphertext := gcm.Seal(nil, iv, plaintext, nil)
+
+	wrappedKey, err := wrapKeyForRecipient(fileKey, publicKeyPEM)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	metadata, err := json.Marshal(fileMetadata{
+		IV:          base64.StdEncoding.EncodeToString(iv),
+		Algorithm:   fileAlgorithm,
+		KeyWrapping: algorithm,
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return ciphertext, string(metadata), wrappedKey, nil
+}
+
+// wrapKeyForRecipient encrypts a short secret such as a file key for an RSA or
+// X25519 public key. RSA uses OAEP with SHA-256. X25519 agrees a key with a fresh
+// ephemeral keypair, derives the wrapping key with HKDF-SHA256 (salt: ephemeral
+// public key then recipient public key) and seals with AES-256-GCM; the result is
+// ephemeral public key, nonce and ciphertext concatenated. Both are base64.
+func wrapKeyForRecipient(key []byte, publicKeyPEM string) (string, error) {
+	algorithm, err := publicKeyAlgorithm(publicKeyPEM)
+	if err != nil {
+		return "", err
+	}
+
+	if algorithm == keyWrapAlgorithm {
+		publicKey, err := parsePublicKey(publicKeyPEM)
+		if err != nil {
+			return "", err
+		}
+
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(wrapped), nil
+	}
+
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	recipient, ok := parsed.(*ecdh.PublicKey)
+	if !ok {
+		return "", errors.New("public key is not an X25519 key")
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := x25519WrapCipher(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	wrapped := append(ephemeral.PublicKey().Bytes(), nonce...)
+	wrapped = gcm.Seal(wrapped, nonce, key, nil)
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrapKey reverses wrapKeyForRecipient with the matching private key
+func unwrapKey(wrappedKey string, privateKey crypto.PrivateKey) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return rsa.DecryptOAEP(sha256.New(), nil, k, wrapped, nil)
+	case *ecdh.PrivateKey:
+		if len(wrapped) < 32 {
+			return nil, errors.New("wrapped key is too short")
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(wrapped[:32])
+		if err != nil {
+			return nil, err
+		}
+
+		shared, err := k.ECDH(ephemeral)
+		if err != nil {
+			return nil, err
+		}
+
+		gcm, err := x25519WrapCipher(shared, ephemeral, k.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+
+		wrapped = wrapped[32:]
+		if len(wrapped) < gcm.NonceSize() {
+			return nil, errors.New("wrapped key is too short")
+		}
+		return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+	}
+	return nil, errFileKeyUnavailable
+}
+
+// x25519WrapCipher derives the AES-GCM cipher for an X25519 shared secret
+func x25519WrapCipher(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+
+	wrappingKey, err := hkdf.Key(sha256.New, shared, salt, x25519WrapInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(wrappingKey)
+}
+
+// decryptFileContents reverses encryptFileForRecipient. Password-derived files from
+// the web client can only be opened when the account password is known.
+func decryptFileContents(ciphertext []byte, metadataJSON, wrappedKey string, privateKey crypto.PrivateKey, password string) ([]byte, error) {
+	fileKey, err := recoverFileKey(metadataJSON, wrappedKey, privateKey, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata fileMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return nil, err
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(metadata.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, iv, ciphertext, nil)
+}
+
+// recoverFileKey unwraps a file key with the private key, or derives it from
+// the password for files the web client encrypted
+func recoverFileKey(metadataJSON, wrappedKey string, privateKey crypto.PrivateKey, password string) ([]byte, error) {
+	var metadata fileMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case (metadata.KeyWrapping == keyWrapAlgorithm || metadata.KeyWrapping == x25519Algorithm) && privateKey != nil:
+		return unwrapKey(wrappedKey, privateKey)
+	case metadata.Salt != "" && password != "":
+		salt, err := base64.StdEncoding.DecodeString(metadata.Salt)
+		if err != nil {
+			return nil, err
+		}
+		return pbkdf2.Key(sha256.New, password, salt, pbkdf2Iterations, 32)
+	}
+	return nil, errFileKeyUnavailable
+}
+
+// sealFileName encrypts a name in the encrypted_name format: NUL-padded to the
+// smallest of encryptedNameBuckets that fits, sealed with AES-GCM under key and
+// base64 encoded with the IV in front
+func sealFileName(key []byte, name string) (string, error) {
+	size := 0
+	for _, bucket := range encryptedNameBuckets {
+		if len(name) <= bucket {
+			size = bucket
+			break
+		}
+	}
+	if size == 0 || name == "" || strings.ContainsRune(name, 0) {
+		return "", errInvalidFileName
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", err
+	}
+
+	padded := make([]byte, size)
+	copy(padded, name)
+	return base64.StdEncoding.EncodeToString(gcm.Seal(iv, iv, padded, nil)), nil
+}
+
+// openFileName reverses sealFileName
+func openFileName(key []byte, blob string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", errInvalidEncryptedName
+	}
+
+	padded, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimRight(string(padded), "\x00")
+	if name == "" {
+		return "", errInvalidEncryptedName
+	}
+	return name, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fileSignaturePayload is the message an uploader signs: the context string, file
+// ID, version, hex SHA-256 of the ciphertext and hex SHA-256 of the metadata JSON
+// exactly as stored (and returned in X-File-Metadata), one per line
+func fileSignaturePayload(fileID string, version int32, ciphertextSHA256, metadata string) []byte {
+	metadataHash := sha256.Sum256([]byte(metadata))
+	return []byte(strings.Join([]string{
+		fileSignatureContext,
+		fileID,
+		strconv.Itoa(int(version)),
+		ciphertextSHA256,
+		hex.EncodeToString(metadataHash[:]),
+	}, "\n"))
+}
+
+// verifySignature checks a base64 Ed25519 signature against a PEM signing key
+func verifySignature(signingPublicKeyPEM string, payload []byte, signature string) error {
+	publicKey, err := parseSigningPublicKey(signingPublicKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, payload, sig) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func TestEncryptFileForRecipientRoundTrip(t *testing.T) {
+	privKeyPEM, pubKeyPEM, err := generateRSAKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+
+	// The gateway unlocks the key the same way registration locked it
+	encryptedPrivKey, err := encryptPrivateKey(privKeyPEM, "password123")
+	if err != nil {
+		t.Fatalf("Failed to encrypt private key: %v", err)
+	}
+	unlockedPEM, err := decryptPrivateKey(encryptedPrivKey, "password123")
+	if err != nil {
+		t.Fatalf("Failed to decrypt private key: %v", err)
+	}
+	if _, err := decryptPrivateKey(encryptedPrivKey, "wrong-password"); err == nil {
+		t.Error("Expected an error decrypting with the wrong password")
+	}
+
+	privateKey, err := parsePrivateKey(unlockedPEM)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	plaintext := []byte("hello from the gateway")
+	ciphertext, metadata, wrappedKey, err := encryptFileForRecipient(plaintext, pubKeyPEM)
+	if err != nil {
+		t.Fatalf("Failed to encrypt file: %v", err)
+	}
+	if len(ciphertext) != len(plaintext)+gcmTagSize {
+		t.Errorf("Unexpected ciphertext size: got %d want %d", len(ciphertext), len(plaintext)+gcmTagSize)
+	}
+
+	decrypted, err := decryptFileContents(ciphertext, metadata, wrappedKey, privateKey, "")
+	if err != nil {
+		t.Fatalf("Failed to decrypt file: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted content mismatch: got %q want %q", decrypted, plaintext)
+	}
+}
+
+func TestEncryptFileForX25519Recipient(t *testing.T) {
+	privKeyPEM, pubKeyPEM, err := generateX25519Keys()
+	if err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+
+	privateKey, err := parsePrivateKey(privKeyPEM)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	plaintext := []byte("hello over x25519")
+	ciphertext, metadata, wrappedKey, err := encryptFileForRecipient(plaintext, pubKeyPEM)
+	if err != nil {
+		t.Fatalf("Failed to encrypt file: %v", err)
+	}
+	if !strings.Contains(metadata, x25519Algorithm) {
+		t.Errorf("Expected metadata to name %s wrapping: %s", x25519Algorithm, metadata)
+	}
+
+	decrypted, err := decryptFileContents(ciphertext, metadata, wrappedKey, privateKey, "")
+	if err != nil {
+		t.Fatalf("Failed to decrypt file: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted content mismatch: got %q want %q", decrypted, plaintext)
+	}
+
+	// A key wrapped for someone else must not open
+	otherPrivPEM, _, err := generateX25519Keys()
+	if err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+	otherKey, err := parsePrivateKey(otherPrivPEM)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	if _, err := unwrapKey(wrappedKey, otherKey); err == nil {
+		t.Error("Expected an error unwrapping with the wrong key")
+	}
+}
+
+func TestPublicKeyAlgorithm(t *testing.T) {
+	_, rsaPEM, err := generateRSAKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+
+	x25519Key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate X25519 key: %v", err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+
+	encodePEM := func(key any) string {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("Failed to marshal public key: %v", err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+
+	tests := []struct {
+		name      string
+		publicKey string
+		want      string
+		wantErr   bool
+	}{
+		{name: "RSA", publicKey: rsaPEM, want: keyWrapAlgorithm},
+		{name: "X25519", publicKey: encodePEM(x25519Key.PublicKey()), want: x25519Algorithm},
+		{name: "P-256", publicKey: encodePEM(&p256Key.PublicKey), wantErr: true},
+		{name: "Not PEM", publicKey: "not a key", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := publicKeyAlgorithm(tt.publicKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("publicKeyAlgorithm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("publicKeyAlgorithm() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileSignature(t *testing.T) {
+	privKeyPEM, pubKeyPEM, err := generateSigningKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate signing keys: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(privKeyPEM))
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse signing key: %v", err)
+	}
+	signingKey := parsed.(ed25519.PrivateKey)
+
+	fileID := "3f2c8a4e-5b1d-4c7e-9a0f-2d6b8e1c4a7f"
+	metadata := `{"algorithm":"AES-GCM","iv":"aXY=","salt":"c2FsdA=="}`
+	payload := fileSignaturePayload(fileID, 1, "abc123", metadata)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload))
+
+	if err := verifySignature(pubKeyPEM, payload, signature); err != nil {
+		t.Errorf("Expected signature to verify: %v", err)
+	}
+
+	// A blob moved to another file, or a different version, must not verify
+	swapped := fileSignaturePayload("9b7d1e2f-0c3a-4f5b-8e6d-7a1c2b3d4e5f", 1, "abc123", metadata)
+	if err := verifySignature(pubKeyPEM, swapped, signature); err == nil {
+		t.Error("Expected signature over another file ID to fail")
+	}
+	if err := verifySignature(pubKeyPEM, fileSignaturePayload(fileID, 2, "abc123", metadata), signature); err == nil {
+		t.Error("Expected signature over another version to fail")
+	}
+}
+
+func TestValidEncryptedName(t *testing.T) {
+	blob := func(n int) string {
+		return base64.StdEncoding.EncodeToString(make([]byte, n))
+	}
+
+	tests := []struct {
+		name string
+		blob string
+		want bool
+	}{
+		{"smallest bucket", blob(64 + encryptedNameOverhead), true},
+		{"largest bucket", blob(512 + encryptedNameOverhead), true},
+		{"unpadded name", blob(17 + encryptedNameOverhead), false},
+		{"too large", blob(1024 + encryptedNameOverhead), false},
+		{"not base64", "report.pdf", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validEncryptedName(tt.blob); got != tt.want {
+				t.Errorf("validEncryptedName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealFileName(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := sealFileName(key, "report.pdf")
+	if err != nil {
+		t.Fatalf("sealFileName() error = %v", err)
+	}
+	if !validEncryptedName(sealed) {
+		t.Errorf("sealFileName() = %q, not a valid encrypted name", sealed)
+	}
+	if name, err := openFileName(key, sealed); err != nil || name != "report.pdf" {
+		t.Errorf("openFileName() = %q, %v", name, err)
+	}
+
+	otherKey := make([]byte, 32)
+	if _, err := openFileName(otherKey, sealed); err == nil {
+		t.Error("Expected an error opening the name with another key")
+	}
+
+	for _, name := range []string{"", strings.Repeat("a", 513), "a\x00b"} {
+		if _, err := sealFileName(key, name); err != errInvalidFileName {
+			t.Errorf("sealFileName(%q) error = %v, want %v", name, err, errInvalidFileName)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Gateways (WebDAV, SFTP, S3) encrypt and decrypt on the server on behalf of a
+// user. Unlocked keys only ever live in memory for the length of a session.
+//
+// Like API uploads, gateway files keep an encrypted name in their metadata and
+// the file ID in the filename column. SFTP and S3 sessions without gateway
+// encryption cannot unwrap file keys but still look files up by name, so
+// gateways seal names with a per-user key derived from the server's gateway key
+// (name_key "gateway"). Names clients sealed with the file key are opened when
+// the session can recover that key.
+
+const (
+	personalAccessTokenPrefix = "vdpat_"
+	gatewaySessionTTL         = 15 * time.Minute
+
+	// nameKeyGateway marks names sealed with gatewayNameKey
+	nameKeyGateway = "gateway"
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+type gatewaySession struct {
+	user       database.User
+	privateKey crypto.PrivateKey
+	// password is only set for account-password logins, which can also open
+	// files the web client encrypted with a password-derived key
+	password string
+	// tokenID is the personal access token the session was opened with
+	tokenID   uuid.NullUUID
+	createdAt time.Time
+	expiresAt time.Time
+	// names caches opened file names by their encrypted form
+	names sync.Map
+}
+
+type gatewaySessionCache struct {
+	mu       sync.Mutex
+	sessions map[string]*gatewaySession
+}
+
+func newGatewaySessionCache() *gatewaySessionCache {
+	return &gatewaySessionCache{sessions: make(map[string]*gatewaySession)}
+}
+
+// get returns the live session for key and extends its idle timeout
+func (c *gatewaySessionCache) get(key string) *gatewaySession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, ok := c.sessions[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(session.expiresAt) {
+		delete(c.sessions, key)
+		return nil
+	}
+	session.expiresAt = time.Now().Add(gatewaySessionTTL)
+	return session
+}
+
+func (c *gatewaySessionCache) put(key string, session *gatewaySession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, s := range c.sessions {
+		if now.After(s.expiresAt) {
+			delete(c.sessions, k)
+		}
+	}
+
+	if session.createdAt.IsZero() {
+		session.createdAt = now
+	}
+	session.expiresAt = now.Add(gatewaySessionTTL)
+	c.sessions[key] = session
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// loginWithPassword checks account credentials and unlocks the user's private key
+func (cfg *ApiConfig) loginWithPassword(ctx context.Context, email, password string) (*gatewaySession, error) {
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+	if err != nil || user.DisabledAt.Valid {
+		return nil, errInvalidCredentials
+	}
+
+	if err := auth.CheckPasswordHash(password, user.PasswordHash); err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	// A password names no device, so like a login without one it only
+	// unlocks the key while the account has no approved devices. Afterwards
+	// the gateway takes personal access tokens created on an approved device.
+	approvedDevices, err := cfg.dbQueries.CountApprovedDevices(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if approvedDevices > 0 {
+		return nil, errInvalidCredentials
+	}
+
+	privateKeyPEM, err := decryptPrivateKey(user.PrivateKeyEncrypted, password)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gatewaySession{user: user, privateKey: privateKey, password: password}, nil
+}
+
+// loginWithAccessToken resolves a personal access token and unlocks the private key stored with it
+func (cfg *ApiConfig) loginWithAccessToken(ctx context.Context, token string) (*gatewaySession, error) {
+	if !strings.HasPrefix(token, personalAccessTokenPrefix) {
+		return nil, errInvalidCredentials
+	}
+
+	pat, err := cfg.dbQueries.GetPersonalAccessTokenByHash(ctx, hashSecret(token))
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(ctx, pat.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt.Valid {
+		return nil, errInvalidCredentials
+	}
+
+	privateKeyPEM, err := decryptPrivateKey(pat.PrivateKeyEncrypted, token)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.dbQueries.TouchPersonalAccessToken(ctx, database.TouchPersonalAccessTokenParams{
+		ID:         pat.ID,
+		LastUsedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Could not update token last use", "error", err)
+	}
+
+	return &gatewaySession{
+		user:       user,
+		privateKey: privateKey,
+		tokenID:    uuid.NullUUID{UUID: pat.ID, Valid: true},
+	}, nil
+}
+
+// sessionCurrent reports whether a cached session may still be used: the account
+// must not have been disabled, signed out by an admin or had its keys rotated
+// since it was created, and the access token it was opened with must not have
+// been revoked
+func (cfg *ApiConfig) sessionCurrent(ctx context.Context, session *gatewaySession) bool {
+	user, err := cfg.dbQueries.GetUserByID(ctx, session.user.ID)
+	if err != nil || user.DisabledAt.Valid || user.KeyVersion != session.user.KeyVersion {
+		return false
+	}
+	if user.TokensValidAfter.Valid && !session.createdAt.After(user.TokensValidAfter.Time) {
+		return false
+	}
+	if session.tokenID.Valid {
+		active, err := cfg.dbQueries.IsPersonalAccessTokenActive(ctx, session.tokenID.UUID)
+		if err != nil || !active {
+			return false
+		}
+	}
+	return true
+}
+
+// gatewayNameKey is the key gateways seal the names of userID's files with
+func (cfg *ApiConfig) gatewayNameKey(userID uuid.UUID) []byte {
+	mac := hmac.New(sha256.New, cfg.s3GatewayKey)
+	mac.Write([]byte("vaultdrive file names\x00"))
+	mac.Write(userID[:])
+	return mac.Sum(nil)
+}
+
+// setEncryptedName stores an encrypted name in a file's metadata JSON, keeping
+// the other fields
+func setEncryptedName(metadataJSON, encryptedName, nameKey string) (string, error) {
+	metadata := map[string]interface{}{}
+	if metadataJSON != "" {
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return "", err
+		}
+	}
+	metadata["encrypted_name"] = encryptedName
+	if nameKey != "" {
+		metadata["name_key"] = nameKey
+	} else {
+		delete(metadata, "name_key")
+	}
+
+	updated, err := json.Marshal(metadata)
+	return string(updated), err
+}
+
+// nameSealedWith reports which key a file's encrypted name is sealed with: the
+// file key when nameKey is empty
+func nameSealedWith(file database.File) (encryptedName, nameKey string) {
+	var metadata fileMetadata
+	if err := json.Unmarshal([]byte(file.EncryptedMetadata.String), &metadata); err != nil {
+		return "", ""
+	}
+	return metadata.EncryptedName, metadata.NameKey
+}
+
+// fileName is the name gateways show for file. Files whose encrypted name the
+// session cannot open are shown under the filename column, which holds the
+// file ID for them, as are older files that still have a plaintext name.
+func (cfg *ApiConfig) fileName(ctx context.Context, session *gatewaySession, file database.File) string {
+	encryptedName, nameKey := nameSealedWith(file)
+	if encryptedName == "" {
+		return file.Filename
+	}
+	if name, ok := session.names.Load(encryptedName); ok {
+		return name.(string)
+	}
+
+	key, err := cfg.fileNameKey(ctx, session, file, nameKey)
+	if err == nil {
+		var name string
+		name, err = openFileName(key, encryptedName)
+		if err == nil {
+			session.names.Store(encryptedName, name)
+			return name
+		}
+	}
+	if errors.Is(err, errFileKeyUnavailable) {
+		session.names.Store(encryptedName, file.Filename)
+	} else {
+		slog.WarnContext(ctx, "Could not open file name", "file_id", file.ID, "error", err)
+	}
+	return file.Filename
+}
+
+// fileNameKey returns the key a file's name is sealed with
+func (cfg *ApiConfig) fileNameKey(ctx context.Context, session *gatewaySession, file database.File, nameKey string) ([]byte, error) {
+	if nameKey == nameKeyGateway {
+		return cfg.gatewayNameKey(session.user.ID), nil
+	}
+
+	wrappedKey, privateKey, err := cfg.sessionFileKeys(ctx, session, file)
+	if err != nil {
+		return nil, err
+	}
+	return recoverFileKey(file.EncryptedMetadata.String, wrappedKey, privateKey, session.password)
+}
+
+// renamedMetadata seals a new name into file's metadata with the key its
+// current name uses. Clients can still open names sealed with the file key,
+// so those are resealed with it, which needs the session to recover the key.
+func (cfg *ApiConfig) renamedMetadata(ctx context.Context, session *gatewaySession, file database.File, name string) (string, error) {
+	encryptedName, nameKey := nameSealedWith(file)
+	if encryptedName == "" {
+		nameKey = nameKeyGateway
+	}
+
+	key, err := cfg.fileNameKey(ctx, session, file, nameKey)
+	if errors.Is(err, errFileKeyUnavailable) {
+		return "", os.ErrPermission
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := sealFileName(key, name)
+	if err != nil {
+		return "", err
+	}
+	return setEncryptedName(file.EncryptedMetadata.String, sealed, nameKey)
+}
+
+// storeFile encrypts plaintext for user and records it like a regular upload
+func (cfg *ApiConfig) storeFile(ctx context.Context, user database.User, folderID uuid.NullUUID, name string, plaintext []byte) (database.File, error) {
+	ciphertext, metadata, wrappedKey, err := encryptFileForRecipient(plaintext, user.PublicKey)
+	if err != nil {
+		return database.File{}, err
+	}
+
+	blob, err := stageBlob(ctx, bytes.NewReader(ciphertext))
+	if err != nil {
+		return database.File{}, err
+	}
+	defer blob.discard()
+
+	return cfg.recordFile(ctx, user, folderID, name, blob, metadata, wrappedKey)
+}
+
+// recordFile adds the database rows for a staged blob in one transaction and
+// commits the blob after them, like an API upload. The caller discards the
+// blob. Without a wrapped key the owner keeps access through the owner
+// fallback, as for files stored without server-side encryption.
+func (cfg *ApiConfig) recordFile(ctx context.Context, user database.User, folderID uuid.NullUUID, name string, blob *stagedBlob, metadata, wrappedKey string) (database.File, error) {
+	if err := cfg.checkStorageAvailable(ctx, user.ID, blob.size); err != nil {
+		return database.File{}, err
+	}
+
+	sealed, err := sealFileName(cfg.gatewayNameKey(user.ID), name)
+	if err != nil {
+		return database.File{}, err
+	}
+	metadata, err = setEncryptedName(metadata, sealed, nameKeyGateway)
+	if err != nil {
+		return database.File{}, err
+	}
+
+	sum, _, err := hashBlob(ctx, blob.stagedPath, 0)
+	if err != nil {
+		return database.File{}, err
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.File{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	fileID := uuid.New()
+	dbFile, err := qtx.CreateFile(ctx, database.CreateFileParams{
+		ID:                uuid.NullUUID{UUID: fileID, Valid: true},
+		OwnerID:           uuid.NullUUID{UUID: user.ID, Valid: true},
+		Filename:          fileID.String(),
+		FilePath:          blob.path,
+		FileSize:          blob.size,
+		EncryptedMetadata: sql.NullString{String: metadata, Valid: true},
+		CurrentKeyVersion: sql.NullInt32{Int32: 1, Valid: true},
+		CreatedAt:         time.Now().UTC(),
+		UpdatedAt:         time.Now().UTC(),
+		FolderID:          folderID,
+	})
+	if err != nil {
+		return database.File{}, err
+	}
+
+	err = qtx.SetFileHash(ctx, database.SetFileHashParams{
+		FileID:    dbFile.ID,
+		Sha256:    sql.NullString{String: sum, Valid: true},
+		CheckedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return database.File{}, err
+	}
+
+	if wrappedKey != "" {
+		_, err = qtx.CreateFileAccessKey(ctx, database.CreateFileAccessKeyParams{
+			FileID:     uuid.NullUUID{UUID: dbFile.ID, Valid: true},
+			UserID:     uuid.NullUUID{UUID: user.ID, Valid: true},
+			WrappedKey: wrappedKey,
+			KeyVersion: sql.NullInt32{Int32: user.KeyVersion, Valid: true},
+		})
+		if err != nil {
+			return database.File{}, err
+		}
+	}
+
+	if err := cfg.commitBlob(ctx, tx, blob, dbFile.ID); err != nil {
+		return database.File{}, err
+	}
+
+	cfg.publishEvent(ctx, user.ID, EventUploadComplete, map[string]interface{}{
+		"file_id":   dbFile.ID,
+		"file_name": dbFile.Filename,
+		"file_size": dbFile.FileSize,
+	})
+	cfg.checkQuota(ctx, user.ID)
+
+	return dbFile, nil
+}
+
+// loadFile reads and decrypts a file the session's user has a key for
+func (cfg *ApiConfig) loadFile(ctx context.Context, session *gatewaySession, file database.File) ([]byte, error) {
+	wrappedKey, privateKey, err := cfg.sessionFileKeys(ctx, session, file)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := cfg.readBlob(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptFileContents(ciphertext, file.EncryptedMetadata.String, wrappedKey, privateKey, session.password)
+}
+
+// sessionFileKeys returns the session user's wrapped key for file, if any, and
+// the private key of the version it is wrapped for
+func (cfg *ApiConfig) sessionFileKeys(ctx context.Context, session *gatewaySession, file database.File) (string, crypto.PrivateKey, error) {
+	accessKey, err := cfg.dbQueries.GetFileAccessKey(ctx, database.GetFileAccessKeyParams{
+		FileID: uuid.NullUUID{UUID: file.ID, Valid: true},
+		UserID: uuid.NullUUID{UUID: session.user.ID, Valid: true},
+	})
+	if err == sql.ErrNoRows {
+		return "", session.privateKey, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	privateKey, err := cfg.sessionPrivateKey(ctx, session, accessKey.KeyVersion)
+	if err != nil {
+		return "", nil, err
+	}
+	return accessKey.WrappedKey, privateKey, nil
+}
+
+// removeFile deletes a file's blob and its database row
+func (cfg *ApiConfig) removeFile(ctx context.Context, file database.File) error {
+	if err := removeBlob(ctx, file.FilePath); err != nil && !os.IsNotExist(err) {
+		slog.WarnContext(ctx, "Could not delete file from disk", "file_id", file.ID, "error", err)
+	}
+	if err := cfg.dbQueries.DeleteFile(ctx, file.ID); err != nil {
+		return err
+	}
+
+	if file.OwnerID.Valid {
+		cfg.checkQuota(ctx, file.OwnerID.UUID)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Admin endpoints sit behind middlewareRequireRole. They manage accounts and
+// storage but never touch keys, so admins cannot read anyone's files.
+
+const adminUsersPageSize = 50
+
+type adminUserResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	FirstName  string     `json:"first_name"`
+	LastName   string     `json:"last_name"`
+	Username   string     `json:"username"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	QuotaBytes *int64     `json:"quota_bytes"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAdminUserResponse(user database.User) adminUserResponse {
+	resp := adminUserResponse{
+		ID:        user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      user.Role,
+		Disabled:  user.DisabledAt.Valid,
+		CreatedAt: user.CreatedAt,
+	}
+	if user.DisabledAt.Valid {
+		resp.DisabledAt = &user.DisabledAt.Time
+	}
+	if user.QuotaBytes.Valid {
+		resp.QuotaBytes = &user.QuotaBytes.Int64
+	}
+	return resp
+}
+
+// handlerAdminListUsers lists accounts with their storage usage, optionally
+// filtered by ?q= against email and username
+func (cfg *ApiConfig) handlerAdminListUsers(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > adminUsersPageSize {
+		limit = adminUsersPageSize
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	users, err := cfg.dbQueries.SearchUsers(r.Context(), database.SearchUsersParams{
+		Query:       r.URL.Query().Get("q"),
+		LimitCount:  int32(limit),
+		OffsetCount: int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve users", err)
+		return
+	}
+
+	type UserResponse struct {
+		adminUserResponse
+		FileCount   int64 `json:"file_count"`
+		StorageUsed int64 `json:"storage_used"`
+	}
+
+	userResponses := []UserResponse{}
+	for _, u := range users {
+		userResponses = append(userResponses, UserResponse{
+			adminUserResponse: newAdminUserResponse(database.User{
+				ID:         u.ID,
+				FirstName:  u.FirstName,
+				LastName:   u.LastName,
+				Username:   u.Username,
+				Email:      u.Email,
+				Role:       u.Role,
+				DisabledAt: u.DisabledAt,
+				QuotaBytes: u.QuotaBytes,
+				CreatedAt:  u.CreatedAt,
+			}),
+			FileCount:   u.FileCount,
+			StorageUsed: u.StorageUsed,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, userResponses)
+}
+
+func (cfg *ApiConfig) handlerAdminDisableUser(w http.ResponseWriter, r *http.Request) {
+	adminID, targetID, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if adminID == targetID {
+		respondWithError(w, http.StatusBadRequest, "You cannot disable your own account", nil)
+		return
+	}
+
+	now := time.Now().UTC()
+	user, err := cfg.dbQueries.SetUserDisabled(r.Context(), database.SetUserDisabledParams{
+		ID:         targetID,
+		DisabledAt: sql.NullTime{Time: now, Valid: true},
+		UpdatedAt:  now,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not disable user", err)
+		return
+	}
+
+	if err := signOutUser(r.Context(), cfg.dbQueries, targetID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not sign out user", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin disabled user", "admin_id", adminID, "target_id", targetID)
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(user))
+}
+
+func (cfg *ApiConfig) handlerAdminEnableUser(w http.ResponseWriter, r *http.Request) {
+	adminID, targetID, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.dbQueries.SetUserDisabled(r.Context(), database.SetUserDisabledParams{
+		ID:        targetID,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not enable user", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin enabled user", "admin_id", adminID, "target_id", targetID)
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(user))
+}
+
+// handlerAdminLogoutUser revokes every refresh token and access token issued to a user
+func (cfg *ApiConfig) handlerAdminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	adminID, targetID, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetUserByID(r.Context(), targetID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+
+	if err := signOutUser(r.Context(), cfg.dbQueries, targetID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not sign out user", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin signed out user", "admin_id", adminID, "target_id", targetID)
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "User signed out successfully",
+	})
+}
+
+// signOutUser revokes userID's refresh tokens and rejects access tokens issued until now
+func signOutUser(ctx context.Context, queries *database.Queries, userID uuid.UUID) error {
+	// JWT issue times have second precision
+	now := time.Now().UTC().Truncate(time.Second)
+
+	err := queries.RevokeRefreshTokensByUser(ctx, database.RevokeRefreshTokensByUserParams{
+		UserID:    userID,
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	return queries.SetUserTokensValidAfter(ctx, database.SetUserTokensValidAfterParams{
+		ID:               userID,
+		TokensValidAfter: sql.NullTime{Time: now, Valid: true},
+	})
+}
+
+// handlerAdminSetQuota sets a user's storage quota; null restores the default
+func (cfg *ApiConfig) handlerAdminSetQuota(w http.ResponseWriter, r *http.Request) {
+	adminID, targetID, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		QuotaBytes *int64 `json:"quota_bytes"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	quota := sql.NullInt64{}
+	if params.QuotaBytes != nil {
+		if *params.QuotaBytes < 0 {
+			respondWithError(w, http.StatusBadRequest, "Quota cannot be negative", nil)
+			return
+		}
+		quota = sql.NullInt64{Int64: *params.QuotaBytes, Valid: true}
+	}
+
+	user, err := cfg.dbQueries.SetUserQuota(r.Context(), database.SetUserQuotaParams{
+		ID:         targetID,
+		QuotaBytes: quota,
+		UpdatedAt:  time.Now().UTC(),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set quota", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin set user quota", "admin_id", adminID, "target_id", targetID)
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(user))
+}
+
+// handlerAdminSetRole changes a user's role. Their existing tokens stop working
+// because the role claim no longer matches.
+func (cfg *ApiConfig) handlerAdminSetRole(w http.ResponseWriter, r *http.Request) {
+	adminID, targetID, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.Role != roleUser && params.Role != roleAdmin && params.Role != roleAuditor {
+		respondWithError(w, http.StatusBadRequest, "Role must be user, admin or auditor", nil)
+		return
+	}
+
+	if adminID == targetID && params.Role != roleAdmin {
+		respondWithError(w, http.StatusBadRequest, "You cannot remove your own admin role", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:        targetID,
+		Role:      params.Role,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set role", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin set user role", "admin_id", adminID, "target_id", targetID, "role", params.Role)
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(user))
+}
+
+func (cfg *ApiConfig) handlerAdminStorageStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := cfg.dbQueries.GetStorageStats(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve storage statistics", err)
+		return
+	}
+
+	topUsers, err := cfg.dbQueries.GetTopStorageUsers(r.Context(), 10)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve storage statistics", err)
+		return
+	}
+
+	type TopUser struct {
+		ID          uuid.UUID `json:"id"`
+		Username    string    `json:"username"`
+		StorageUsed int64     `json:"storage_used"`
+	}
+
+	top := []TopUser{}
+	for _, u := range topUsers {
+		top = append(top, TopUser{ID: u.ID, Username: u.Username, StorageUsed: u.StorageUsed})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"total_users":        stats.TotalUsers,
+		"disabled_users":     stats.DisabledUsers,
+		"total_files":        stats.TotalFiles,
+		"total_bytes":        stats.TotalBytes,
+		"default_quota":      cfg.userQuotaBytes,
+		"top_users_by_usage": top,
+	})
+}
+
+// handlerAdminDeleteFile removes a file's ciphertext, keys and shares by ID. The
+// owner is notified; recipients lose access along with the cascaded rows.
+func (cfg *ApiConfig) handlerAdminDeleteFile(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	adminID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	fileID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid file ID format", err)
+		return
+	}
+
+	file, err := cfg.dbQueries.GetFileByID(r.Context(), fileID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "File not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving file info", err)
+		return
+	}
+
+	if err := cfg.removeFile(r.Context(), file); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete file", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin deleted file", "admin_id", adminID, "file_id", file.ID, "owner_id", file.OwnerID.UUID)
+	if file.OwnerID.Valid {
+		cfg.publishEvent(r.Context(), file.OwnerID.UUID, EventFileRemoved, map[string]interface{}{
+			"file_id":   file.ID,
+			"file_name": file.Filename,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "File deleted successfully",
+	})
+}
+
+// adminTarget returns the acting admin's ID and the user ID from the path
+func (cfg *ApiConfig) adminTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	adminID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return adminID, targetID, true
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Contacts pin the fingerprint of another user's key when added. Marking a
+// contact verified means the fingerprint was compared out of band (see
+// handlerGetSafetyNumber). Shares to a contact are refused while their current
+// key differs from the pin, until the user verifies the new key.
+
+const (
+	contactUnverified = "unverified"
+	contactVerified   = "verified"
+	contactKeyChanged = "key_changed"
+)
+
+// contactStatus compares a contact's pin with the fingerprint of their current key
+func contactStatus(pinnedFingerprint string, verifiedAt sql.NullTime, currentFingerprint string) string {
+	if pinnedFingerprint != currentFingerprint {
+		return contactKeyChanged
+	}
+	if verifiedAt.Valid {
+		return contactVerified
+	}
+	return contactUnverified
+}
+
+// normalizeFingerprint drops spacing and case so typed fingerprints compare equal
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.Join(strings.Fields(fingerprint), ""))
+}
+
+// checkContactKey refuses to wrap a key for a recipient who is a contact whose
+// key changed since it was pinned: file shares, group and organization
+// members, and recovery trustees. Recipients who are not contacts pass.
+func (cfg *ApiConfig) checkContactKey(w http.ResponseWriter, r *http.Request, userID uuid.UUID, recipient database.User) bool {
+	contact, err := cfg.dbQueries.GetContact(r.Context(), database.GetContactParams{
+		UserID:    userID,
+		ContactID: recipient.ID,
+	})
+	if err == sql.ErrNoRows {
+		return true
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving contact", err)
+		return false
+	}
+
+	fingerprint, err := keyFingerprint(recipient.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error computing key fingerprint", err)
+		return false
+	}
+
+	if contactStatus(contact.PinnedFingerprint, contact.VerifiedAt, fingerprint) == contactKeyChanged {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":               "Recipient's key has changed since it was pinned, compare fingerprints and verify the contact again",
+			"code":                contactKeyChanged,
+			"pinned_fingerprint":  contact.PinnedFingerprint,
+			"current_fingerprint": fingerprint,
+			"key_version":         recipient.KeyVersion,
+		})
+		return false
+	}
+
+	return true
+}
+
+// checkContactKeys runs checkContactKey for every recipient other than the
+// caller, for requests that wrap keys for several users at once
+func (cfg *ApiConfig) checkContactKeys(w http.ResponseWriter, r *http.Request, userID uuid.UUID, recipientIDs []uuid.UUID) bool {
+	for _, id := range recipientIDs {
+		if id == userID {
+			continue
+		}
+		recipient, err := cfg.dbQueries.GetUserByID(r.Context(), id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+			return false
+		}
+		if !cfg.checkContactKey(w, r, userID, recipient) {
+			return false
+		}
+	}
+	return true
+}
+
+// handlerAddContact adds a user as a contact, pinning their current key. A
+// fingerprint in the request that matches the key marks the contact verified.
+func (cfg *ApiConfig) handlerAddContact(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type parameters struct {
+		Email       string `json:"email"`
+		Fingerprint string `json:"fingerprint"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	other, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+	if other.ID == userID {
+		respondWithError(w, http.StatusBadRequest, "You cannot add yourself as a contact", nil)
+		return
+	}
+
+	fingerprint, err := keyFingerprint(other.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error computing key fingerprint", err)
+		return
+	}
+
+	var verifiedAt sql.NullTime
+	if params.Fingerprint != "" {
+		if normalizeFingerprint(params.Fingerprint) != normalizeFingerprint(fingerprint) {
+			respondWithError(w, http.StatusConflict, "Fingerprint does not match the user's current key", nil)
+			return
+		}
+		verifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+
+	contact, err := cfg.dbQueries.CreateContact(r.Context(), database.CreateContactParams{
+		UserID:            userID,
+		ContactID:         other.ID,
+		PinnedFingerprint: fingerprint,
+		PinnedKeyVersion:  other.KeyVersion,
+		VerifiedAt:        verifiedAt,
+		CreatedAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not add contact (already added?)", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"user_id":            contact.ContactID,
+		"username":           other.Username,
+		"email":              other.Email,
+		"pinned_fingerprint": contact.PinnedFingerprint,
+		"pinned_key_version": contact.PinnedKeyVersion,
+		"status":             contactStatus(contact.PinnedFingerprint, contact.VerifiedAt, fingerprint),
+	})
+}
+
+func (cfg *ApiConfig) handlerListContacts(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	contacts, err := cfg.dbQueries.GetContactsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve contacts", err)
+		return
+	}
+
+	type ContactResponse struct {
+		UserID             uuid.UUID  `json:"user_id"`
+		Username           string     `json:"username"`
+		Email              string     `json:"email"`
+		Status             string     `json:"status"`
+		PinnedFingerprint  string     `json:"pinned_fingerprint"`
+		PinnedKeyVersion   int32      `json:"pinned_key_version"`
+		CurrentFingerprint string     `json:"current_fingerprint"`
+		KeyVersion         int32      `json:"key_version"`
+		VerifiedAt         *time.Time `json:"verified_at"`
+	}
+
+	contactResponses := []ContactResponse{}
+	for _, c := range contacts {
+		fingerprint, err := keyFingerprint(c.PublicKey)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error computing key fingerprint", err)
+			return
+		}
+
+		var verifiedAt *time.Time
+		if c.VerifiedAt.Valid {
+			verifiedAt = &c.VerifiedAt.Time
+		}
+		contactResponses = append(contactResponses, ContactResponse{
+			UserID:             c.ContactID,
+			Username:           c.Username,
+			Email:              c.Email,
+			Status:             contactStatus(c.PinnedFingerprint, c.VerifiedAt, fingerprint),
+			PinnedFingerprint:  c.PinnedFingerprint,
+			PinnedKeyVersion:   c.PinnedKeyVersion,
+			CurrentFingerprint: fingerprint,
+			KeyVersion:         c.KeyVersion,
+			VerifiedAt:         verifiedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, contactResponses)
+}
+
+// handlerVerifyContact pins a contact's current key after the user compared
+// its fingerprint. The fingerprint they compared must be sent back, so a key
+// that changed in the meantime is not pinned by mistake.
+func (cfg *ApiConfig) handlerVerifyContact(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	contactID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	type parameters struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetContact(r.Context(), database.GetContactParams{
+		UserID:    userID,
+		ContactID: contactID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Contact not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving contact", err)
+		return
+	}
+
+	other, err := cfg.dbQueries.GetUserByID(r.Context(), contactID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+
+	fingerprint, err := keyFingerprint(other.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error computing key fingerprint", err)
+		return
+	}
+
+	if params.Fingerprint == "" || normalizeFingerprint(params.Fingerprint) != normalizeFingerprint(fingerprint) {
+		respondWithError(w, http.StatusConflict, "Fingerprint does not match the contact's current key", nil)
+		return
+	}
+
+	now := time.Now().UTC()
+	contact, err := cfg.dbQueries.PinContactKey(r.Context(), database.PinContactKeyParams{
+		UserID:            userID,
+		ContactID:         contactID,
+		PinnedFingerprint: fingerprint,
+		PinnedKeyVersion:  other.KeyVersion,
+		VerifiedAt:        sql.NullTime{Time: now, Valid: true},
+		UpdatedAt:         now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not verify contact", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":            contact.ContactID,
+		"pinned_fingerprint": contact.PinnedFingerprint,
+		"pinned_key_version": contact.PinnedKeyVersion,
+		"status":             contactVerified,
+	})
+}
+
+func (cfg *ApiConfig) handlerDeleteContact(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	contactID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteContact(r.Context(), database.DeleteContactParams{
+		UserID:    userID,
+		ContactID: contactID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete contact", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Contact not found", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Contact deleted successfully",
+	})
+}
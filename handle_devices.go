@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Devices register their own keypair at login. The first device of an account is
+// approved straight away and unlocks the account private key with the password;
+// later devices stay pending until an approved device enters their pairing code
+// and wraps the account private key (the master key) for the new device.
+
+const (
+	pairingCodeTTL         = 10 * time.Minute
+	maxPairingAttempts     = 5
+	deviceStatusApproved   = "approved"
+	deviceStatusPending    = "pending"
+	pairingCodeDigits      = 8
+	pairingCodeDigitsLimit = 100000000
+)
+
+var (
+	errUnknownDevice    = errors.New("unknown device")
+	errInvalidDeviceKey = errors.New("invalid device public key")
+)
+
+func deviceStatus(device database.Device) string {
+	if device.ApprovedAt.Valid {
+		return deviceStatusApproved
+	}
+	return deviceStatusPending
+}
+
+// approvedDeviceUser returns the user of an access token issued to one of
+// their approved devices. Paths that hand out the account private key, or
+// re-wrap it under another secret, use it so that pending devices and logins
+// without a device cannot reach the key.
+func (cfg *ApiConfig) approvedDeviceUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return uuid.Nil, false
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return uuid.Nil, false
+	}
+	userID := uuid.MustParse(claims.Subject)
+
+	if claims.DeviceID == "" {
+		respondWithError(w, http.StatusForbidden, "This needs a session on an approved device", nil)
+		return uuid.Nil, false
+	}
+	device, err := cfg.dbQueries.GetDeviceByID(r.Context(), uuid.MustParse(claims.DeviceID))
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving device", err)
+		return uuid.Nil, false
+	}
+	if err == sql.ErrNoRows || device.UserID != userID || !device.ApprovedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "This needs a session on an approved device", err)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// newPairingCode returns a random numeric code and the hash stored for it
+func newPairingCode() (string, string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(pairingCodeDigitsLimit))
+	if err != nil {
+		return "", "", err
+	}
+	code := fmt.Sprintf("%0*d", pairingCodeDigits, n)
+	return code, hashPairingCode(code), nil
+}
+
+func hashPairingCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// loginDevice resolves the device a login is for: an existing device by ID, or a
+// new one registered with its public key. Pending devices get a fresh pairing
+// code each time they log in. A zero Device means the login named no device.
+func (cfg *ApiConfig) loginDevice(ctx context.Context, userID uuid.UUID, deviceID, name, publicKey string) (database.Device, string, error) {
+	now := time.Now().UTC()
+
+	if deviceID != "" {
+		id, err := uuid.Parse(deviceID)
+		if err != nil {
+			return database.Device{}, "", errUnknownDevice
+		}
+		device, err := cfg.dbQueries.GetDeviceByID(ctx, id)
+		if err == sql.ErrNoRows || (err == nil && device.UserID != userID) {
+			return database.Device{}, "", errUnknownDevice
+		}
+		if err != nil {
+			return database.Device{}, "", err
+		}
+
+		err = cfg.dbQueries.TouchDevice(ctx, database.TouchDeviceParams{ID: device.ID, LastSeenAt: now})
+		if err != nil {
+			return database.Device{}, "", err
+		}
+		if device.ApprovedAt.Valid {
+			return device, "", nil
+		}
+
+		code, codeHash, err := newPairingCode()
+		if err != nil {
+			return database.Device{}, "", err
+		}
+		device.PairingExpiresAt = sql.NullTime{Time: now.Add(pairingCodeTTL), Valid: true}
+		err = cfg.dbQueries.SetDevicePairingCode(ctx, database.SetDevicePairingCodeParams{
+			ID:               device.ID,
+			PairingCodeHash:  sql.NullString{String: codeHash, Valid: true},
+			PairingExpiresAt: device.PairingExpiresAt,
+		})
+		if err != nil {
+			return database.Device{}, "", err
+		}
+		return device, code, nil
+	}
+
+	if publicKey == "" {
+		return database.Device{}, "", nil
+	}
+
+	algorithm, err := publicKeyAlgorithm(publicKey)
+	if err != nil {
+		return database.Device{}, "", errInvalidDeviceKey
+	}
+
+	approvedDevices, err := cfg.dbQueries.CountApprovedDevices(ctx, userID)
+	if err != nil {
+		return database.Device{}, "", err
+	}
+
+	params := database.CreateDeviceParams{
+		UserID:       userID,
+		Name:         name,
+		PublicKey:    publicKey,
+		KeyAlgorithm: algorithm,
+		CreatedAt:    now,
+		LastSeenAt:   now,
+	}
+	if params.Name == "" {
+		params.Name = "Unnamed device"
+	}
+
+	code := ""
+	if approvedDevices == 0 {
+		params.ApprovedAt = sql.NullTime{Time: now, Valid: true}
+	} else {
+		var codeHash string
+		code, codeHash, err = newPairingCode()
+		if err != nil {
+			return database.Device{}, "", err
+		}
+		params.PairingCodeHash = sql.NullString{String: codeHash, Valid: true}
+		params.PairingExpiresAt = sql.NullTime{Time: now.Add(pairingCodeTTL), Valid: true}
+	}
+
+	device, err := cfg.dbQueries.CreateDevice(ctx, params)
+	if err != nil {
+		return database.Device{}, "", err
+	}
+	return device, code, nil
+}
+
+// handlerListDevices lists the caller's devices. The calling device also gets its
+// wrapped master key, which is how a newly approved device picks it up.
+func (cfg *ApiConfig) handlerListDevices(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+	userID := uuid.MustParse(claims.Subject)
+
+	devices, err := cfg.dbQueries.GetDevicesByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve devices", err)
+		return
+	}
+
+	type DeviceResponse struct {
+		ID               uuid.UUID  `json:"id"`
+		Name             string     `json:"name"`
+		Status           string     `json:"status"`
+		Current          bool       `json:"current"`
+		PublicKey        string     `json:"public_key"`
+		KeyAlgorithm     string     `json:"key_algorithm"`
+		WrappedMasterKey string     `json:"wrapped_master_key,omitempty"`
+		ApprovedAt       *time.Time `json:"approved_at"`
+		CreatedAt        time.Time  `json:"created_at"`
+		LastSeenAt       time.Time  `json:"last_seen_at"`
+	}
+
+	deviceResponses := []DeviceResponse{}
+	for _, d := range devices {
+		resp := DeviceResponse{
+			ID:           d.ID,
+			Name:         d.Name,
+			Status:       deviceStatus(d),
+			Current:      d.ID.String() == claims.DeviceID,
+			PublicKey:    d.PublicKey,
+			KeyAlgorithm: d.KeyAlgorithm,
+			CreatedAt:    d.CreatedAt,
+			LastSeenAt:   d.LastSeenAt,
+		}
+		if resp.Current {
+			resp.WrappedMasterKey = d.WrappedMasterKey.String
+		}
+		if d.ApprovedAt.Valid {
+			resp.ApprovedAt = &d.ApprovedAt.Time
+		}
+		deviceResponses = append(deviceResponses, resp)
+	}
+
+	respondWithJSON(w, http.StatusOK, deviceResponses)
+}
+
+// handlerApproveDevice approves a pending device from an already approved one.
+// The pairing code shown on the new device proves the user holds both, and
+// wrapped_master_key is the account private key wrapped for the new device.
+func (cfg *ApiConfig) handlerApproveDevice(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+	userID := uuid.MustParse(claims.Subject)
+
+	deviceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid device ID format", err)
+		return
+	}
+
+	type parameters struct {
+		PairingCode      string `json:"pairing_code"`
+		WrappedMasterKey string `json:"wrapped_master_key"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.PairingCode == "" || params.WrappedMasterKey == "" {
+		respondWithError(w, http.StatusBadRequest, "pairing_code and wrapped_master_key are required", nil)
+		return
+	}
+
+	approverID, err := uuid.Parse(claims.DeviceID)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "Devices can only be approved from an approved device", err)
+		return
+	}
+	approver, err := cfg.dbQueries.GetDeviceByID(r.Context(), approverID)
+	if err != nil || approver.UserID != userID || !approver.ApprovedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Devices can only be approved from an approved device", err)
+		return
+	}
+
+	device, err := cfg.dbQueries.GetDeviceByID(r.Context(), deviceID)
+	if err == sql.ErrNoRows || (err == nil && device.UserID != userID) {
+		respondWithError(w, http.StatusNotFound, "Device not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving device", err)
+		return
+	}
+
+	if device.ApprovedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Device is already approved", nil)
+		return
+	}
+
+	if !device.PairingCodeHash.Valid || !device.PairingExpiresAt.Valid || time.Now().UTC().After(device.PairingExpiresAt.Time) {
+		respondWithError(w, http.StatusGone, "Pairing code has expired, log in again on the new device", nil)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashPairingCode(params.PairingCode)), []byte(device.PairingCodeHash.String)) != 1 {
+		attempts, err := cfg.dbQueries.RecordDevicePairingAttempt(r.Context(), device.ID)
+		if err == nil && attempts >= maxPairingAttempts {
+			// Too many guesses; the new device has to log in again for a new code
+			cfg.dbQueries.SetDevicePairingCode(r.Context(), database.SetDevicePairingCodeParams{ID: device.ID})
+		}
+		respondWithError(w, http.StatusUnauthorized, "Incorrect pairing code", nil)
+		return
+	}
+
+	approved, err := cfg.dbQueries.ApproveDevice(r.Context(), database.ApproveDeviceParams{
+		ID:               device.ID,
+		WrappedMasterKey: sql.NullString{String: params.WrappedMasterKey, Valid: true},
+		ApprovedAt:       sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not approve device", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Device approved", "device_id", approved.ID, "approver_device_id", approver.ID)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"id":          approved.ID,
+		"name":        approved.Name,
+		"status":      deviceStatus(approved),
+		"approved_at": approved.ApprovedAt.Time,
+	})
+}
+
+// handlerRevokeDevice removes a device and its wrapped master key, and revokes its
+// refresh tokens. Access tokens issued to it are rejected from then on.
+func (cfg *ApiConfig) handlerRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	deviceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid device ID format", err)
+		return
+	}
+
+	device, err := cfg.dbQueries.GetDeviceByID(r.Context(), deviceID)
+	if err == sql.ErrNoRows || (err == nil && device.UserID != userID) {
+		respondWithError(w, http.StatusNotFound, "Device not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving device", err)
+		return
+	}
+
+	err = cfg.dbQueries.RevokeRefreshTokensByDevice(r.Context(), database.RevokeRefreshTokensByDeviceParams{
+		DeviceID:  uuid.NullUUID{UUID: device.ID, Valid: true},
+		RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not revoke device tokens", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteDevice(r.Context(), database.DeleteDeviceParams{
+		ID:     device.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not revoke device", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Device revoked", "device_id", device.ID)
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Device revoked successfully",
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+)
+
+const (
+	// eventHeartbeat keeps idle streams and the proxies in front of them open
+	eventHeartbeat = 25 * time.Second
+	// eventWriteTimeout bounds each write to a stream, so a client that stops
+	// reading is dropped
+	eventWriteTimeout = 10 * time.Second
+)
+
+// handlerEvents streams share, revoke, upload and quota events to the caller as Server-Sent Events
+func (cfg *ApiConfig) handlerEvents(w http.ResponseWriter, r *http.Request) {
+	// EventSource cannot set headers, so browsers pass the token as a query parameter
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("token")
+		if token == "" {
+			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+			return
+		}
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+
+	var lastEventID int64
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventIDStr != "" {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is lost
+	ch := cfg.events.subscribe(userID)
+	defer cfg.events.unsubscribe(userID, ch)
+
+	var missed []database.Event
+	if lastEventIDStr != "" {
+		missed, err = cfg.dbQueries.GetEventsByUserAfterID(r.Context(), database.GetEventsByUserAfterIDParams{
+			UserID: userID,
+			ID:     lastEventID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not retrieve missed events", err)
+			return
+		}
+	}
+
+	// Streams have no deadlines of their own; each write gets a fresh one
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range missed {
+		writeEvent(w, event)
+		lastEventID = event.ID
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-cfg.events.done:
+			return
+
+		case event := <-ch:
+			// Skip anything already sent during the replay
+			if event.ID <= lastEventID {
+				continue
+			}
+			rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			writeEvent(w, event)
+			lastEventID = event.ID
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event database.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.EventType, event.Payload)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestEventStreamOutlivesReadTimeout(t *testing.T) {
+	cfg := &ApiConfig{jwtSecret: "secret", events: newEventBroker()}
+	defer cfg.events.close()
+
+	t.Setenv("HTTP_READ_TIMEOUT", "100ms")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "100ms")
+	config, err := loadServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /events", withTimeouts(config.stream, http.HandlerFunc(cfg.handlerEvents)))
+	srv := httptest.NewServer(middlewareRequestLog(withTimeouts(config.api, mux)))
+	defer srv.Close()
+
+	userID := uuid.New()
+	token, err := auth.MakeJWT(userID, "user", cfg.jwtSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	time.Sleep(3 * config.api.read)
+	cfg.events.dispatch(database.Event{ID: 1, UserID: userID, EventType: EventFileShared, Payload: "{}"})
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream ended before the event arrived")
+			}
+			if strings.HasPrefix(line, "event: "+EventFileShared) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("event did not arrive")
+		}
+	}
+}
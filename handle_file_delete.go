@@ -1,8 +1,8 @@
 package main
 
 import (
+	"log/slog"
 	"net/http"
-	"os"
 
 	"github.com/Pranay0205/VaultDrive/auth"
 	"github.com/google/uuid"
@@ -47,11 +47,11 @@ func (cfg *ApiConfig) handlerDeleteFile(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Delete file from disk
-	err = os.Remove(dbFile.FilePath)
+	err = removeBlob(r.Context(), dbFile.FilePath)
 	if err != nil {
 		// Log the error but continue to delete from database
 		// The file might already be deleted from disk
-		println("Warning: Could not delete file from disk:", err.Error())
+		slog.WarnContext(r.Context(), "Could not delete file from disk", "file_id", dbFile.ID, "error", err)
 	}
 
 	// Delete from database
@@ -60,6 +60,7 @@ func (cfg *ApiConfig) handlerDeleteFile(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "Could not delete file from database", err)
 		return
 	}
+	cfg.checkQuota(r.Context(), userID)
 
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "File deleted successfully",
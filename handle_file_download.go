@@ -3,7 +3,7 @@ package main
 import (
 	"database/sql"
 	"net/http"
-	"os"
+	"strconv"
 
 	"io"
 
@@ -55,10 +55,13 @@ func (cfg *ApiConfig) handlerDownloadFile(w http.ResponseWriter, r *http.Request
 
 	hasAccess := false
 	wrappedKey := ""
+	keyVersion := ""
+	groupID := ""
 
 	if err == nil {
 		hasAccess = true
 		wrappedKey = accessKey.WrappedKey
+		keyVersion = strconv.Itoa(int(accessKey.KeyVersion))
 	} else if err == sql.ErrNoRows {
 		// Fallback: Check if owner (for legacy files or if key is missing)
 		if dbFile.OwnerID.Valid && dbFile.OwnerID.UUID == userID {
@@ -69,20 +72,30 @@ func (cfg *ApiConfig) handlerDownloadFile(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Files shared with a group carry the key wrapped for the group; the client
+	// unwraps it with the group private key from GET /groups
 	if !hasAccess {
-		respondWithError(w, http.StatusForbidden, "You do not have access to this file", nil)
-		return
+		groupKey, err := cfg.dbQueries.GetGroupFileKeyForUser(r.Context(), database.GetGroupFileKeyForUserParams{
+			FileID: fileID,
+			UserID: userID,
+		})
+		if err == nil {
+			hasAccess = true
+			wrappedKey = groupKey.WrappedKey
+			groupID = groupKey.GroupID.String()
+		} else if err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Error checking file access", err)
+			return
+		}
 	}
 
-	// Debug logging
-	if dbFile.EncryptedMetadata.Valid {
-		println("Metadata found:", dbFile.EncryptedMetadata.String)
-	} else {
-		println("No metadata found for file:", dbFile.ID.String())
+	if !hasAccess {
+		respondWithError(w, http.StatusForbidden, "You do not have access to this file", nil)
+		return
 	}
 
 	// Open the file from disk
-	file, err := os.Open(dbFile.FilePath)
+	file, err := cfg.openBlob(r.Context(), dbFile)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not read file from disk", err)
 		return
@@ -90,7 +103,8 @@ func (cfg *ApiConfig) handlerDownloadFile(w http.ResponseWriter, r *http.Request
 	defer file.Close()
 
 	// Set headers
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+dbFile.Filename+"\"")
+	// The real name is in the metadata, so the server never puts it on the wire
+	w.Header().Set("Content-Disposition", "attachment; filename=\"download\"")
 	w.Header().Set("Content-Type", "application/octet-stream")
 
 	// Return metadata in a custom header so the client can decrypt
@@ -102,6 +116,20 @@ func (cfg *ApiConfig) handlerDownloadFile(w http.ResponseWriter, r *http.Request
 	if wrappedKey != "" {
 		w.Header().Set("X-Wrapped-Key", wrappedKey)
 	}
+	if keyVersion != "" {
+		w.Header().Set("X-Wrapped-Key-Version", keyVersion)
+	}
+	if groupID != "" {
+		w.Header().Set("X-Wrapped-Key-Group", groupID)
+	}
+
+	signature, err := cfg.dbQueries.GetFileSignature(r.Context(), dbFile.ID)
+	if err == nil {
+		setSignatureHeaders(w, dbFile, signature)
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving file signature", err)
+		return
+	}
 
 	// Stream the file content
 	_, err = io.Copy(w, file)
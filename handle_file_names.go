@@ -0,0 +1,203 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Clients may encrypt a file's name with the file key (encryptMetadata) and send
+// it as encrypted_name: base64 of a 12-byte IV followed by the AES-GCM ciphertext.
+// The name is NUL-padded to one of encryptedNameBuckets before encrypting so the
+// blob size only reveals a rough length. The blob is kept in the file metadata
+// and the filename column holds the file ID instead of the real name. Uploads
+// must send one; older files keep their plaintext name until the client sets an
+// encrypted one.
+
+const encryptedNameOverhead = 12 + gcmTagSize
+
+var encryptedNameBuckets = []int{64, 128, 256, 512}
+
+var errInvalidEncryptedName = errors.New("invalid encrypted name")
+
+// validEncryptedName checks that a blob decodes to an IV and ciphertext of one
+// of the padded name sizes
+func validEncryptedName(blob string) bool {
+	raw, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		return false
+	}
+	for _, size := range encryptedNameBuckets {
+		if len(raw) == size+encryptedNameOverhead {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadName checks an upload's encrypted name and picks the filename column,
+// which only holds the file ID, so an ID is assigned up front when the client
+// did not choose one
+func uploadName(encryptedName string, fileID uuid.NullUUID) (string, uuid.NullUUID, error) {
+	if !validEncryptedName(encryptedName) {
+		return "", fileID, errInvalidEncryptedName
+	}
+	if !fileID.Valid {
+		fileID = uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	}
+	return fileID.UUID.String(), fileID, nil
+}
+
+// handlerSetFileName stores a new encrypted name for a file, which is how
+// clients move existing files off their plaintext name
+func (cfg *ApiConfig) handlerSetFileName(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	fileID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid file ID format", err)
+		return
+	}
+
+	type parameters struct {
+		EncryptedName string `json:"encrypted_name"`
+		Signature     string `json:"signature"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if !validEncryptedName(params.EncryptedName) {
+		respondWithError(w, http.StatusBadRequest, "encrypted_name must be a base64 IV and ciphertext of a padded name", nil)
+		return
+	}
+
+	dbFile, err := cfg.dbQueries.GetFileByID(r.Context(), fileID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "File not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving file info", err)
+		return
+	}
+
+	if !cfg.canRenameFile(r, dbFile, userID) {
+		respondWithError(w, http.StatusForbidden, "You do not have permission to rename this file", nil)
+		return
+	}
+
+	metadata := map[string]interface{}{}
+	if dbFile.EncryptedMetadata.Valid {
+		if err := json.Unmarshal([]byte(dbFile.EncryptedMetadata.String), &metadata); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error processing metadata", err)
+			return
+		}
+	}
+	metadata["encrypted_name"] = params.EncryptedName
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing metadata", err)
+		return
+	}
+
+	// The signature covers the metadata, so a signed file needs a new signature
+	// over the renamed metadata. Without one the old signature is dropped.
+	var signature database.CreateFileSignatureParams
+	if params.Signature != "" {
+		existing, err := cfg.dbQueries.GetFileSignature(r.Context(), dbFile.ID)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "File was uploaded without a signature", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving file signature", err)
+			return
+		}
+
+		signature, err = cfg.checkUploadSignature(r.Context(), userID, uuid.NullUUID{UUID: dbFile.ID, Valid: true}, dbFile.CurrentKeyVersion.Int32, existing.CiphertextSha256, string(metadataJSON), params.Signature)
+		if err != nil {
+			respondWithSignatureError(w, err)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update file name", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	dbFile, err = queries.SetFileEncryptedName(r.Context(), database.SetFileEncryptedNameParams{
+		ID:                dbFile.ID,
+		Filename:          dbFile.ID.String(),
+		EncryptedMetadata: sql.NullString{String: string(metadataJSON), Valid: true},
+		UpdatedAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update file name", err)
+		return
+	}
+
+	if err := queries.DeleteFileSignature(r.Context(), dbFile.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update file signature", err)
+		return
+	}
+	if params.Signature != "" {
+		if _, err := queries.CreateFileSignature(r.Context(), signature); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not update file signature", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update file name", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"file_id":  dbFile.ID,
+		"metadata": dbFile.EncryptedMetadata.String,
+		"signed":   params.Signature != "",
+	})
+}
+
+// canRenameFile allows the owner of a personal file, or the uploader of a team
+// file while they are still a member
+func (cfg *ApiConfig) canRenameFile(r *http.Request, file database.File, userID uuid.UUID) bool {
+	if file.OwnerID.Valid {
+		return file.OwnerID.UUID == userID
+	}
+	if !file.OrgID.Valid || !file.UploadedBy.Valid || file.UploadedBy.UUID != userID {
+		return false
+	}
+
+	member, err := cfg.dbQueries.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+		OrgID:  file.OrgID.UUID,
+		UserID: userID,
+	})
+	return err == nil && orgRoleAtLeast(member.Role, orgRoleMember)
+}
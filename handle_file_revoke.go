@@ -72,6 +72,11 @@ func (cfg *ApiConfig) handlerRevokeFileAccess(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	cfg.publishEvent(r.Context(), targetUserID, EventFileRevoked, map[string]interface{}{
+		"file_id":  fileID,
+		"owner_id": userID,
+	})
+
 	respondWithJSON(w, http.StatusOK, map[string]string{
 		"status":  "success",
 		"message": "Access revoked successfully",
@@ -38,6 +38,9 @@ func (cfg *ApiConfig) handlerShareFile(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		RecipientEmail string `json:"recipient_email"`
 		WrappedKey     string `json:"wrapped_key"`
+		// KeyVersion is the recipient key version the key was wrapped for;
+		// zero means their current key
+		KeyVersion int32 `json:"key_version"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -80,11 +83,26 @@ func (cfg *ApiConfig) handlerShareFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !cfg.checkContactKey(w, r, userID, recipient) {
+		return
+	}
+
+	keyVersion, err := cfg.recipientKeyVersion(r.Context(), recipient, params.KeyVersion)
+	if err == errRetiredKeyVersion {
+		respondWithError(w, http.StatusConflict, "Recipient has rotated their keys, fetch the current public key", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving recipient keys", err)
+		return
+	}
+
 	// Insert access key for recipient
 	_, err = cfg.dbQueries.CreateFileAccessKey(r.Context(), database.CreateFileAccessKeyParams{
 		FileID:     uuid.NullUUID{UUID: fileID, Valid: true},
 		UserID:     uuid.NullUUID{UUID: recipient.ID, Valid: true},
 		WrappedKey: params.WrappedKey,
+		KeyVersion: keyVersion,
 	})
 
 	if err != nil {
@@ -94,6 +112,12 @@ func (cfg *ApiConfig) handlerShareFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.publishEvent(r.Context(), recipient.ID, EventFileShared, map[string]interface{}{
+		"file_id":   fileID,
+		"file_name": dbFile.Filename,
+		"owner_id":  userID,
+	})
+
 	respondWithJSON(w, http.StatusOK, map[string]string{
 		"status":  "success",
 		"message": "File shared successfully",
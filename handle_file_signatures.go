@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Uploads may carry an Ed25519 signature by the uploader over the file ID,
+// version, ciphertext hash and metadata (see fileSignaturePayload). Signed
+// uploads name their own file ID so it can be part of the signature. The server
+// verifies the signature before storing the file and hands it back on download,
+// so clients can check authorship and notice a blob swapped in from another file.
+
+var (
+	errNoSigningKey         = errors.New("uploader has no signing key")
+	errInvalidFileSignature = errors.New("invalid file signature")
+)
+
+// requestedFileID reads the optional client-chosen file ID of an upload, which
+// must not be taken yet
+func (cfg *ApiConfig) requestedFileID(w http.ResponseWriter, r *http.Request, raw string) (uuid.NullUUID, bool) {
+	if raw == "" {
+		return uuid.NullUUID{}, true
+	}
+
+	fileID, err := uuid.Parse(raw)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid file_id format", err)
+		return uuid.NullUUID{}, false
+	}
+
+	_, err = cfg.dbQueries.GetFileByID(r.Context(), fileID)
+	if err == nil {
+		respondWithError(w, http.StatusConflict, "file_id is already in use", nil)
+		return uuid.NullUUID{}, false
+	}
+	if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error checking file_id", err)
+		return uuid.NullUUID{}, false
+	}
+
+	return uuid.NullUUID{UUID: fileID, Valid: true}, true
+}
+
+// checkUploadSignature verifies an upload signature with the signer's current
+// signing key and returns the row to store once the file exists
+func (cfg *ApiConfig) checkUploadSignature(ctx context.Context, signerID uuid.UUID, fileID uuid.NullUUID, version int32, ciphertextSHA256, metadata, signature string) (database.CreateFileSignatureParams, error) {
+	if !fileID.Valid {
+		return database.CreateFileSignatureParams{}, errInvalidFileSignature
+	}
+
+	signer, err := cfg.dbQueries.GetUserByID(ctx, signerID)
+	if err != nil {
+		return database.CreateFileSignatureParams{}, err
+	}
+	if !signer.SigningPublicKey.Valid {
+		return database.CreateFileSignatureParams{}, errNoSigningKey
+	}
+
+	payload := fileSignaturePayload(fileID.UUID.String(), version, ciphertextSHA256, metadata)
+	if err := verifySignature(signer.SigningPublicKey.String, payload, signature); err != nil {
+		return database.CreateFileSignatureParams{}, errInvalidFileSignature
+	}
+
+	return database.CreateFileSignatureParams{
+		FileID:           fileID.UUID,
+		SignerID:         uuid.NullUUID{UUID: signerID, Valid: true},
+		SigningPublicKey: signer.SigningPublicKey.String,
+		CiphertextSha256: ciphertextSHA256,
+		Signature:        signature,
+		CreatedAt:        time.Now().UTC(),
+	}, nil
+}
+
+// respondWithSignatureError maps checkUploadSignature errors to responses
+func respondWithSignatureError(w http.ResponseWriter, err error) {
+	switch err {
+	case errNoSigningKey:
+		respondWithError(w, http.StatusBadRequest, "Register a signing key before uploading signed files", err)
+	case errInvalidFileSignature:
+		respondWithError(w, http.StatusBadRequest, "Signature does not match the file, or file_id is missing", err)
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Error verifying file signature", err)
+	}
+}
+
+// setSignatureHeaders adds a file's signature to a download response. The
+// signing key is sent as base64 DER (the PEM body) to fit in a header.
+func setSignatureHeaders(w http.ResponseWriter, file database.File, signature database.FileSignature) {
+	w.Header().Set("X-File-Signature", signature.Signature)
+	w.Header().Set("X-File-Version", strconv.Itoa(int(file.CurrentKeyVersion.Int32)))
+	w.Header().Set("X-File-Ciphertext-SHA256", signature.CiphertextSha256)
+	if signature.SignerID.Valid {
+		w.Header().Set("X-File-Signer", signature.SignerID.UUID.String())
+	}
+	if block, _ := pem.Decode([]byte(signature.SigningPublicKey)); block != nil {
+		w.Header().Set("X-File-Signature-Key", base64.StdEncoding.EncodeToString(block.Bytes))
+	}
+}
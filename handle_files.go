@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
-	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/Pranay0205/VaultDrive/auth"
@@ -14,8 +14,19 @@ import (
 	"github.com/google/uuid"
 )
 
+// handlerCreateFiles takes a multipart upload whose metadata fields precede
+// the "file" part
 func (cfg *ApiConfig) handlerCreateFiles(w http.ResponseWriter, r *http.Request) {
+	cfg.handleFileUpload(w, r, readMultipartUpload)
+}
+
+// handlerPutFile takes the file as the raw request body with its metadata in
+// X-File-* headers
+func (cfg *ApiConfig) handlerPutFile(w http.ResponseWriter, r *http.Request) {
+	cfg.handleFileUpload(w, r, readRawUpload)
+}
 
+func (cfg *ApiConfig) handleFileUpload(w http.ResponseWriter, r *http.Request, readUpload func(*http.Request) (*uploadForm, error)) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
@@ -28,102 +39,161 @@ func (cfg *ApiConfig) handlerCreateFiles(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Parse multipart form (max 10 MB)
-	err = r.ParseMultipartForm(10 << 20)
+	if !cfg.limitUploadBody(w, r) {
+		return
+	}
+
+	form, err := readUpload(r)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Could not parse multipart form", err)
+		respondWithUploadFormError(w, err)
+		return
+	}
+
+	fileID, ok := cfg.requestedFileID(w, r, form.get("file_id"))
+	if !ok {
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
+	encryptedName := form.get("encrypted_name")
+	filename, fileID, err := uploadName(encryptedName, fileID)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Error retrieving the file", err)
+		respondWithError(w, http.StatusBadRequest, "encrypted_name is required: a base64 IV and ciphertext of a padded name", err)
 		return
 	}
-	defer file.Close()
 
-	// Create uploads directory if it doesn't exist
-	uploadDir := "uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		err = os.Mkdir(uploadDir, 0755)
+	// Extract encryption metadata
+	metadata := map[string]string{
+		"iv":             form.get("iv"),
+		"salt":           form.get("salt"),
+		"algorithm":      form.get("algorithm"),
+		"encrypted_name": encryptedName,
+	}
+
+	wrappedKey := form.get("wrapped_key")
+	if wrappedKey == "" {
+		respondWithError(w, http.StatusBadRequest, "wrapped_key is required before the file", nil)
+		return
+	}
+
+	// The owner normally wraps for their current key; key_version names the
+	// version used if a rotation happened while uploading
+	requestedVersion := 0
+	if raw := form.get("key_version"); raw != "" {
+		requestedVersion, err = strconv.Atoi(raw)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Could not create uploads directory", err)
+			respondWithError(w, http.StatusBadRequest, "key_version must be a number", err)
 			return
 		}
 	}
 
-	// Generate a unique filename to prevent overwriting
-	filename := uuid.New().String() + filepath.Ext(handler.Filename)
-	filePath := filepath.Join(uploadDir, filename)
-
-	// Create the file on the server
-	dst, err := os.Create(filePath)
+	owner, err := cfg.dbQueries.GetUserByID(r.Context(), ownerID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not create file on server", err)
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
 		return
 	}
-	defer dst.Close()
 
-	// Copy the uploaded file to the destination file
-	if _, err := io.Copy(dst, file); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not save file", err)
+	keyVersion, err := cfg.recipientKeyVersion(r.Context(), owner, int32(requestedVersion))
+	if err == errRetiredKeyVersion {
+		respondWithError(w, http.StatusConflict, "Keys have been rotated, wrap the file key for the current public key", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user keys", err)
 		return
 	}
 
-	// Extract encryption metadata
-	metadata := map[string]string{
-		"iv":        r.FormValue("iv"),
-		"salt":      r.FormValue("salt"),
-		"algorithm": r.FormValue("algorithm"),
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing metadata", err)
+		return
 	}
 
-	wrappedKey := r.FormValue("wrapped_key")
-	if wrappedKey == "" {
-		os.Remove(filePath)
-		respondWithError(w, http.StatusBadRequest, "wrapped_key is required", nil)
+	checkQuota := func(size int64) error {
+		return cfg.checkStorageAvailable(r.Context(), ownerID, size)
+	}
+	blob, ciphertextHash, ok := cfg.stageUpload(w, r, form, checkQuota, "Storage quota exceeded")
+	if !ok {
 		return
 	}
+	defer blob.discard()
 
-	metadataJSON, err := json.Marshal(metadata)
+	version := int32(1)
+	var signature database.CreateFileSignatureParams
+	if form.get("signature") != "" {
+		signature, err = cfg.checkUploadSignature(r.Context(), ownerID, fileID, version, ciphertextHash, string(metadataJSON), form.get("signature"))
+		if err != nil {
+			respondWithSignatureError(w, err)
+			return
+		}
+	}
+
+	// The rows are written together, and the blob is moved into place once
+	// they are committed
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		os.Remove(filePath)
-		respondWithError(w, http.StatusInternalServerError, "Error processing metadata", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not start transaction", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
 
-	dbfile, err := cfg.dbQueries.CreateFile(r.Context(), database.CreateFileParams{
+	dbfile, err := qtx.CreateFile(r.Context(), database.CreateFileParams{
 		OwnerID:           uuid.NullUUID{UUID: ownerID, Valid: true},
-		Filename:          handler.Filename,
-		FilePath:          filePath,
-		FileSize:          handler.Size,
+		Filename:          filename,
+		FilePath:          blob.path,
+		FileSize:          blob.size,
 		EncryptedMetadata: sql.NullString{String: string(metadataJSON), Valid: true},
-		CurrentKeyVersion: sql.NullInt32{Int32: 1, Valid: true},
+		CurrentKeyVersion: sql.NullInt32{Int32: version, Valid: true},
 		CreatedAt:         time.Now().UTC(),
 		UpdatedAt:         time.Now().UTC(),
+		ID:                fileID,
 	})
-
 	if err != nil {
-		// If DB insert fails, we should probably delete the uploaded file
-		os.Remove(filePath)
 		respondWithError(w, http.StatusInternalServerError, "Could not create file entry", err)
 		return
 	}
 
+	err = qtx.SetFileHash(r.Context(), database.SetFileHashParams{
+		FileID:    dbfile.ID,
+		Sha256:    sql.NullString{String: ciphertextHash, Valid: true},
+		CheckedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save file hash", err)
+		return
+	}
+
 	// Save the wrapped key for the owner
-	_, err = cfg.dbQueries.CreateFileAccessKey(r.Context(), database.CreateFileAccessKeyParams{
+	_, err = qtx.CreateFileAccessKey(r.Context(), database.CreateFileAccessKeyParams{
 		FileID:     uuid.NullUUID{UUID: dbfile.ID, Valid: true},
 		UserID:     uuid.NullUUID{UUID: ownerID, Valid: true},
 		WrappedKey: wrappedKey,
+		KeyVersion: keyVersion,
 	})
-
 	if err != nil {
-		// Rollback: delete file and DB entry
-		os.Remove(filePath)
-		cfg.dbQueries.DeleteFile(r.Context(), dbfile.ID)
 		respondWithError(w, http.StatusInternalServerError, "Could not save file access key", err)
 		return
 	}
 
+	if signature.Signature != "" {
+		_, err = qtx.CreateFileSignature(r.Context(), signature)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save file signature", err)
+			return
+		}
+	}
+
+	if !cfg.commitUpload(w, r, tx, blob, dbfile.ID) {
+		return
+	}
+
+	cfg.publishEvent(r.Context(), ownerID, EventUploadComplete, map[string]interface{}{
+		"file_id":   dbfile.ID,
+		"file_name": dbfile.Filename,
+		"file_size": dbfile.FileSize,
+	})
+	cfg.checkQuota(r.Context(), ownerID)
+
 	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
 		"file_name":  dbfile.Filename,
 		"file_path":  dbfile.FilePath,
@@ -132,6 +202,51 @@ func (cfg *ApiConfig) handlerCreateFiles(w http.ResponseWriter, r *http.Request)
 		"created_at": dbfile.CreatedAt,
 		"updated_at": dbfile.UpdatedAt,
 		"metadata":   dbfile.EncryptedMetadata.String,
+		"signed":     signature.Signature != "",
+		"file_size":  dbfile.FileSize,
+		"sha256":     ciphertextHash,
 	})
 
 }
+
+// commitUpload commits the upload's rows and its blob, responding with an
+// error if that fails
+func (cfg *ApiConfig) commitUpload(w http.ResponseWriter, r *http.Request, tx *sql.Tx, blob *stagedBlob, fileID uuid.UUID) bool {
+	if err := cfg.commitBlob(r.Context(), tx, blob, fileID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save file", err)
+		return false
+	}
+	return true
+}
+
+// commitBlob commits the upload's rows and then moves its blob into place.
+// If the move fails the rows are deleted again; should that fail too, the
+// reconciler removes them later. Chunked uploads store their chunks within tx
+// instead and have nothing to move.
+func (cfg *ApiConfig) commitBlob(ctx context.Context, tx *sql.Tx, blob *stagedBlob, fileID uuid.UUID) error {
+	if isChunked(blob.path) {
+		if err := storeChunks(ctx, cfg.dbQueries.WithTx(tx), blob, fileID, cfg.chunkSize); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if replication != nil {
+		if err := replication.replicate(ctx, blob.stagedPath, blobName(blob.path)); err != nil {
+			return err
+		}
+		blob.replicated = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if err := blob.commit(); err != nil {
+		if delErr := cfg.dbQueries.DeleteFile(ctx, fileID); delErr != nil {
+			slog.ErrorContext(ctx, "Could not remove rows of a failed upload", "file_id", fileID, "error", delErr)
+		}
+		return err
+	}
+	return nil
+}
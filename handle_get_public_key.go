@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"net/http"
+	"strconv"
 )
 
 func (cfg *ApiConfig) handlerGetPublicKey(w http.ResponseWriter, r *http.Request) {
@@ -22,8 +23,60 @@ func (cfg *ApiConfig) handlerGetPublicKey(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{
-		"public_key": user.PublicKey,
-		"user_id":    user.ID.String(),
+	// A key can be wrapped for the current keypair or for a previous one that
+	// is not retired yet, so each of them is offered with its scheme
+	previous, err := cfg.dbQueries.GetUserKeys(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user keys", err)
+		return
+	}
+	type wrappingKey struct {
+		KeyVersion   string `json:"key_version"`
+		KeyAlgorithm string `json:"key_algorithm"`
+		PublicKey    string `json:"public_key"`
+	}
+	keys := []wrappingKey{{strconv.Itoa(int(user.KeyVersion)), user.KeyAlgorithm, user.PublicKey}}
+	for _, key := range previous {
+		if !key.RetiredAt.Valid {
+			keys = append(keys, wrappingKey{strconv.Itoa(int(key.Version)), key.KeyAlgorithm, key.PublicKey})
+		}
+	}
+
+	// Share clients compare these with what they support and use the strongest
+	// scheme both sides have, wrapping for the key in keys that uses it
+	wrappingAlgorithms := []string{}
+	for _, algorithm := range keyWrappingPreference {
+		for _, key := range keys {
+			if key.KeyAlgorithm == algorithm {
+				wrappingAlgorithms = append(wrappingAlgorithms, algorithm)
+				break
+			}
+		}
+	}
+	signingAlgorithms := []string{}
+	var signingPublicKey *string
+	if user.SigningPublicKey.Valid {
+		signingAlgorithms = append(signingAlgorithms, signingAlgorithm)
+		signingPublicKey = &user.SigningPublicKey.String
+	}
+
+	// Compared out of band, or checked against the key log before wrapping
+	fingerprint, err := keyFingerprint(user.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error computing key fingerprint", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"public_key":  user.PublicKey,
+		"fingerprint": fingerprint,
+		"user_id":     user.ID.String(),
+		// Sent back as key_version when sharing, so a rotation in between is caught
+		"key_version":             strconv.Itoa(int(user.KeyVersion)),
+		"key_algorithm":           user.KeyAlgorithm,
+		"key_wrapping_algorithms": wrappingAlgorithms,
+		"keys":                    keys,
+		"signing_algorithms":      signingAlgorithms,
+		"signing_public_key":      signingPublicKey,
 	})
 }
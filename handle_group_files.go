@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerShareFileWithGroup shares one of the caller's files with a group they
+// belong to, with the file key wrapped for the current group public key
+func (cfg *ApiConfig) handlerShareFileWithGroup(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	fileID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid File ID format", err)
+		return
+	}
+
+	type parameters struct {
+		GroupID    uuid.UUID `json:"group_id"`
+		WrappedKey string    `json:"wrapped_key"`
+		KeyVersion int32     `json:"key_version"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.WrappedKey == "" {
+		respondWithError(w, http.StatusBadRequest, "wrapped_key is required", nil)
+		return
+	}
+
+	dbFile, err := cfg.dbQueries.GetFileByID(r.Context(), fileID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "File not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving file info", err)
+		return
+	}
+
+	if !dbFile.OwnerID.Valid || dbFile.OwnerID.UUID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only share your own files", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: params.GroupID,
+		UserID:  userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Group not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return
+	}
+
+	group, err := cfg.dbQueries.GetGroupByID(r.Context(), params.GroupID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving group", err)
+		return
+	}
+
+	if group.RotationRequired {
+		respondWithError(w, http.StatusConflict, "Group key must be rotated by an owner before sharing", nil)
+		return
+	}
+	if params.KeyVersion != group.KeyVersion {
+		respondWithError(w, http.StatusConflict, "Group key has been rotated, wrap for the current key", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.ShareFileWithGroup(r.Context(), database.ShareFileWithGroupParams{
+		GroupID:    group.ID,
+		FileID:     fileID,
+		WrappedKey: params.WrappedKey,
+		SharedBy:   uuid.NullUUID{UUID: userID, Valid: true},
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not share file", err)
+		return
+	}
+
+	members, err := cfg.dbQueries.GetGroupMembers(r.Context(), group.ID)
+	if err == nil {
+		for _, m := range members {
+			if m.UserID == userID {
+				continue
+			}
+			cfg.publishEvent(r.Context(), m.UserID, EventFileShared, map[string]interface{}{
+				"file_id":   fileID,
+				"file_name": dbFile.Filename,
+				"owner_id":  userID,
+				"group_id":  group.ID,
+			})
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "File shared with group successfully",
+	})
+}
+
+// handlerUnshareFileFromGroup removes a group's access to one of the caller's files
+func (cfg *ApiConfig) handlerUnshareFileFromGroup(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	fileID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid File ID format", err)
+		return
+	}
+
+	groupID, err := uuid.Parse(r.PathValue("group_id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid group ID format", err)
+		return
+	}
+
+	dbFile, err := cfg.dbQueries.GetFileByID(r.Context(), fileID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "File not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving file info", err)
+		return
+	}
+
+	if !dbFile.OwnerID.Valid || dbFile.OwnerID.UUID != userID {
+		respondWithError(w, http.StatusForbidden, "Only the file owner can revoke access", nil)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteGroupFileKey(r.Context(), database.DeleteGroupFileKeyParams{
+		GroupID: groupID,
+		FileID:  fileID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not revoke group access", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Group access revoked successfully",
+	})
+}
+
+// handlerListGroupFiles lists files shared with a group and their keys wrapped for the group
+func (cfg *ApiConfig) handlerListGroupFiles(w http.ResponseWriter, r *http.Request) {
+	_, group, _, ok := cfg.groupMembership(w, r)
+	if !ok {
+		return
+	}
+
+	files, err := cfg.dbQueries.GetGroupFiles(r.Context(), group.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve group files", err)
+		return
+	}
+
+	type GroupFileResponse struct {
+		ID                uuid.UUID  `json:"id"`
+		Filename          string     `json:"filename"`
+		FileSize          int64      `json:"file_size"`
+		SharedBy          *uuid.UUID `json:"shared_by"`
+		SharedAt          time.Time  `json:"shared_at"`
+		EncryptedMetadata string     `json:"encrypted_metadata"`
+		WrappedKey        string     `json:"wrapped_key"`
+		KeyVersion        int32      `json:"key_version"`
+	}
+
+	fileResponses := []GroupFileResponse{}
+	for _, f := range files {
+		var sharedBy *uuid.UUID
+		if f.SharedBy.Valid {
+			sharedBy = &f.SharedBy.UUID
+		}
+		fileResponses = append(fileResponses, GroupFileResponse{
+			ID:                f.ID,
+			Filename:          f.Filename,
+			FileSize:          f.FileSize,
+			SharedBy:          sharedBy,
+			SharedAt:          f.SharedAt,
+			EncryptedMetadata: f.EncryptedMetadata.String,
+			WrappedKey:        f.WrappedKey,
+			KeyVersion:        group.KeyVersion,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, fileResponses)
+}
@@ -0,0 +1,601 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Groups have their own RSA or X25519 keypair, generated client-side. Each member
+// holds the group private key wrapped for their own public key, and files are
+// shared once by wrapping the file key for the group public key. The server only
+// ever stores wrapped keys.
+
+const (
+	groupRoleOwner  = "owner"
+	groupRoleMember = "member"
+)
+
+type groupResponse struct {
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"name"`
+	PublicKey         string    `json:"public_key"`
+	KeyAlgorithm      string    `json:"key_algorithm"`
+	KeyVersion        int32     `json:"key_version"`
+	RotationRequired  bool      `json:"rotation_required"`
+	Role              string    `json:"role,omitempty"`
+	WrappedPrivateKey string    `json:"wrapped_private_key,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func newGroupResponse(group database.Group, member database.GroupMember) groupResponse {
+	return groupResponse{
+		ID:                group.ID,
+		Name:              group.Name,
+		PublicKey:         group.PublicKey,
+		KeyAlgorithm:      group.KeyAlgorithm,
+		KeyVersion:        group.KeyVersion,
+		RotationRequired:  group.RotationRequired,
+		Role:              member.Role,
+		WrappedPrivateKey: member.WrappedPrivateKey,
+		CreatedAt:         group.CreatedAt,
+		UpdatedAt:         group.UpdatedAt,
+	}
+}
+
+// groupKeyRotation carries a new group keypair: the public key, the new private
+// key wrapped for every remaining member, and every group file key rewrapped for
+// the new public key
+type groupKeyRotation struct {
+	PublicKey          string            `json:"public_key"`
+	WrappedPrivateKeys map[string]string `json:"wrapped_private_keys"`
+	WrappedFileKeys    map[string]string `json:"wrapped_file_keys"`
+}
+
+// handlerCreateGroup creates a group with the caller as owner. The client
+// generates the keypair and sends the private key wrapped for the caller.
+func (cfg *ApiConfig) handlerCreateGroup(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type parameters struct {
+		Name              string `json:"name"`
+		PublicKey         string `json:"public_key"`
+		WrappedPrivateKey string `json:"wrapped_private_key"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || params.PublicKey == "" || params.WrappedPrivateKey == "" {
+		respondWithError(w, http.StatusBadRequest, "name, public_key and wrapped_private_key are required", nil)
+		return
+	}
+
+	algorithm, err := publicKeyAlgorithm(params.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid group public key", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	group, err := cfg.dbQueries.CreateGroup(r.Context(), database.CreateGroupParams{
+		Name:         params.Name,
+		CreatedBy:    uuid.NullUUID{UUID: userID, Valid: true},
+		PublicKey:    params.PublicKey,
+		KeyAlgorithm: algorithm,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create group", err)
+		return
+	}
+
+	member, err := cfg.dbQueries.AddGroupMember(r.Context(), database.AddGroupMemberParams{
+		GroupID:           group.ID,
+		UserID:            userID,
+		Role:              groupRoleOwner,
+		WrappedPrivateKey: params.WrappedPrivateKey,
+		CreatedAt:         now,
+	})
+	if err != nil {
+		cfg.dbQueries.DeleteGroup(r.Context(), group.ID)
+		respondWithError(w, http.StatusInternalServerError, "Could not create group", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newGroupResponse(group, member))
+}
+
+// handlerListGroups lists the caller's groups with their wrapped group private keys
+func (cfg *ApiConfig) handlerListGroups(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	groups, err := cfg.dbQueries.GetGroupsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve groups", err)
+		return
+	}
+
+	groupResponses := []groupResponse{}
+	for _, g := range groups {
+		groupResponses = append(groupResponses, newGroupResponse(database.Group{
+			ID:               g.ID,
+			Name:             g.Name,
+			PublicKey:        g.PublicKey,
+			KeyAlgorithm:     g.KeyAlgorithm,
+			KeyVersion:       g.KeyVersion,
+			RotationRequired: g.RotationRequired,
+			CreatedAt:        g.CreatedAt,
+			UpdatedAt:        g.UpdatedAt,
+		}, database.GroupMember{
+			Role:              g.Role,
+			WrappedPrivateKey: g.WrappedPrivateKey,
+		}))
+	}
+
+	respondWithJSON(w, http.StatusOK, groupResponses)
+}
+
+// handlerGetGroup returns a group with its members and their public keys, which
+// owners need to wrap the group private key during rotation
+func (cfg *ApiConfig) handlerGetGroup(w http.ResponseWriter, r *http.Request) {
+	_, group, member, ok := cfg.groupMembership(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.dbQueries.GetGroupMembers(r.Context(), group.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve members", err)
+		return
+	}
+
+	type MemberResponse struct {
+		UserID    uuid.UUID `json:"user_id"`
+		Username  string    `json:"username"`
+		Email     string    `json:"email"`
+		Role      string    `json:"role"`
+		PublicKey string    `json:"public_key"`
+		JoinedAt  time.Time `json:"joined_at"`
+	}
+
+	memberResponses := []MemberResponse{}
+	for _, m := range members {
+		memberResponses = append(memberResponses, MemberResponse{
+			UserID:    m.UserID,
+			Username:  m.Username,
+			Email:     m.Email,
+			Role:      m.Role,
+			PublicKey: m.PublicKey,
+			JoinedAt:  m.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"group":   newGroupResponse(group, member),
+		"members": memberResponses,
+	})
+}
+
+// handlerDeleteGroup deletes a group; members lose access to files shared only with it
+func (cfg *ApiConfig) handlerDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	_, group, member, ok := cfg.groupMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if member.Role != groupRoleOwner {
+		respondWithError(w, http.StatusForbidden, "Only group owners can delete the group", nil)
+		return
+	}
+
+	err := cfg.dbQueries.DeleteGroup(r.Context(), group.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete group", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Group deleted", "group_id", group.ID)
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Group deleted successfully",
+	})
+}
+
+// handlerAddGroupMember adds a user with the group private key wrapped for them.
+// They can open every file shared with the group without any file being rewrapped.
+func (cfg *ApiConfig) handlerAddGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID, group, member, ok := cfg.groupMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if member.Role != groupRoleOwner {
+		respondWithError(w, http.StatusForbidden, "Only group owners can add members", nil)
+		return
+	}
+
+	type parameters struct {
+		Email             string `json:"email"`
+		Role              string `json:"role"`
+		WrappedPrivateKey string `json:"wrapped_private_key"`
+		KeyVersion        int32  `json:"key_version"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.Role == "" {
+		params.Role = groupRoleMember
+	}
+	if params.Role != groupRoleOwner && params.Role != groupRoleMember {
+		respondWithError(w, http.StatusBadRequest, "Role must be owner or member", nil)
+		return
+	}
+	if params.WrappedPrivateKey == "" {
+		respondWithError(w, http.StatusBadRequest, "wrapped_private_key is required", nil)
+		return
+	}
+
+	// A key wrapped before a rotation would give the new member a retired key
+	if params.KeyVersion != group.KeyVersion {
+		respondWithError(w, http.StatusConflict, "Group key has been rotated, wrap the current key", nil)
+		return
+	}
+
+	newUser, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+
+	// The group's private key is wrapped for the new member's key
+	if !cfg.checkContactKey(w, r, userID, newUser) {
+		return
+	}
+
+	_, err = cfg.dbQueries.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: group.ID,
+		UserID:  newUser.ID,
+	})
+	if err == nil {
+		respondWithError(w, http.StatusConflict, "User is already a member", nil)
+		return
+	}
+	if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return
+	}
+
+	added, err := cfg.dbQueries.AddGroupMember(r.Context(), database.AddGroupMemberParams{
+		GroupID:           group.ID,
+		UserID:            newUser.ID,
+		Role:              params.Role,
+		WrappedPrivateKey: params.WrappedPrivateKey,
+		CreatedAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not add member", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Group member added", "group_id", group.ID, "member_id", newUser.ID)
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"user_id":   added.UserID,
+		"username":  newUser.Username,
+		"email":     newUser.Email,
+		"role":      added.Role,
+		"joined_at": added.CreatedAt,
+	})
+}
+
+// handlerRemoveGroupMember removes a member. Owners removing someone must rotate
+// the group key in the same request so the removed member's copy of the private
+// key opens nothing shared afterwards. Members leaving on their own cannot be
+// trusted with the new key, so the group is flagged for an owner to rotate.
+func (cfg *ApiConfig) handlerRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID, group, member, ok := cfg.groupMembership(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	target, err := cfg.dbQueries.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: group.ID,
+		UserID:  targetID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return
+	}
+
+	if target.Role == groupRoleOwner {
+		owners, err := cfg.dbQueries.CountGroupOwners(r.Context(), group.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving members", err)
+			return
+		}
+		if owners <= 1 {
+			respondWithError(w, http.StatusConflict, "A group must keep at least one owner", nil)
+			return
+		}
+	}
+
+	if targetID == userID {
+		err = cfg.dbQueries.RemoveGroupMember(r.Context(), database.RemoveGroupMemberParams{
+			GroupID: group.ID,
+			UserID:  userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not leave group", err)
+			return
+		}
+
+		err = cfg.dbQueries.SetGroupRotationRequired(r.Context(), database.SetGroupRotationRequiredParams{
+			ID:        group.ID,
+			UpdatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not flag group key for rotation", err)
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, map[string]string{
+			"status":  "success",
+			"message": "Left group successfully",
+		})
+		return
+	}
+
+	if member.Role != groupRoleOwner {
+		respondWithError(w, http.StatusForbidden, "Only group owners can remove members", nil)
+		return
+	}
+
+	rotation := groupKeyRotation{}
+	err = json.NewDecoder(r.Body).Decode(&rotation)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Removing a member requires a group key rotation", err)
+		return
+	}
+
+	rotated, ok := cfg.rotateGroupKey(w, r, userID, group, rotation, targetID)
+	if !ok {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Group member removed", "group_id", group.ID, "member_id", targetID, "key_version", rotated.KeyVersion)
+	respondWithJSON(w, http.StatusOK, newGroupResponse(rotated, database.GroupMember{Role: member.Role}))
+}
+
+// handlerRotateGroupKey replaces the group keypair, e.g. after a member left
+func (cfg *ApiConfig) handlerRotateGroupKey(w http.ResponseWriter, r *http.Request) {
+	userID, group, member, ok := cfg.groupMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if member.Role != groupRoleOwner {
+		respondWithError(w, http.StatusForbidden, "Only group owners can rotate the group key", nil)
+		return
+	}
+
+	rotation := groupKeyRotation{}
+	err := json.NewDecoder(r.Body).Decode(&rotation)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	rotated, ok := cfg.rotateGroupKey(w, r, userID, group, rotation, uuid.Nil)
+	if !ok {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Group key rotated", "group_id", group.ID, "key_version", rotated.KeyVersion)
+	respondWithJSON(w, http.StatusOK, newGroupResponse(rotated, database.GroupMember{Role: member.Role}))
+}
+
+// rotateGroupKey installs a new group keypair in one transaction, removing
+// removedID first when set. The rotation must cover every remaining member and
+// every file shared with the group, or nothing changes.
+func (cfg *ApiConfig) rotateGroupKey(w http.ResponseWriter, r *http.Request, userID uuid.UUID, group database.Group, rotation groupKeyRotation, removedID uuid.UUID) (database.Group, bool) {
+	algorithm, err := publicKeyAlgorithm(rotation.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid group public key", err)
+		return database.Group{}, false
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate group key", err)
+		return database.Group{}, false
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	// Locks the group row, so concurrent rotations and shares wait for this one
+	rotated, err := queries.RotateGroupKey(r.Context(), database.RotateGroupKeyParams{
+		ID:           group.ID,
+		PublicKey:    rotation.PublicKey,
+		KeyAlgorithm: algorithm,
+		UpdatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate group key", err)
+		return database.Group{}, false
+	}
+
+	if removedID != uuid.Nil {
+		err = queries.RemoveGroupMember(r.Context(), database.RemoveGroupMemberParams{
+			GroupID: group.ID,
+			UserID:  removedID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not remove member", err)
+			return database.Group{}, false
+		}
+	}
+
+	members, err := queries.GetGroupMembers(r.Context(), group.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve members", err)
+		return database.Group{}, false
+	}
+	if len(rotation.WrappedPrivateKeys) != len(members) {
+		respondWithError(w, http.StatusBadRequest, "wrapped_private_keys must contain a key for every remaining member", nil)
+		return database.Group{}, false
+	}
+	memberIDs := make([]uuid.UUID, len(members))
+	for i, m := range members {
+		memberIDs[i] = m.UserID
+	}
+	if !cfg.checkContactKeys(w, r, userID, memberIDs) {
+		return database.Group{}, false
+	}
+	for _, m := range members {
+		wrapped := rotation.WrappedPrivateKeys[m.UserID.String()]
+		if wrapped == "" {
+			respondWithError(w, http.StatusBadRequest, "wrapped_private_keys must contain a key for every remaining member", nil)
+			return database.Group{}, false
+		}
+		err = queries.SetGroupMemberKey(r.Context(), database.SetGroupMemberKeyParams{
+			GroupID:           group.ID,
+			UserID:            m.UserID,
+			WrappedPrivateKey: wrapped,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save member keys", err)
+			return database.Group{}, false
+		}
+	}
+
+	fileKeys, err := queries.GetGroupFileKeys(r.Context(), group.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve group files", err)
+		return database.Group{}, false
+	}
+	if len(rotation.WrappedFileKeys) != len(fileKeys) {
+		respondWithError(w, http.StatusBadRequest, "wrapped_file_keys must contain a key for every file shared with the group", nil)
+		return database.Group{}, false
+	}
+	for _, k := range fileKeys {
+		wrapped := rotation.WrappedFileKeys[k.FileID.String()]
+		if wrapped == "" {
+			respondWithError(w, http.StatusBadRequest, "wrapped_file_keys must contain a key for every file shared with the group", nil)
+			return database.Group{}, false
+		}
+		err = queries.SetGroupFileKey(r.Context(), database.SetGroupFileKeyParams{
+			GroupID:    group.ID,
+			FileID:     k.FileID,
+			WrappedKey: wrapped,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save file keys", err)
+			return database.Group{}, false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate group key", err)
+		return database.Group{}, false
+	}
+
+	return rotated, true
+}
+
+// groupMembership returns the caller's ID, the group in the path and the caller's
+// membership. Non-members get a 404 so group IDs cannot be probed.
+func (cfg *ApiConfig) groupMembership(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Group, database.GroupMember, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return uuid.Nil, database.Group{}, database.GroupMember{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return uuid.Nil, database.Group{}, database.GroupMember{}, false
+	}
+
+	groupID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid group ID format", err)
+		return uuid.Nil, database.Group{}, database.GroupMember{}, false
+	}
+
+	member, err := cfg.dbQueries.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Group not found", err)
+		return uuid.Nil, database.Group{}, database.GroupMember{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return uuid.Nil, database.Group{}, database.GroupMember{}, false
+	}
+
+	group, err := cfg.dbQueries.GetGroupByID(r.Context(), groupID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving group", err)
+		return uuid.Nil, database.Group{}, database.GroupMember{}, false
+	}
+
+	return userID, group, member, true
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/Pranay0205/VaultDrive/internal/merkle"
+	"github.com/google/uuid"
+)
+
+// Every public key a user registers or rotates to, and every Ed25519 signing
+// key, is appended to a Merkle tree log (see internal/merkle). The server signs tree heads with its log key, and
+// hands out inclusion proofs for a user's key and consistency proofs between
+// tree sizes. Before wrapping a file key, clients check the recipient's key is
+// in the log and that the tree head they see only ever grows from the last one
+// they kept, so a substituted key shows up to the user it was aimed at.
+
+const (
+	keyLogLeafContext        = "vaultdrive-key-log-v1"
+	keyLogSigningLeafContext = "vaultdrive-key-log-signing-v1"
+	keyLogHeadContext        = "vaultdrive-key-log-head-v1"
+	safetyNumberContext      = "vaultdrive-safety-number-v1"
+	keyLogPageLimit          = 1000
+
+	keyUseEncryption = "encryption"
+	keyUseSigning    = "signing"
+)
+
+var errKeyLogSize = errors.New("tree_size is larger than the log")
+
+// keyLogCache keeps the leaf hashes read so far. The log is append-only, so
+// each request only reads the entries added since the last one.
+type keyLogCache struct {
+	mu     sync.Mutex
+	leaves [][]byte
+}
+
+type keyLogTreeHead struct {
+	TreeSize  int64  `json:"tree_size"`
+	RootHash  string `json:"root_hash"`
+	Timestamp int64  `json:"timestamp"`
+	Signature string `json:"signature"`
+}
+
+type keyLogEntryResponse struct {
+	Index        int64     `json:"index"`
+	UserID       uuid.UUID `json:"user_id"`
+	KeyUse       string    `json:"key_use"`
+	KeyVersion   int32     `json:"key_version"`
+	KeyAlgorithm string    `json:"key_algorithm"`
+	PublicKey    string    `json:"public_key"`
+	LeafHash     string    `json:"leaf_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// keyLogLeaf is the data hashed into the tree for an entry. Signing keys use
+// their own context so one can never pass for an encryption key.
+func keyLogLeaf(entry database.KeyLogEntry) []byte {
+	leafContext := keyLogLeafContext
+	if entry.KeyUse == keyUseSigning {
+		leafContext = keyLogSigningLeafContext
+	}
+
+	return []byte(strings.Join([]string{
+		leafContext,
+		entry.UserID.String(),
+		strconv.Itoa(int(entry.KeyVersion)),
+		entry.KeyAlgorithm,
+		entry.PublicKey,
+	}, "\n"))
+}
+
+// keyLogHeadPayload is what the log key signs for a tree head
+func keyLogHeadPayload(treeSize, timestamp int64, rootHash []byte) []byte {
+	return []byte(fmt.Sprintf("%s\n%d\n%d\n%s", keyLogHeadContext, treeSize, timestamp, hex.EncodeToString(rootHash)))
+}
+
+// appendKeyLog logs a user's current public key. The lock keeps indexes
+// gapless when keys are logged concurrently.
+func appendKeyLog(ctx context.Context, queries *database.Queries, user database.User) error {
+	if err := queries.LockKeyLog(ctx); err != nil {
+		return err
+	}
+
+	_, err := queries.AppendKeyLogEntry(ctx, database.AppendKeyLogEntryParams{
+		UserID:       user.ID,
+		KeyVersion:   user.KeyVersion,
+		KeyAlgorithm: user.KeyAlgorithm,
+		PublicKey:    user.PublicKey,
+		CreatedAt:    time.Now().UTC(),
+		KeyUse:       keyUseEncryption,
+	})
+	return err
+}
+
+// appendSigningKeyLog logs a user's new signing key under the next signing key
+// version
+func appendSigningKeyLog(ctx context.Context, queries *database.Queries, userID uuid.UUID, publicKey string) error {
+	if err := queries.LockKeyLog(ctx); err != nil {
+		return err
+	}
+
+	version, err := queries.NextSigningKeyVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = queries.AppendKeyLogEntry(ctx, database.AppendKeyLogEntryParams{
+		UserID:       userID,
+		KeyVersion:   version,
+		KeyAlgorithm: "Ed25519",
+		PublicKey:    publicKey,
+		CreatedAt:    time.Now().UTC(),
+		KeyUse:       keyUseSigning,
+	})
+	return err
+}
+
+// loadOrCreateKeyLogKey reads the Ed25519 key that signs tree heads, generating
+// one on first start
+func loadOrCreateKeyLogKey(keyPath string) (ed25519.PrivateKey, error) {
+	keyBytes, err := os.ReadFile(keyPath)
+	if err == nil {
+		block, _ := pem.Decode(keyBytes)
+		if block == nil {
+			return nil, errors.New("invalid key log key PEM")
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("key log key is not an Ed25519 key")
+		}
+		return edKey, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	privPEM, _, err := marshalKeyPair(privateKey, privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(keyPath, []byte(privPEM), 0600); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// keyLogLeaves returns the leaf hashes of the first treeSize entries, or of the
+// whole log when treeSize is 0
+func (cfg *ApiConfig) keyLogLeaves(ctx context.Context, treeSize int64) ([][]byte, error) {
+	count, err := cfg.dbQueries.CountKeyLogEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if treeSize > count {
+		return nil, errKeyLogSize
+	}
+	if treeSize == 0 {
+		treeSize = count
+	}
+
+	cfg.keyLog.mu.Lock()
+	defer cfg.keyLog.mu.Unlock()
+
+	if cached := int64(len(cfg.keyLog.leaves)); cached < treeSize {
+		entries, err := cfg.dbQueries.GetKeyLogEntries(ctx, database.GetKeyLogEntriesParams{
+			Idx:   cached,
+			Idx_2: count,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Idx != int64(len(cfg.keyLog.leaves)) {
+				return nil, fmt.Errorf("key log entry %d is out of order", entry.Idx)
+			}
+			cfg.keyLog.leaves = append(cfg.keyLog.leaves, merkle.LeafHash(keyLogLeaf(entry)))
+		}
+	}
+	if int64(len(cfg.keyLog.leaves)) < treeSize {
+		return nil, errKeyLogSize
+	}
+
+	// The cap stops later appends from writing into a slice a caller holds
+	return cfg.keyLog.leaves[:treeSize:treeSize], nil
+}
+
+// signTreeHead signs the root of leaves with the log key
+func (cfg *ApiConfig) signTreeHead(leaves [][]byte) keyLogTreeHead {
+	root := merkle.Root(leaves)
+	timestamp := time.Now().UnixMilli()
+	signature := ed25519.Sign(cfg.keyLogKey, keyLogHeadPayload(int64(len(leaves)), timestamp, root))
+
+	return keyLogTreeHead{
+		TreeSize:  int64(len(leaves)),
+		RootHash:  base64.StdEncoding.EncodeToString(root),
+		Timestamp: timestamp,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}
+}
+
+// treeSizeParam reads an optional tree size from the query string
+func treeSizeParam(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, true
+	}
+
+	size, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || size < 1 {
+		respondWithError(w, http.StatusBadRequest, name+" must be a positive number", err)
+		return 0, false
+	}
+	return size, true
+}
+
+// respondWithKeyLogError maps keyLogLeaves errors to responses
+func respondWithKeyLogError(w http.ResponseWriter, err error) {
+	if err == errKeyLogSize {
+		respondWithError(w, http.StatusBadRequest, "tree_size is larger than the log", err)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Error reading key log", err)
+}
+
+func encodeHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, h := range hashes {
+		encoded[i] = base64.StdEncoding.EncodeToString(h)
+	}
+	return encoded
+}
+
+// handlerGetKeyLogHead returns a signed tree head for the whole log, or for
+// its first tree_size entries
+func (cfg *ApiConfig) handlerGetKeyLogHead(w http.ResponseWriter, r *http.Request) {
+	treeSize, ok := treeSizeParam(w, r, "tree_size")
+	if !ok {
+		return
+	}
+
+	leaves, err := cfg.keyLogLeaves(r.Context(), treeSize)
+	if err != nil {
+		respondWithKeyLogError(w, err)
+		return
+	}
+
+	_, logPublicKey, err := marshalKeyPair(cfg.keyLogKey, cfg.keyLogKey.Public())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error encoding log key", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		keyLogTreeHead
+		LogPublicKey string `json:"log_public_key"`
+	}{cfg.signTreeHead(leaves), logPublicKey})
+}
+
+// handlerGetKeyLogEntries lists log entries from start up to end, for monitors
+// replaying the log
+func (cfg *ApiConfig) handlerGetKeyLogEntries(w http.ResponseWriter, r *http.Request) {
+	start, err := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+	if err != nil || start < 0 {
+		start = 0
+	}
+	end, err := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+	if err != nil || end <= start || end-start > keyLogPageLimit {
+		end = start + keyLogPageLimit
+	}
+
+	entries, err := cfg.dbQueries.GetKeyLogEntries(r.Context(), database.GetKeyLogEntriesParams{
+		Idx:   start,
+		Idx_2: end,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reading key log", err)
+		return
+	}
+
+	response := make([]keyLogEntryResponse, len(entries))
+	for i, entry := range entries {
+		response[i] = newKeyLogEntryResponse(entry)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func newKeyLogEntryResponse(entry database.KeyLogEntry) keyLogEntryResponse {
+	return keyLogEntryResponse{
+		Index:        entry.Idx,
+		UserID:       entry.UserID,
+		KeyUse:       entry.KeyUse,
+		KeyVersion:   entry.KeyVersion,
+		KeyAlgorithm: entry.KeyAlgorithm,
+		PublicKey:    entry.PublicKey,
+		LeafHash:     base64.StdEncoding.EncodeToString(merkle.LeafHash(keyLogLeaf(entry))),
+		CreatedAt:    entry.CreatedAt,
+	}
+}
+
+// handlerGetKeyLogInclusion proves a user's key version is in the log. key_use
+// picks signing keys over encryption keys.
+func (cfg *ApiConfig) handlerGetKeyLogInclusion(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	keyVersion, err := strconv.Atoi(r.URL.Query().Get("key_version"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "key_version must be a number", err)
+		return
+	}
+
+	keyUse := r.URL.Query().Get("key_use")
+	if keyUse == "" {
+		keyUse = keyUseEncryption
+	}
+	if keyUse != keyUseEncryption && keyUse != keyUseSigning {
+		respondWithError(w, http.StatusBadRequest, "key_use must be encryption or signing", nil)
+		return
+	}
+
+	treeSize, ok := treeSizeParam(w, r, "tree_size")
+	if !ok {
+		return
+	}
+
+	entry, err := cfg.dbQueries.GetKeyLogEntry(r.Context(), database.GetKeyLogEntryParams{
+		UserID:     userID,
+		KeyUse:     keyUse,
+		KeyVersion: int32(keyVersion),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Key is not in the log", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reading key log", err)
+		return
+	}
+
+	leaves, err := cfg.keyLogLeaves(r.Context(), treeSize)
+	if err != nil {
+		respondWithKeyLogError(w, err)
+		return
+	}
+
+	proof, err := merkle.InclusionProof(leaves, int(entry.Idx))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Key was logged after that tree size", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"entry":      newKeyLogEntryResponse(entry),
+		"audit_path": encodeHashes(proof),
+		"tree_head":  cfg.signTreeHead(leaves),
+	})
+}
+
+// handlerGetKeyLogConsistency proves the tree of size first is a prefix of the
+// tree of size second, or of the whole log
+func (cfg *ApiConfig) handlerGetKeyLogConsistency(w http.ResponseWriter, r *http.Request) {
+	first, ok := treeSizeParam(w, r, "first")
+	if !ok {
+		return
+	}
+	second, ok := treeSizeParam(w, r, "second")
+	if !ok {
+		return
+	}
+	if first == 0 {
+		respondWithError(w, http.StatusBadRequest, "first is required", nil)
+		return
+	}
+
+	leaves, err := cfg.keyLogLeaves(r.Context(), second)
+	if err != nil {
+		respondWithKeyLogError(w, err)
+		return
+	}
+
+	proof, err := merkle.ConsistencyProof(leaves, int(first))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "first must not be larger than second", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"first":     first,
+		"proof":     encodeHashes(proof),
+		"tree_head": cfg.signTreeHead(leaves),
+	})
+}
+
+// keyFingerprint is the SHA-256 of a public key's DER encoding, in groups of
+// four hex digits for reading aloud
+func keyFingerprint(publicKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return "", errors.New("invalid public key PEM")
+	}
+
+	digest := sha256.Sum256(block.Bytes)
+	sum := hex.EncodeToString(digest[:])
+
+	groups := make([]string, 0, len(sum)/4)
+	for i := 0; i < len(sum); i += 4 {
+		groups = append(groups, sum[i:i+4])
+	}
+	return strings.Join(groups, " "), nil
+}
+
+// safetyNumberHalf turns one user's ID and key into 30 digits
+func safetyNumberHalf(userID uuid.UUID, publicKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return "", errors.New("invalid public key PEM")
+	}
+
+	h := sha256.New()
+	h.Write([]byte(safetyNumberContext))
+	h.Write(userID[:])
+	h.Write(block.Bytes)
+	digest := h.Sum(nil)
+
+	var digits strings.Builder
+	for i := 0; i < 30; i += 5 {
+		chunk := make([]byte, 8)
+		copy(chunk[3:], digest[i:i+5])
+		fmt.Fprintf(&digits, "%05d", binary.BigEndian.Uint64(chunk)%100000)
+	}
+	return digits.String(), nil
+}
+
+// safetyNumber combines both users' halves in a fixed order, so both sides see
+// the same 60 digits
+func safetyNumber(userA uuid.UUID, keyA string, userB uuid.UUID, keyB string) (string, error) {
+	halfA, err := safetyNumberHalf(userA, keyA)
+	if err != nil {
+		return "", err
+	}
+	halfB, err := safetyNumberHalf(userB, keyB)
+	if err != nil {
+		return "", err
+	}
+
+	halves := []string{halfA, halfB}
+	sort.Strings(halves)
+	combined := halves[0] + halves[1]
+
+	groups := make([]string, 0, len(combined)/5)
+	for i := 0; i < len(combined); i += 5 {
+		groups = append(groups, combined[i:i+5])
+	}
+	return strings.Join(groups, " "), nil
+}
+
+// handlerGetSafetyNumber returns the safety number between the caller and
+// another user, for comparing in person or over another channel
+func (cfg *ApiConfig) handlerGetSafetyNumber(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	otherID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	other, err := cfg.dbQueries.GetUserByID(r.Context(), otherID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	number, err := safetyNumber(user.ID, user.PublicKey, other.ID, other.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not compute safety number", err)
+		return
+	}
+	fingerprint, err := keyFingerprint(other.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not compute fingerprint", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":       other.ID,
+		"key_version":   other.KeyVersion,
+		"fingerprint":   fingerprint,
+		"safety_number": number,
+	})
+}
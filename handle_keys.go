@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Rotating a keypair bumps users.key_version and keeps the previous keypair in
+// user_keys. Access keys record the version they were wrapped for, so shares
+// made against the old public key keep working while the client rewraps them
+// in batches. Once nothing is wrapped for an old version it is retired and its
+// encrypted private key dropped.
+//
+// The other copies of the private key are replaced in the rotation itself:
+// the client rewraps it for every approved device and optionally under the
+// recovery key. Copies the server cannot rewrap, under personal access tokens
+// and S3 access keys, are revoked, and recovery is turned off when its copy is
+// not replaced.
+
+const (
+	defaultRewrapBatchSize = 100
+	maxRewrapBatchSize     = 500
+)
+
+var (
+	errRetiredKeyVersion  = errors.New("key version has been retired")
+	errDeviceKeysMismatch = errors.New("device keys do not match the approved devices")
+)
+
+// recipientKeyVersion resolves the keypair a wrapped key was made for. Zero means
+// the current keypair; earlier versions are accepted until they are retired.
+func (cfg *ApiConfig) recipientKeyVersion(ctx context.Context, user database.User, requested int32) (sql.NullInt32, error) {
+	if requested == 0 || requested == user.KeyVersion {
+		return sql.NullInt32{Int32: user.KeyVersion, Valid: true}, nil
+	}
+
+	key, err := cfg.dbQueries.GetUserKey(ctx, database.GetUserKeyParams{
+		UserID:  user.ID,
+		Version: requested,
+	})
+	if err == sql.ErrNoRows || (err == nil && key.RetiredAt.Valid) {
+		return sql.NullInt32{}, errRetiredKeyVersion
+	}
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+
+	return sql.NullInt32{Int32: key.Version, Valid: true}, nil
+}
+
+// sessionPrivateKey returns the gateway session's private key for a key version,
+// unlocking a previous keypair with the account password when needed
+func (cfg *ApiConfig) sessionPrivateKey(ctx context.Context, session *gatewaySession, version int32) (crypto.PrivateKey, error) {
+	if version == session.user.KeyVersion {
+		return session.privateKey, nil
+	}
+	if session.password == "" {
+		return nil, errors.New("file key is wrapped for another keypair version")
+	}
+
+	encrypted := ""
+	user, err := cfg.dbQueries.GetUserByID(ctx, session.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.KeyVersion == version {
+		encrypted = user.PrivateKeyEncrypted
+	} else {
+		key, err := cfg.dbQueries.GetUserKey(ctx, database.GetUserKeyParams{
+			UserID:  session.user.ID,
+			Version: version,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !key.PrivateKeyEncrypted.Valid {
+			return nil, errRetiredKeyVersion
+		}
+		encrypted = key.PrivateKeyEncrypted.String
+	}
+
+	privateKeyPEM, err := decryptPrivateKey(encrypted, session.password)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKey(privateKeyPEM)
+}
+
+// handlerGetKeys lists the caller's keypair versions and how many access keys
+// still need rewrapping for the current one
+func (cfg *ApiConfig) handlerGetKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.approvedDeviceUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	keys, err := cfg.dbQueries.GetUserKeys(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve keys", err)
+		return
+	}
+
+	pending, err := cfg.dbQueries.CountStaleFileAccessKeys(r.Context(), database.CountStaleFileAccessKeysParams{
+		UserID:     uuid.NullUUID{UUID: userID, Valid: true},
+		KeyVersion: user.KeyVersion,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count pending rewraps", err)
+		return
+	}
+
+	// Previous private keys are never handed out again; the device that
+	// rotated still holds the old key for the rewrap
+	type KeyResponse struct {
+		Version      int32      `json:"version"`
+		KeyAlgorithm string     `json:"key_algorithm"`
+		PublicKey    string     `json:"public_key"`
+		CreatedAt    time.Time  `json:"created_at"`
+		RetiredAt    *time.Time `json:"retired_at"`
+	}
+
+	previous := []KeyResponse{}
+	for _, k := range keys {
+		key := KeyResponse{
+			Version:      k.Version,
+			KeyAlgorithm: k.KeyAlgorithm,
+			PublicKey:    k.PublicKey,
+			CreatedAt:    k.CreatedAt,
+		}
+		if k.RetiredAt.Valid {
+			key.RetiredAt = &k.RetiredAt.Time
+		}
+		previous = append(previous, key)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"key_version":        user.KeyVersion,
+		"key_algorithm":      user.KeyAlgorithm,
+		"public_key":         user.PublicKey,
+		"signing_public_key": user.SigningPublicKey.String,
+		"previous_keys":      previous,
+		"pending_rewraps":    pending,
+	})
+}
+
+// handlerRotateKeys replaces the caller's keypair with one generated on the
+// client. Access keys are then rewrapped through the batch endpoints.
+func (cfg *ApiConfig) handlerRotateKeys(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type parameters struct {
+		Password            string `json:"password"`
+		PublicKey           string `json:"public_key"`
+		PrivateKeyEncrypted string `json:"private_key_encrypted"`
+		// Device ID to the new private key wrapped for that device
+		DeviceKeys           map[uuid.UUID]string `json:"device_keys"`
+		RecoveryKeyEncrypted string               `json:"recovery_key_encrypted"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.PrivateKeyEncrypted == "" {
+		respondWithError(w, http.StatusBadRequest, "private_key_encrypted is required", nil)
+		return
+	}
+	algorithm, err := publicKeyAlgorithm(params.PublicKey)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "public_key must be an RSA or X25519 public key in PEM format", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	if err := auth.CheckPasswordHash(params.Password, user.PasswordHash); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+		return
+	}
+
+	pending, err := cfg.dbQueries.CountStaleFileAccessKeys(r.Context(), database.CountStaleFileAccessKeysParams{
+		UserID:     uuid.NullUUID{UUID: userID, Valid: true},
+		KeyVersion: user.KeyVersion,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count pending rewraps", err)
+		return
+	}
+	if pending > 0 {
+		respondWithError(w, http.StatusConflict, "Finish rewrapping access keys from the previous rotation first", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate keys", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	now := time.Now().UTC()
+	_, err = queries.CreateUserKey(r.Context(), database.CreateUserKeyParams{
+		UserID:              userID,
+		Version:             user.KeyVersion,
+		PublicKey:           user.PublicKey,
+		PrivateKeyEncrypted: sql.NullString{String: user.PrivateKeyEncrypted, Valid: true},
+		CreatedAt:           now,
+		KeyAlgorithm:        user.KeyAlgorithm,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate keys", err)
+		return
+	}
+
+	// Matching on the old version makes a concurrent rotation fail instead of
+	// silently overwriting this one
+	rotated, err := queries.RotateUserKey(r.Context(), database.RotateUserKeyParams{
+		ID:                  userID,
+		PublicKey:           params.PublicKey,
+		PrivateKeyEncrypted: params.PrivateKeyEncrypted,
+		UpdatedAt:           now,
+		KeyVersion:          user.KeyVersion,
+		KeyAlgorithm:        algorithm,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Keys were rotated by another request", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate keys", err)
+		return
+	}
+
+	if err := appendKeyLog(r.Context(), queries, rotated); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not log new public key", err)
+		return
+	}
+
+	recoveryDisabled, err := replaceKeyCopies(r.Context(), queries, userID, params.DeviceKeys, params.RecoveryKeyEncrypted, now)
+	if err == errDeviceKeysMismatch {
+		respondWithError(w, http.StatusBadRequest, "device_keys must hold the new private key wrapped for every approved device", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not replace key copies", err)
+		return
+	}
+
+	pending, err = queries.CountStaleFileAccessKeys(r.Context(), database.CountStaleFileAccessKeysParams{
+		UserID:     uuid.NullUUID{UUID: userID, Valid: true},
+		KeyVersion: rotated.KeyVersion,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count pending rewraps", err)
+		return
+	}
+	if pending == 0 {
+		err = queries.RetireUserKeys(r.Context(), database.RetireUserKeysParams{
+			UserID:    userID,
+			RetiredAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not retire previous keys", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not rotate keys", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Keys rotated", "key_version", rotated.KeyVersion)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"key_version":       rotated.KeyVersion,
+		"pending_rewraps":   pending,
+		"recovery_disabled": recoveryDisabled,
+	})
+}
+
+// replaceKeyCopies stores the rewrapped copies of a rotated private key and
+// revokes those that cannot be rewrapped. It reports whether recovery was
+// turned off because its copy was not replaced.
+func replaceKeyCopies(ctx context.Context, queries *database.Queries, userID uuid.UUID, deviceKeys map[uuid.UUID]string, recoveryKeyEncrypted string, now time.Time) (bool, error) {
+	for deviceID, wrapped := range deviceKeys {
+		if wrapped == "" {
+			return false, errDeviceKeysMismatch
+		}
+		updated, err := queries.RewrapDeviceKey(ctx, database.RewrapDeviceKeyParams{
+			ID:               deviceID,
+			UserID:           userID,
+			WrappedMasterKey: sql.NullString{String: wrapped, Valid: true},
+		})
+		if err != nil {
+			return false, err
+		}
+		if updated == 0 {
+			return false, errDeviceKeysMismatch
+		}
+	}
+	// An approved device left with the old key would restore it at login
+	approved, err := queries.CountApprovedDevices(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if approved != int64(len(deviceKeys)) {
+		return false, errDeviceKeysMismatch
+	}
+
+	err = queries.RevokePersonalAccessTokensByUser(ctx, database.RevokePersonalAccessTokensByUserParams{
+		UserID:    userID,
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := queries.DeleteGatewayS3AccessKeysByUser(ctx, userID); err != nil {
+		return false, err
+	}
+
+	if recoveryKeyEncrypted != "" {
+		_, err := queries.RewrapRecoveryKey(ctx, database.RewrapRecoveryKeyParams{
+			UserID:               userID,
+			RecoveryKeyEncrypted: recoveryKeyEncrypted,
+			UpdatedAt:            now,
+		})
+		return false, err
+	}
+	_, err = queries.GetRecoveryKey(ctx, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := queries.DeleteRecoverySharesByUser(ctx, userID); err != nil {
+		return false, err
+	}
+	return true, queries.DeleteRecoveryKey(ctx, userID)
+}
+
+// handlerGetRewrapBatch returns the next access keys still wrapped for an
+// earlier keypair. Calling it again after each submitted batch resumes the
+// migration wherever it stopped.
+func (cfg *ApiConfig) handlerGetRewrapBatch(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	limit := defaultRewrapBatchSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxRewrapBatchSize {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 500", err)
+			return
+		}
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	keys, err := cfg.dbQueries.GetStaleFileAccessKeys(r.Context(), database.GetStaleFileAccessKeysParams{
+		UserID:     uuid.NullUUID{UUID: userID, Valid: true},
+		KeyVersion: user.KeyVersion,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve access keys", err)
+		return
+	}
+
+	pending, err := cfg.dbQueries.CountStaleFileAccessKeys(r.Context(), database.CountStaleFileAccessKeysParams{
+		UserID:     uuid.NullUUID{UUID: userID, Valid: true},
+		KeyVersion: user.KeyVersion,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count pending rewraps", err)
+		return
+	}
+
+	type AccessKeyResponse struct {
+		FileID     uuid.UUID `json:"file_id"`
+		WrappedKey string    `json:"wrapped_key"`
+		KeyVersion int32     `json:"key_version"`
+	}
+
+	keyResponses := []AccessKeyResponse{}
+	for _, k := range keys {
+		keyResponses = append(keyResponses, AccessKeyResponse{
+			FileID:     k.FileID.UUID,
+			WrappedKey: k.WrappedKey,
+			KeyVersion: k.KeyVersion,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"key_version":     user.KeyVersion,
+		"keys":            keyResponses,
+		"pending_rewraps": pending,
+	})
+}
+
+// handlerSubmitRewrapBatch stores access keys rewrapped for the current keypair.
+// A key is only replaced if it is still at the version the client read, so a
+// share made meanwhile is never overwritten. The previous keypairs are retired
+// once nothing is left to rewrap.
+func (cfg *ApiConfig) handlerSubmitRewrapBatch(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type rewrappedKey struct {
+		FileID      uuid.UUID `json:"file_id"`
+		WrappedKey  string    `json:"wrapped_key"`
+		FromVersion int32     `json:"from_version"`
+	}
+
+	type parameters struct {
+		KeyVersion int32          `json:"key_version"`
+		Keys       []rewrappedKey `json:"keys"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if len(params.Keys) > maxRewrapBatchSize {
+		respondWithError(w, http.StatusBadRequest, "At most 500 keys can be rewrapped per batch", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	if params.KeyVersion != user.KeyVersion {
+		respondWithError(w, http.StatusConflict, "Keys have been rotated again, rewrap for the current key", nil)
+		return
+	}
+
+	var rewrapped int64
+	for _, k := range params.Keys {
+		if k.WrappedKey == "" || k.FromVersion >= user.KeyVersion {
+			respondWithError(w, http.StatusBadRequest, "Each key needs a wrapped_key and the earlier from_version it replaces", nil)
+			return
+		}
+		n, err := cfg.dbQueries.RewrapFileAccessKey(r.Context(), database.RewrapFileAccessKeyParams{
+			FileID:       uuid.NullUUID{UUID: k.FileID, Valid: true},
+			UserID:       uuid.NullUUID{UUID: userID, Valid: true},
+			WrappedKey:   k.WrappedKey,
+			KeyVersion:   user.KeyVersion,
+			KeyVersion_2: k.FromVersion,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save rewrapped key", err)
+			return
+		}
+		rewrapped += n
+	}
+
+	pending, err := cfg.dbQueries.CountStaleFileAccessKeys(r.Context(), database.CountStaleFileAccessKeysParams{
+		UserID:     uuid.NullUUID{UUID: userID, Valid: true},
+		KeyVersion: user.KeyVersion,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count pending rewraps", err)
+		return
+	}
+
+	if pending == 0 {
+		err = cfg.dbQueries.RetireUserKeys(r.Context(), database.RetireUserKeysParams{
+			UserID:    userID,
+			RetiredAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not retire previous keys", err)
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"rewrapped":       rewrapped,
+		"pending_rewraps": pending,
+		"complete":        pending == 0,
+	})
+}
+
+// handlerSetSigningKey registers a client-generated Ed25519 signing keypair,
+// for accounts created before signing keys existed or after a recovery
+func (cfg *ApiConfig) handlerSetSigningKey(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type parameters struct {
+		Password            string `json:"password"`
+		PublicKey           string `json:"public_key"`
+		PrivateKeyEncrypted string `json:"private_key_encrypted"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.PrivateKeyEncrypted == "" {
+		respondWithError(w, http.StatusBadRequest, "private_key_encrypted is required", nil)
+		return
+	}
+	if _, err := parseSigningPublicKey(params.PublicKey); err != nil {
+		respondWithError(w, http.StatusBadRequest, "public_key must be an Ed25519 public key in PEM format", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	if err := auth.CheckPasswordHash(params.Password, user.PasswordHash); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save signing key", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	err = queries.SetUserSigningKey(r.Context(), database.SetUserSigningKeyParams{
+		ID:                         userID,
+		SigningPublicKey:           sql.NullString{String: params.PublicKey, Valid: true},
+		SigningPrivateKeyEncrypted: sql.NullString{String: params.PrivateKeyEncrypted, Valid: true},
+		UpdatedAt:                  time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save signing key", err)
+		return
+	}
+
+	if err := appendSigningKeyLog(r.Context(), queries, userID, params.PublicKey); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not log signing key", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save signing key", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Signing key saved",
+	})
+}
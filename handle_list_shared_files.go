@@ -52,6 +52,11 @@ func (cfg *ApiConfig) handlerListSharedFiles(w http.ResponseWriter, r *http.Requ
 			continue
 		}
 
+		// Team space files are listed under their organization
+		if file.OrgID.Valid {
+			continue
+		}
+
 		// Get owner details
 		var ownerUsername string
 		if file.OwnerID.Valid {
@@ -7,21 +7,39 @@ import (
 
 	"github.com/Pranay0205/VaultDrive/auth"
 	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
 )
 
 func (cfg *ApiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
+		// Optional: an existing device ID, or a new device's name and public key
+		DeviceID        string `json:"device_id"`
+		DeviceName      string `json:"device_name"`
+		DevicePublicKey string `json:"device_public_key"`
+	}
+
+	type deviceResponse struct {
+		ID               uuid.UUID  `json:"id"`
+		Status           string     `json:"status"`
+		PairingCode      string     `json:"pairing_code,omitempty"`
+		PairingExpiresAt *time.Time `json:"pairing_expires_at,omitempty"`
+		WrappedMasterKey string     `json:"wrapped_master_key,omitempty"`
 	}
 
 	type response struct {
-		Username            string `json:"username"`
-		Email               string `json:"email"`
-		Token               string `json:"token"`
-		RefreshToken        string `json:"refresh_token"`
-		PublicKey           string `json:"public_key"`
-		PrivateKeyEncrypted string `json:"private_key_encrypted"`
+		Username            string          `json:"username"`
+		Email               string          `json:"email"`
+		Token               string          `json:"token"`
+		RefreshToken        string          `json:"refresh_token"`
+		PublicKey           string          `json:"public_key"`
+		PrivateKeyEncrypted string          `json:"private_key_encrypted"`
+		KeyAlgorithm        string          `json:"key_algorithm"`
+		SigningPublicKey    string          `json:"signing_public_key,omitempty"`
+		SigningKeyEncrypted string          `json:"signing_private_key_encrypted,omitempty"`
+		Role                string          `json:"role"`
+		Device              *deviceResponse `json:"device,omitempty"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -34,17 +52,73 @@ func (cfg *ApiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 
 	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
+		loginFailuresTotal.WithLabelValues("invalid_credentials").Inc()
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
 	err = auth.CheckPasswordHash(params.Password, user.PasswordHash)
 	if err != nil {
+		loginFailuresTotal.WithLabelValues("invalid_credentials").Inc()
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour*24*30)
+	if user.DisabledAt.Valid {
+		loginFailuresTotal.WithLabelValues("account_disabled").Inc()
+		respondWithError(w, http.StatusForbidden, "Account disabled", nil)
+		return
+	}
+
+	device, pairingCode, err := cfg.loginDevice(r.Context(), user.ID, params.DeviceID, params.DeviceName, params.DevicePublicKey)
+	if err == errUnknownDevice {
+		loginFailuresTotal.WithLabelValues("unknown_device").Inc()
+		respondWithError(w, http.StatusUnauthorized, "Unknown or revoked device", err)
+		return
+	}
+	if err == errInvalidDeviceKey {
+		respondWithError(w, http.StatusBadRequest, "device_public_key must be an RSA or X25519 public key", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't register device", err)
+		return
+	}
+
+	// The password-locked private key only goes to approved devices, or to any
+	// login while the account has no devices yet
+	privateKeyEncrypted := user.PrivateKeyEncrypted
+	signingKeyEncrypted := user.SigningPrivateKeyEncrypted.String
+	deviceID := uuid.NullUUID{}
+	var loginDevice *deviceResponse
+	if device.ID != uuid.Nil {
+		deviceID = uuid.NullUUID{UUID: device.ID, Valid: true}
+		loginDevice = &deviceResponse{
+			ID:               device.ID,
+			Status:           deviceStatus(device),
+			PairingCode:      pairingCode,
+			WrappedMasterKey: device.WrappedMasterKey.String,
+		}
+		if pairingCode != "" {
+			loginDevice.PairingExpiresAt = &device.PairingExpiresAt.Time
+		}
+		if !device.ApprovedAt.Valid {
+			privateKeyEncrypted = ""
+			signingKeyEncrypted = ""
+		}
+	} else {
+		approvedDevices, err := cfg.dbQueries.CountApprovedDevices(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check devices", err)
+			return
+		}
+		if approvedDevices > 0 {
+			privateKeyEncrypted = ""
+			signingKeyEncrypted = ""
+		}
+	}
+
+	accessToken, err := auth.MakeDeviceJWT(user.ID, user.Role, deviceID.UUID, cfg.jwtSecret, time.Hour*24*30)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
@@ -60,6 +134,7 @@ func (cfg *ApiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		UserID:    user.ID,
 		Token:     refreshToken,
 		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
+		DeviceID:  deviceID,
 	})
 
 	if err != nil {
@@ -73,6 +148,11 @@ func (cfg *ApiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		Token:               accessToken,
 		RefreshToken:        refreshToken,
 		PublicKey:           user.PublicKey,
-		PrivateKeyEncrypted: user.PrivateKeyEncrypted,
+		PrivateKeyEncrypted: privateKeyEncrypted,
+		KeyAlgorithm:        user.KeyAlgorithm,
+		SigningPublicKey:    user.SigningPublicKey.String,
+		SigningKeyEncrypted: signingKeyEncrypted,
+		Role:                user.Role,
+		Device:              loginDevice,
 	})
 }
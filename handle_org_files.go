@@ -0,0 +1,310 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerListOrganizationFiles lists the team space with the caller's wrapped key for each file
+func (cfg *ApiConfig) handlerListOrganizationFiles(w http.ResponseWriter, r *http.Request) {
+	userID, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	files, err := cfg.dbQueries.GetOrgFilesWithKeyForUser(r.Context(), database.GetOrgFilesWithKeyForUserParams{
+		OrgID:  uuid.NullUUID{UUID: member.OrgID, Valid: true},
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve organization files", err)
+		return
+	}
+
+	type OrgFileResponse struct {
+		ID                uuid.UUID  `json:"id"`
+		Filename          string     `json:"filename"`
+		FileSize          int64      `json:"file_size"`
+		UploadedBy        *uuid.UUID `json:"uploaded_by"`
+		EncryptedMetadata string     `json:"encrypted_metadata"`
+		WrappedKey        string     `json:"wrapped_key"`
+		CreatedAt         time.Time  `json:"created_at"`
+	}
+
+	fileResponses := []OrgFileResponse{}
+	for _, f := range files {
+		var uploadedBy *uuid.UUID
+		if f.UploadedBy.Valid {
+			uploadedBy = &f.UploadedBy.UUID
+		}
+		fileResponses = append(fileResponses, OrgFileResponse{
+			ID:                f.ID,
+			Filename:          f.Filename,
+			FileSize:          f.FileSize,
+			UploadedBy:        uploadedBy,
+			EncryptedMetadata: f.EncryptedMetadata.String,
+			WrappedKey:        f.WrappedKey.String,
+			CreatedAt:         f.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, fileResponses)
+}
+
+// handlerUploadOrganizationFile stores a file in the team space. The uploader
+// wraps the file key for every current member, passed as wrapped_keys: a JSON
+// object mapping member user IDs to wrapped keys.
+func (cfg *ApiConfig) handlerUploadOrganizationFile(w http.ResponseWriter, r *http.Request) {
+	userID, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if !orgRoleAtLeast(member.Role, orgRoleMember) {
+		respondWithError(w, http.StatusForbidden, "Guests cannot upload files", nil)
+		return
+	}
+
+	if !cfg.limitUploadBody(w, r) {
+		return
+	}
+
+	form, err := readMultipartUpload(r)
+	if err != nil {
+		respondWithUploadFormError(w, err)
+		return
+	}
+
+	fileID, ok := cfg.requestedFileID(w, r, form.get("file_id"))
+	if !ok {
+		return
+	}
+
+	encryptedName := form.get("encrypted_name")
+	filename, fileID, err := uploadName(encryptedName, fileID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "encrypted_name is required: a base64 IV and ciphertext of a padded name", err)
+		return
+	}
+
+	wrappedKeys := map[string]string{}
+	err = json.Unmarshal([]byte(form.get("wrapped_keys")), &wrappedKeys)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "wrapped_keys must be a JSON object of user IDs to wrapped keys", err)
+		return
+	}
+
+	members, err := cfg.dbQueries.GetOrganizationMembers(r.Context(), member.OrgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve members", err)
+		return
+	}
+
+	if len(wrappedKeys) != len(members) {
+		respondWithError(w, http.StatusBadRequest, "wrapped_keys must contain a key for every organization member", nil)
+		return
+	}
+	memberIDs := make([]uuid.UUID, len(members))
+	for i, m := range members {
+		if wrappedKeys[m.UserID.String()] == "" {
+			respondWithError(w, http.StatusBadRequest, "wrapped_keys must contain a key for every organization member", nil)
+			return
+		}
+		memberIDs[i] = m.UserID
+	}
+	if !cfg.checkContactKeys(w, r, userID, memberIDs) {
+		return
+	}
+
+	metadata := map[string]string{
+		"iv":             form.get("iv"),
+		"salt":           form.get("salt"),
+		"algorithm":      form.get("algorithm"),
+		"encrypted_name": encryptedName,
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error processing metadata", err)
+		return
+	}
+
+	checkQuota := func(size int64) error {
+		return cfg.checkOrgStorageAvailable(r.Context(), member.OrgID, size)
+	}
+	blob, ciphertextHash, ok := cfg.stageUpload(w, r, form, checkQuota, "Organization storage quota exceeded")
+	if !ok {
+		return
+	}
+	defer blob.discard()
+
+	version := int32(1)
+	var signature database.CreateFileSignatureParams
+	if form.get("signature") != "" {
+		signature, err = cfg.checkUploadSignature(r.Context(), userID, fileID, version, ciphertextHash, string(metadataJSON), form.get("signature"))
+		if err != nil {
+			respondWithSignatureError(w, err)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Member changes take the same lock. The file key must be wrapped for
+	// exactly the members there are now, or someone added during the upload
+	// would join without a key and someone removed would keep one.
+	if _, err := qtx.LockOrganization(r.Context(), member.OrgID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not start transaction", err)
+		return
+	}
+	current, err := qtx.GetOrganizationMembers(r.Context(), member.OrgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve members", err)
+		return
+	}
+	if !sameOrgMembers(current, members) {
+		respondWithError(w, http.StatusConflict, "Organization members changed during the upload, wrap the file key for every current member", nil)
+		return
+	}
+
+	dbfile, err := qtx.CreateOrgFile(r.Context(), database.CreateOrgFileParams{
+		OrgID:             uuid.NullUUID{UUID: member.OrgID, Valid: true},
+		UploadedBy:        uuid.NullUUID{UUID: userID, Valid: true},
+		Filename:          filename,
+		FilePath:          blob.path,
+		FileSize:          blob.size,
+		EncryptedMetadata: sql.NullString{String: string(metadataJSON), Valid: true},
+		CurrentKeyVersion: sql.NullInt32{Int32: version, Valid: true},
+		CreatedAt:         time.Now().UTC(),
+		UpdatedAt:         time.Now().UTC(),
+		ID:                fileID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create file entry", err)
+		return
+	}
+
+	err = qtx.SetFileHash(r.Context(), database.SetFileHashParams{
+		FileID:    dbfile.ID,
+		Sha256:    sql.NullString{String: ciphertextHash, Valid: true},
+		CheckedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save file hash", err)
+		return
+	}
+
+	for _, m := range members {
+		_, err = qtx.CreateFileAccessKey(r.Context(), database.CreateFileAccessKeyParams{
+			FileID:     uuid.NullUUID{UUID: dbfile.ID, Valid: true},
+			UserID:     uuid.NullUUID{UUID: m.UserID, Valid: true},
+			WrappedKey: wrappedKeys[m.UserID.String()],
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save file access keys", err)
+			return
+		}
+	}
+
+	if signature.Signature != "" {
+		_, err = qtx.CreateFileSignature(r.Context(), signature)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save file signature", err)
+			return
+		}
+	}
+
+	if !cfg.commitUpload(w, r, tx, blob, dbfile.ID) {
+		return
+	}
+
+	for _, m := range members {
+		cfg.publishEvent(r.Context(), m.UserID, EventUploadComplete, map[string]interface{}{
+			"file_id":   dbfile.ID,
+			"file_name": dbfile.Filename,
+			"file_size": dbfile.FileSize,
+			"org_id":    member.OrgID,
+		})
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"file_name":   dbfile.Filename,
+		"file_id":     dbfile.ID,
+		"org_id":      member.OrgID,
+		"uploaded_by": userID,
+		"created_at":  dbfile.CreatedAt,
+		"updated_at":  dbfile.UpdatedAt,
+		"metadata":    dbfile.EncryptedMetadata.String,
+		"signed":      signature.Signature != "",
+		"file_size":   dbfile.FileSize,
+		"sha256":      ciphertextHash,
+	})
+}
+
+// sameOrgMembers reports whether two member lists hold the same users
+func sameOrgMembers(a, b []database.GetOrganizationMembersRow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[uuid.UUID]bool, len(a))
+	for _, m := range a {
+		ids[m.UserID] = true
+	}
+	for _, m := range b {
+		if !ids[m.UserID] {
+			return false
+		}
+	}
+	return true
+}
+
+// handlerDeleteOrganizationFile lets the uploader or an org admin delete a team file
+func (cfg *ApiConfig) handlerDeleteOrganizationFile(w http.ResponseWriter, r *http.Request) {
+	userID, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	fileID, err := uuid.Parse(r.PathValue("file_id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid file ID format", err)
+		return
+	}
+
+	dbFile, err := cfg.dbQueries.GetFileByID(r.Context(), fileID)
+	if err == sql.ErrNoRows || (err == nil && (!dbFile.OrgID.Valid || dbFile.OrgID.UUID != member.OrgID)) {
+		respondWithError(w, http.StatusNotFound, "File not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving file info", err)
+		return
+	}
+
+	isUploader := dbFile.UploadedBy.Valid && dbFile.UploadedBy.UUID == userID
+	if !orgRoleAtLeast(member.Role, orgRoleAdmin) && !(isUploader && orgRoleAtLeast(member.Role, orgRoleMember)) {
+		respondWithError(w, http.StatusForbidden, "You do not have permission to delete this file", nil)
+		return
+	}
+
+	if err := cfg.removeFile(r.Context(), dbFile); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete file", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "File deleted successfully",
+	})
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerListOrganizationMembers lists members with their public keys, which
+// uploaders need to wrap file keys for everyone in the organization
+func (cfg *ApiConfig) handlerListOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	_, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.dbQueries.GetOrganizationMembers(r.Context(), member.OrgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve members", err)
+		return
+	}
+
+	type MemberResponse struct {
+		UserID    uuid.UUID `json:"user_id"`
+		Username  string    `json:"username"`
+		Email     string    `json:"email"`
+		Role      string    `json:"role"`
+		PublicKey string    `json:"public_key"`
+		JoinedAt  time.Time `json:"joined_at"`
+	}
+
+	memberResponses := []MemberResponse{}
+	for _, m := range members {
+		memberResponses = append(memberResponses, MemberResponse{
+			UserID:    m.UserID,
+			Username:  m.Username,
+			Email:     m.Email,
+			Role:      m.Role,
+			PublicKey: m.PublicKey,
+			JoinedAt:  m.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, memberResponses)
+}
+
+// handlerAddOrganizationMember adds a user to an organization. The inviting admin
+// unwraps every team file key client-side and rewraps it for the new member, so
+// wrapped_keys must map each organization file ID to its wrapped key.
+func (cfg *ApiConfig) handlerAddOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	userID, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Email       string            `json:"email"`
+		Role        string            `json:"role"`
+		WrappedKeys map[string]string `json:"wrapped_keys"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.Role == "" {
+		params.Role = orgRoleMember
+	}
+	if _, valid := orgRoleRank[params.Role]; !valid {
+		respondWithError(w, http.StatusBadRequest, "Role must be owner, admin, member or guest", nil)
+		return
+	}
+	if !cfg.canAssignOrgRole(w, member.Role, params.Role) {
+		return
+	}
+
+	newUser, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+
+	// Every team file key is wrapped for the new member's key
+	if !cfg.checkContactKey(w, r, userID, newUser) {
+		return
+	}
+
+	_, err = cfg.dbQueries.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+		OrgID:  member.OrgID,
+		UserID: newUser.ID,
+	})
+	if err == nil {
+		respondWithError(w, http.StatusConflict, "User is already a member", nil)
+		return
+	}
+	if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not add member", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	// Team uploads take the same lock, so no file can be added between reading
+	// the files here and the new member joining without a key for it
+	if _, err := queries.LockOrganization(r.Context(), member.OrgID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not add member", err)
+		return
+	}
+
+	files, err := queries.GetFilesByOrgID(r.Context(), uuid.NullUUID{UUID: member.OrgID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve organization files", err)
+		return
+	}
+
+	if len(params.WrappedKeys) != len(files) {
+		respondWithError(w, http.StatusBadRequest, "wrapped_keys must contain a key for every organization file", nil)
+		return
+	}
+	for _, f := range files {
+		if params.WrappedKeys[f.ID.String()] == "" {
+			respondWithError(w, http.StatusBadRequest, "wrapped_keys must contain a key for every organization file", nil)
+			return
+		}
+	}
+
+	added, err := queries.AddOrganizationMember(r.Context(), database.AddOrganizationMemberParams{
+		OrgID:     member.OrgID,
+		UserID:    newUser.ID,
+		Role:      params.Role,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not add member", err)
+		return
+	}
+
+	for _, f := range files {
+		_, err = queries.CreateFileAccessKey(r.Context(), database.CreateFileAccessKeyParams{
+			FileID:     uuid.NullUUID{UUID: f.ID, Valid: true},
+			UserID:     uuid.NullUUID{UUID: newUser.ID, Valid: true},
+			WrappedKey: params.WrappedKeys[f.ID.String()],
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save file access keys", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not add member", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Organization member added", "org_id", member.OrgID, "member_id", newUser.ID, "role", params.Role)
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"user_id":   added.UserID,
+		"username":  newUser.Username,
+		"email":     newUser.Email,
+		"role":      added.Role,
+		"joined_at": added.CreatedAt,
+	})
+}
+
+// handlerUpdateOrganizationMember changes a member's role
+func (cfg *ApiConfig) handlerUpdateOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	_, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if _, valid := orgRoleRank[params.Role]; !valid {
+		respondWithError(w, http.StatusBadRequest, "Role must be owner, admin, member or guest", nil)
+		return
+	}
+
+	target, ok := cfg.orgTargetMember(w, r, member, targetID)
+	if !ok {
+		return
+	}
+
+	if !cfg.canAssignOrgRole(w, member.Role, target.Role) || !cfg.canAssignOrgRole(w, member.Role, params.Role) {
+		return
+	}
+
+	if target.Role == orgRoleOwner && params.Role != orgRoleOwner && !cfg.hasOtherOrgOwner(w, r, member.OrgID) {
+		return
+	}
+
+	updated, err := cfg.dbQueries.UpdateOrganizationMemberRole(r.Context(), database.UpdateOrganizationMemberRoleParams{
+		OrgID:  member.OrgID,
+		UserID: targetID,
+		Role:   params.Role,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update member", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Organization member role set", "org_id", member.OrgID, "member_id", targetID, "role", params.Role)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":   updated.UserID,
+		"role":      updated.Role,
+		"joined_at": updated.CreatedAt,
+	})
+}
+
+// handlerRemoveOrganizationMember removes a member and deletes their copies of
+// the team file keys. Members may remove themselves to leave.
+func (cfg *ApiConfig) handlerRemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	userID, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format", err)
+		return
+	}
+
+	target, ok := cfg.orgTargetMember(w, r, member, targetID)
+	if !ok {
+		return
+	}
+
+	if targetID != userID && !cfg.canAssignOrgRole(w, member.Role, target.Role) {
+		return
+	}
+
+	if target.Role == orgRoleOwner && !cfg.hasOtherOrgOwner(w, r, member.OrgID) {
+		return
+	}
+
+	if err := cfg.removeOrgMember(r.Context(), member.OrgID, targetID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not remove member", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Organization member removed", "org_id", member.OrgID, "member_id", targetID)
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Member removed successfully",
+	})
+}
+
+// canAssignOrgRole reports whether actorRole may grant or manage role: admins
+// manage members and guests, only owners manage admins and owners
+func (cfg *ApiConfig) canAssignOrgRole(w http.ResponseWriter, actorRole, role string) bool {
+	if !orgRoleAtLeast(actorRole, orgRoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "Only organization admins can manage members", nil)
+		return false
+	}
+	if orgRoleAtLeast(role, orgRoleAdmin) && actorRole != orgRoleOwner {
+		respondWithError(w, http.StatusForbidden, "Only organization owners can manage admins and owners", nil)
+		return false
+	}
+	return true
+}
+
+// orgTargetMember looks up targetID's membership of the caller's organization
+func (cfg *ApiConfig) orgTargetMember(w http.ResponseWriter, r *http.Request, member database.OrganizationMember, targetID uuid.UUID) (database.OrganizationMember, bool) {
+	target, err := cfg.dbQueries.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+		OrgID:  member.OrgID,
+		UserID: targetID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Member not found", err)
+		return database.OrganizationMember{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return database.OrganizationMember{}, false
+	}
+	return target, true
+}
+
+// hasOtherOrgOwner keeps organizations from losing their last owner
+func (cfg *ApiConfig) hasOtherOrgOwner(w http.ResponseWriter, r *http.Request, orgID uuid.UUID) bool {
+	owners, err := cfg.dbQueries.CountOrganizationOwners(r.Context(), orgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving members", err)
+		return false
+	}
+	if owners <= 1 {
+		respondWithError(w, http.StatusConflict, "An organization must keep at least one owner", nil)
+		return false
+	}
+	return true
+}
+
+// removeOrgMember deletes userID's team file keys and membership. It holds
+// the organization lock so an upload in progress cannot wrap a key for them
+// afterwards.
+func (cfg *ApiConfig) removeOrgMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	if _, err := queries.LockOrganization(ctx, orgID); err != nil {
+		return err
+	}
+
+	err = queries.DeleteOrgFileAccessKeysForUser(ctx, database.DeleteOrgFileAccessKeysForUserParams{
+		OrgID:  uuid.NullUUID{UUID: orgID, Valid: true},
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = queries.RemoveOrganizationMember(ctx, database.RemoveOrganizationMemberParams{
+		OrgID:  orgID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
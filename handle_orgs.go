@@ -0,0 +1,409 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Organizations own team space files. Every member holds their own wrapped copy
+// of each file key, so the server never sees team file keys either.
+
+const (
+	orgRoleOwner  = "owner"
+	orgRoleAdmin  = "admin"
+	orgRoleMember = "member"
+	orgRoleGuest  = "guest"
+)
+
+// orgRoleRank orders organization roles; guests can only read
+var orgRoleRank = map[string]int{
+	orgRoleGuest:  0,
+	orgRoleMember: 1,
+	orgRoleAdmin:  2,
+	orgRoleOwner:  3,
+}
+
+func orgRoleAtLeast(role, min string) bool {
+	return orgRoleRank[role] >= orgRoleRank[min]
+}
+
+type orgResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	BillingEmail string    `json:"billing_email"`
+	QuotaBytes   *int64    `json:"quota_bytes"`
+	Role         string    `json:"role,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func newOrgResponse(org database.Organization, role string) orgResponse {
+	resp := orgResponse{
+		ID:           org.ID,
+		Name:         org.Name,
+		BillingEmail: org.BillingEmail,
+		Role:         role,
+		CreatedAt:    org.CreatedAt,
+		UpdatedAt:    org.UpdatedAt,
+	}
+	if org.QuotaBytes.Valid {
+		resp.QuotaBytes = &org.QuotaBytes.Int64
+	}
+	return resp
+}
+
+// handlerCreateOrganization creates an organization with the caller as its owner
+func (cfg *ApiConfig) handlerCreateOrganization(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type parameters struct {
+		Name         string `json:"name"`
+		BillingEmail string `json:"billing_email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || params.BillingEmail == "" {
+		respondWithError(w, http.StatusBadRequest, "name and billing_email are required", nil)
+		return
+	}
+
+	now := time.Now().UTC()
+	org, err := cfg.dbQueries.CreateOrganization(r.Context(), database.CreateOrganizationParams{
+		Name:         params.Name,
+		BillingEmail: params.BillingEmail,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create organization", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.AddOrganizationMember(r.Context(), database.AddOrganizationMemberParams{
+		OrgID:     org.ID,
+		UserID:    userID,
+		Role:      orgRoleOwner,
+		CreatedAt: now,
+	})
+	if err != nil {
+		cfg.dbQueries.DeleteOrganization(r.Context(), org.ID)
+		respondWithError(w, http.StatusInternalServerError, "Could not create organization", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newOrgResponse(org, orgRoleOwner))
+}
+
+// handlerListOrganizations lists the organizations the caller belongs to
+func (cfg *ApiConfig) handlerListOrganizations(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	orgs, err := cfg.dbQueries.GetOrganizationsByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve organizations", err)
+		return
+	}
+
+	orgResponses := []orgResponse{}
+	for _, o := range orgs {
+		orgResponses = append(orgResponses, newOrgResponse(database.Organization{
+			ID:           o.ID,
+			Name:         o.Name,
+			BillingEmail: o.BillingEmail,
+			QuotaBytes:   o.QuotaBytes,
+			CreatedAt:    o.CreatedAt,
+			UpdatedAt:    o.UpdatedAt,
+		}, o.Role))
+	}
+
+	respondWithJSON(w, http.StatusOK, orgResponses)
+}
+
+// handlerUpdateOrganization changes an organization's name and billing email
+func (cfg *ApiConfig) handlerUpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	_, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if !orgRoleAtLeast(member.Role, orgRoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "Only organization admins can change settings", nil)
+		return
+	}
+
+	type parameters struct {
+		Name         string `json:"name"`
+		BillingEmail string `json:"billing_email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || params.BillingEmail == "" {
+		respondWithError(w, http.StatusBadRequest, "name and billing_email are required", nil)
+		return
+	}
+
+	org, err := cfg.dbQueries.UpdateOrganization(r.Context(), database.UpdateOrganizationParams{
+		ID:           member.OrgID,
+		Name:         params.Name,
+		BillingEmail: params.BillingEmail,
+		UpdatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update organization", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newOrgResponse(org, member.Role))
+}
+
+// handlerDeleteOrganization deletes an organization and its team space files
+func (cfg *ApiConfig) handlerDeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	_, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if member.Role != orgRoleOwner {
+		respondWithError(w, http.StatusForbidden, "Only organization owners can delete the organization", nil)
+		return
+	}
+
+	files, err := cfg.dbQueries.GetFilesByOrgID(r.Context(), uuid.NullUUID{UUID: member.OrgID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve organization files", err)
+		return
+	}
+
+	for _, f := range files {
+		if err := cfg.removeFile(r.Context(), f); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not delete organization files", err)
+			return
+		}
+	}
+
+	err = cfg.dbQueries.DeleteOrganization(r.Context(), member.OrgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete organization", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Organization deleted", "org_id", member.OrgID)
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Organization deleted successfully",
+	})
+}
+
+// handlerOrganizationAdmin is the org admin view: settings, quota, members by
+// role and storage used per uploader
+func (cfg *ApiConfig) handlerOrganizationAdmin(w http.ResponseWriter, r *http.Request) {
+	_, member, ok := cfg.orgMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if !orgRoleAtLeast(member.Role, orgRoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "Only organization admins can view this page", nil)
+		return
+	}
+
+	org, err := cfg.dbQueries.GetOrganizationByID(r.Context(), member.OrgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving organization", err)
+		return
+	}
+
+	members, err := cfg.dbQueries.GetOrganizationMembers(r.Context(), member.OrgID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve members", err)
+		return
+	}
+
+	usage, err := cfg.dbQueries.GetOrganizationUsageByUploader(r.Context(), uuid.NullUUID{UUID: member.OrgID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve storage usage", err)
+		return
+	}
+
+	usernames := map[uuid.UUID]string{}
+	membersByRole := map[string]int{
+		orgRoleOwner:  0,
+		orgRoleAdmin:  0,
+		orgRoleMember: 0,
+		orgRoleGuest:  0,
+	}
+	for _, m := range members {
+		usernames[m.UserID] = m.Username
+		membersByRole[m.Role]++
+	}
+
+	// Files uploaded by deleted accounts are reported with a null uploader
+	type UploaderUsage struct {
+		UserID      *uuid.UUID `json:"user_id"`
+		Username    string     `json:"username"`
+		FileCount   int64      `json:"file_count"`
+		StorageUsed int64      `json:"storage_used"`
+	}
+
+	var totalFiles, totalBytes int64
+	uploaders := []UploaderUsage{}
+	for _, u := range usage {
+		entry := UploaderUsage{FileCount: u.FileCount, StorageUsed: u.StorageUsed}
+		if u.UploadedBy.Valid {
+			entry.UserID = &u.UploadedBy.UUID
+			entry.Username = usernames[u.UploadedBy.UUID]
+		}
+		uploaders = append(uploaders, entry)
+		totalFiles += u.FileCount
+		totalBytes += u.StorageUsed
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"organization":      newOrgResponse(org, member.Role),
+		"member_count":      len(members),
+		"members_by_role":   membersByRole,
+		"total_files":       totalFiles,
+		"storage_used":      totalBytes,
+		"usage_by_uploader": uploaders,
+	})
+}
+
+// handlerAdminSetOrgQuota sets an organization's storage quota; null removes the limit
+func (cfg *ApiConfig) handlerAdminSetOrgQuota(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	adminID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid organization ID format", err)
+		return
+	}
+
+	type parameters struct {
+		QuotaBytes *int64 `json:"quota_bytes"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	quota := sql.NullInt64{}
+	if params.QuotaBytes != nil {
+		if *params.QuotaBytes < 0 {
+			respondWithError(w, http.StatusBadRequest, "Quota cannot be negative", nil)
+			return
+		}
+		quota = sql.NullInt64{Int64: *params.QuotaBytes, Valid: true}
+	}
+
+	org, err := cfg.dbQueries.SetOrganizationQuota(r.Context(), database.SetOrganizationQuotaParams{
+		ID:         orgID,
+		QuotaBytes: quota,
+		UpdatedAt:  time.Now().UTC(),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Organization not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set quota", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Admin set organization quota", "admin_id", adminID, "org_id", orgID)
+	respondWithJSON(w, http.StatusOK, newOrgResponse(org, ""))
+}
+
+// orgMembership returns the caller's ID and their membership of the organization
+// in the path. Non-members get a 404 so organization IDs cannot be probed.
+func (cfg *ApiConfig) orgMembership(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.OrganizationMember, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return uuid.Nil, database.OrganizationMember{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return uuid.Nil, database.OrganizationMember{}, false
+	}
+
+	orgID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid organization ID format", err)
+		return uuid.Nil, database.OrganizationMember{}, false
+	}
+
+	member, err := cfg.dbQueries.GetOrganizationMember(r.Context(), database.GetOrganizationMemberParams{
+		OrgID:  orgID,
+		UserID: userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Organization not found", err)
+		return uuid.Nil, database.OrganizationMember{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving membership", err)
+		return uuid.Nil, database.OrganizationMember{}, false
+	}
+
+	return userID, member, true
+}
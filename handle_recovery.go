@@ -0,0 +1,854 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/Pranay0205/VaultDrive/internal/shamir"
+	"github.com/google/uuid"
+)
+
+// Account recovery is opt-in. A second copy of the private key is kept encrypted
+// under a random recovery key that only the user holds offline. The recovery key
+// can also be split with Shamir secret sharing among trusted contacts, each share
+// wrapped for the trustee's public key. To recover, the user opens a request with
+// a one-time public key, trustees re-wrap their shares for it, and the client
+// combines them (or uses the offline key) to decrypt the private key and re-encrypt
+// it under a new password. The server only ever checks a proof of the recovery key.
+
+const (
+	recoveryRequestTTL   = 24 * time.Hour
+	recoveryKeyBytes     = 32
+	recoveryKeyGroupSize = 4
+	recoveryProofContext = "vaultdrive-recovery-proof:"
+	recoverySecretHeader = "X-Recovery-Secret"
+)
+
+var (
+	errInvalidRecoveryThreshold = errors.New("recovery threshold must be between 2 and the number of recovery contacts")
+	errInvalidRecoveryContact   = errors.New("recovery contacts must be distinct existing users")
+)
+
+var recoveryKeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryKey returns a random recovery key both as raw bytes, which is what
+// gets split among trustees, and in the grouped form shown to the user
+func newRecoveryKey() ([]byte, string, error) {
+	raw := make([]byte, recoveryKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	return raw, formatRecoveryKey(raw), nil
+}
+
+// formatRecoveryKey renders raw key bytes as base32 in dash-separated groups
+func formatRecoveryKey(raw []byte) string {
+	encoded := recoveryKeyEncoding.EncodeToString(raw)
+
+	var groups []string
+	for len(encoded) > recoveryKeyGroupSize {
+		groups = append(groups, encoded[:recoveryKeyGroupSize])
+		encoded = encoded[recoveryKeyGroupSize:]
+	}
+	groups = append(groups, encoded)
+
+	return strings.Join(groups, "-")
+}
+
+// normalizeRecoveryKey strips separators and case so typed keys still match.
+// The normalized key is the passphrase for the recovery copy of the private key.
+func normalizeRecoveryKey(key string) string {
+	key = strings.ReplaceAll(key, "-", "")
+	key = strings.ReplaceAll(key, " ", "")
+	return strings.ToUpper(key)
+}
+
+// recoveryProof is what clients send to show they hold the recovery key; the
+// server stores only a bcrypt hash of it
+func recoveryProof(key string) string {
+	sum := sha256.Sum256([]byte(recoveryProofContext + normalizeRecoveryKey(key)))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashRecoverySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func recoveryRequestStatus(request database.RecoveryRequest) string {
+	if request.CompletedAt.Valid {
+		return "completed"
+	}
+	if time.Now().UTC().After(request.ExpiresAt) {
+		return "expired"
+	}
+	return "pending"
+}
+
+// resolveRecoveryContacts looks up trustees by email for recovery set up at registration
+func (cfg *ApiConfig) resolveRecoveryContacts(ctx context.Context, emails []string, threshold int) ([]database.User, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	if threshold < 2 || threshold > len(emails) {
+		return nil, errInvalidRecoveryThreshold
+	}
+
+	seen := map[uuid.UUID]bool{}
+	contacts := []database.User{}
+	for _, email := range emails {
+		contact, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+		if err == sql.ErrNoRows {
+			return nil, errInvalidRecoveryContact
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen[contact.ID] {
+			return nil, errInvalidRecoveryContact
+		}
+		seen[contact.ID] = true
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+// enableRecovery creates a recovery key for a freshly generated private key and
+// shares it among contacts. The formatted recovery key is returned to show once.
+func enableRecovery(ctx context.Context, queries *database.Queries, userID uuid.UUID, privateKeyPEM string, contacts []database.User, threshold int) (string, error) {
+	raw, recoveryKey, err := newRecoveryKey()
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := encryptPrivateKey(privateKeyPEM, normalizeRecoveryKey(recoveryKey))
+	if err != nil {
+		return "", err
+	}
+
+	proofHash, err := auth.HashPassword(recoveryProof(recoveryKey))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	storedThreshold := sql.NullInt32{}
+	if len(contacts) > 0 {
+		storedThreshold = sql.NullInt32{Int32: int32(threshold), Valid: true}
+	}
+
+	_, err = queries.UpsertRecoveryKey(ctx, database.UpsertRecoveryKeyParams{
+		UserID:               userID,
+		RecoveryKeyEncrypted: encrypted,
+		ProofHash:            proofHash,
+		Threshold:            storedThreshold,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(contacts) == 0 {
+		return recoveryKey, nil
+	}
+
+	shares, err := shamir.Split(raw, len(contacts), threshold)
+	if err != nil {
+		return "", err
+	}
+
+	for i, contact := range contacts {
+		wrapped, err := wrapKeyForRecipient(shares[i], contact.PublicKey)
+		if err != nil {
+			return "", err
+		}
+		_, err = queries.CreateRecoveryShare(ctx, database.CreateRecoveryShareParams{
+			UserID:         userID,
+			TrusteeID:      contact.ID,
+			EncryptedShare: wrapped,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return recoveryKey, nil
+}
+
+// handlerGetRecovery reports whether recovery is enabled and who the trustees are
+func (cfg *ApiConfig) handlerGetRecovery(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type TrusteeResponse struct {
+		UserID   uuid.UUID `json:"user_id"`
+		Username string    `json:"username"`
+		Email    string    `json:"email"`
+	}
+
+	type RecoveryResponse struct {
+		Enabled   bool              `json:"enabled"`
+		Threshold *int32            `json:"threshold"`
+		Trustees  []TrusteeResponse `json:"trustees"`
+		UpdatedAt *time.Time        `json:"updated_at"`
+	}
+
+	recoveryKey, err := cfg.dbQueries.GetRecoveryKey(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusOK, RecoveryResponse{Trustees: []TrusteeResponse{}})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery settings", err)
+		return
+	}
+
+	shares, err := cfg.dbQueries.GetRecoverySharesByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery contacts", err)
+		return
+	}
+
+	response := RecoveryResponse{
+		Enabled:   true,
+		Trustees:  []TrusteeResponse{},
+		UpdatedAt: &recoveryKey.UpdatedAt,
+	}
+	if recoveryKey.Threshold.Valid {
+		response.Threshold = &recoveryKey.Threshold.Int32
+	}
+	for _, share := range shares {
+		response.Trustees = append(response.Trustees, TrusteeResponse{
+			UserID:   share.TrusteeID,
+			Username: share.Username,
+			Email:    share.Email,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerSetRecovery enables or replaces recovery with material prepared on the
+// client: the private key encrypted under the recovery key, a proof of the key,
+// and optionally shares already wrapped for each trustee
+func (cfg *ApiConfig) handlerSetRecovery(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type shareParameters struct {
+		TrusteeID      uuid.UUID `json:"trustee_id"`
+		EncryptedShare string    `json:"encrypted_share"`
+	}
+
+	type parameters struct {
+		Password             string            `json:"password"`
+		RecoveryKeyEncrypted string            `json:"recovery_key_encrypted"`
+		RecoveryProof        string            `json:"recovery_proof"`
+		Threshold            int32             `json:"threshold"`
+		Shares               []shareParameters `json:"shares"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.RecoveryKeyEncrypted == "" || params.RecoveryProof == "" {
+		respondWithError(w, http.StatusBadRequest, "recovery_key_encrypted and recovery_proof are required", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	if err := auth.CheckPasswordHash(params.Password, user.PasswordHash); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+		return
+	}
+
+	threshold := sql.NullInt32{}
+	if len(params.Shares) > 0 {
+		if params.Threshold < 2 || int(params.Threshold) > len(params.Shares) {
+			respondWithError(w, http.StatusBadRequest, errInvalidRecoveryThreshold.Error(), nil)
+			return
+		}
+		threshold = sql.NullInt32{Int32: params.Threshold, Valid: true}
+
+		seen := map[uuid.UUID]bool{}
+		for _, share := range params.Shares {
+			if share.TrusteeID == userID || seen[share.TrusteeID] || share.EncryptedShare == "" {
+				respondWithError(w, http.StatusBadRequest, "Each share needs a different trustee other than yourself", nil)
+				return
+			}
+			seen[share.TrusteeID] = true
+
+			trustee, err := cfg.dbQueries.GetUserByID(r.Context(), share.TrusteeID)
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusNotFound, "Trustee not found", err)
+				return
+			}
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Could not retrieve trustee", err)
+				return
+			}
+			if !cfg.checkContactKey(w, r, userID, trustee) {
+				return
+			}
+		}
+	}
+
+	proofHash, err := auth.HashPassword(params.RecoveryProof)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save recovery settings", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save recovery settings", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	now := time.Now().UTC()
+	err = queries.DeleteRecoverySharesByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save recovery settings", err)
+		return
+	}
+
+	_, err = queries.UpsertRecoveryKey(r.Context(), database.UpsertRecoveryKeyParams{
+		UserID:               userID,
+		RecoveryKeyEncrypted: params.RecoveryKeyEncrypted,
+		ProofHash:            proofHash,
+		Threshold:            threshold,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save recovery settings", err)
+		return
+	}
+
+	for _, share := range params.Shares {
+		_, err = queries.CreateRecoveryShare(r.Context(), database.CreateRecoveryShareParams{
+			UserID:         userID,
+			TrusteeID:      share.TrusteeID,
+			EncryptedShare: share.EncryptedShare,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not save recovery shares", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save recovery settings", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Account recovery enabled",
+	})
+}
+
+// handlerDeleteRecovery turns recovery off and drops every trustee share
+func (cfg *ApiConfig) handlerDeleteRecovery(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteRecoverySharesByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not disable recovery", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteRecoveryKey(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not disable recovery", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Account recovery disabled",
+	})
+}
+
+// handlerCreateRecoveryRequest starts a recovery for a user who has lost their
+// password. The caller supplies a one-time public key that trustees wrap their
+// shares for, and gets back a secret for polling and completing the request.
+func (cfg *ApiConfig) handlerCreateRecoveryRequest(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email     string `json:"email"`
+		PublicKey string `json:"public_key"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if _, err := publicKeyAlgorithm(params.PublicKey); err != nil {
+		respondWithError(w, http.StatusBadRequest, "public_key must be an RSA or X25519 public key in PEM format", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user", err)
+		return
+	}
+
+	var recoveryKey database.RecoveryKey
+	if err == nil && !user.DisabledAt.Valid {
+		recoveryKey, err = cfg.dbQueries.GetRecoveryKey(r.Context(), user.ID)
+	}
+	if err == sql.ErrNoRows || user.DisabledAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Recovery is not enabled for this account", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery settings", err)
+		return
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create recovery request", err)
+		return
+	}
+	secret := hex.EncodeToString(secretBytes)
+
+	now := time.Now().UTC()
+	request, err := cfg.dbQueries.CreateRecoveryRequest(r.Context(), database.CreateRecoveryRequestParams{
+		UserID:     user.ID,
+		PublicKey:  params.PublicKey,
+		SecretHash: hashRecoverySecret(secret),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(recoveryRequestTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create recovery request", err)
+		return
+	}
+
+	shares, err := cfg.dbQueries.GetRecoverySharesByUser(r.Context(), user.ID)
+	if err == nil {
+		for _, share := range shares {
+			cfg.publishEvent(r.Context(), share.TrusteeID, EventRecoveryRequested, map[string]interface{}{
+				"request_id": request.ID,
+				"user_id":    user.ID,
+				"username":   user.Username,
+			})
+		}
+	}
+
+	slog.InfoContext(r.Context(), "Recovery request opened", "recovery_request_id", request.ID, "target_id", user.ID)
+
+	var threshold *int32
+	if recoveryKey.Threshold.Valid {
+		threshold = &recoveryKey.Threshold.Int32
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":                     request.ID,
+		"secret":                 secret,
+		"recovery_key_encrypted": recoveryKey.RecoveryKeyEncrypted,
+		"threshold":              threshold,
+		"expires_at":             request.ExpiresAt,
+	})
+}
+
+// recoveryRequest loads the request in the path and checks the secret header
+// handed out when it was opened
+func (cfg *ApiConfig) recoveryRequest(w http.ResponseWriter, r *http.Request) (database.RecoveryRequest, bool) {
+	requestID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recovery request ID format", err)
+		return database.RecoveryRequest{}, false
+	}
+
+	secret := r.Header.Get(recoverySecretHeader)
+	if secret == "" {
+		respondWithError(w, http.StatusUnauthorized, "Missing recovery secret", nil)
+		return database.RecoveryRequest{}, false
+	}
+
+	request, err := cfg.dbQueries.GetRecoveryRequest(r.Context(), requestID)
+	if err == sql.ErrNoRows || (err == nil && subtle.ConstantTimeCompare([]byte(hashRecoverySecret(secret)), []byte(request.SecretHash)) != 1) {
+		respondWithError(w, http.StatusNotFound, "Recovery request not found", err)
+		return database.RecoveryRequest{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery request", err)
+		return database.RecoveryRequest{}, false
+	}
+
+	return request, true
+}
+
+// handlerGetRecoveryRequest returns the shares trustees have approved so far,
+// each wrapped for the request's one-time public key
+func (cfg *ApiConfig) handlerGetRecoveryRequest(w http.ResponseWriter, r *http.Request) {
+	request, ok := cfg.recoveryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	approvals, err := cfg.dbQueries.GetRecoveryApprovals(r.Context(), request.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve approvals", err)
+		return
+	}
+
+	type ApprovalResponse struct {
+		TrusteeID      uuid.UUID `json:"trustee_id"`
+		EncryptedShare string    `json:"encrypted_share"`
+		ApprovedAt     time.Time `json:"approved_at"`
+	}
+
+	approvalResponses := []ApprovalResponse{}
+	for _, approval := range approvals {
+		approvalResponses = append(approvalResponses, ApprovalResponse{
+			TrusteeID:      approval.TrusteeID,
+			EncryptedShare: approval.EncryptedShare,
+			ApprovedAt:     approval.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"id":         request.ID,
+		"status":     recoveryRequestStatus(request),
+		"expires_at": request.ExpiresAt,
+		"approvals":  approvalResponses,
+	})
+}
+
+// handlerCompleteRecovery sets a new password once the client proves it has
+// reconstructed the recovery key. The private key arrives already re-encrypted
+// under the new password, so the server never handles it in plaintext.
+func (cfg *ApiConfig) handlerCompleteRecovery(w http.ResponseWriter, r *http.Request) {
+	request, ok := cfg.recoveryRequest(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		RecoveryProof       string `json:"recovery_proof"`
+		NewPassword         string `json:"new_password"`
+		PrivateKeyEncrypted string `json:"private_key_encrypted"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.NewPassword == "" || params.PrivateKeyEncrypted == "" {
+		respondWithError(w, http.StatusBadRequest, "new_password and private_key_encrypted are required", nil)
+		return
+	}
+
+	if status := recoveryRequestStatus(request); status != "pending" {
+		respondWithError(w, http.StatusConflict, "Recovery request is "+status, nil)
+		return
+	}
+
+	recoveryKey, err := cfg.dbQueries.GetRecoveryKey(r.Context(), request.UserID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Recovery has been disabled for this account", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery settings", err)
+		return
+	}
+
+	if err := auth.CheckPasswordHash(params.RecoveryProof, recoveryKey.ProofHash); err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid recovery proof", err)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.NewPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set new password", err)
+		return
+	}
+
+	// The password, key, request and sessions change together, so a failure
+	// cannot leave the request spent with the old password still in place
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not complete recovery request", err)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	// Only one completion can claim the request
+	now := time.Now().UTC()
+	completed, err := queries.CompleteRecoveryRequest(r.Context(), database.CompleteRecoveryRequestParams{
+		ID:          request.ID,
+		CompletedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not complete recovery request", err)
+		return
+	}
+	if completed == 0 {
+		respondWithError(w, http.StatusConflict, "Recovery request is completed", nil)
+		return
+	}
+
+	err = queries.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+		ID:                  request.UserID,
+		PasswordHash:        hashedPassword,
+		PrivateKeyEncrypted: params.PrivateKeyEncrypted,
+		UpdatedAt:           now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set new password", err)
+		return
+	}
+
+	// The signing key was locked with the old password and cannot be recovered;
+	// the client registers a new one with PUT /me/signing-key
+	err = queries.SetUserSigningKey(r.Context(), database.SetUserSigningKeyParams{
+		ID:        request.UserID,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not reset signing key", err)
+		return
+	}
+
+	// Anyone holding the old password's sessions is signed out
+	if err := signOutUser(r.Context(), queries, request.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not sign out existing sessions", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not complete recovery request", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Recovery request completed", "recovery_request_id", request.ID, "target_id", request.UserID)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Account recovered, sign in with your new password",
+	})
+}
+
+// handlerListRecoveryRequests lists open requests from users the caller holds a
+// share for, with the caller's share to unwrap and re-wrap
+func (cfg *ApiConfig) handlerListRecoveryRequests(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	requests, err := cfg.dbQueries.GetPendingRecoveryRequestsForTrustee(r.Context(), database.GetPendingRecoveryRequestsForTrusteeParams{
+		TrusteeID: userID,
+		ExpiresAt: time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery requests", err)
+		return
+	}
+
+	type RecoveryRequestResponse struct {
+		ID             uuid.UUID `json:"id"`
+		UserID         uuid.UUID `json:"user_id"`
+		Username       string    `json:"username"`
+		Email          string    `json:"email"`
+		PublicKey      string    `json:"public_key"`
+		EncryptedShare string    `json:"encrypted_share"`
+		CreatedAt      time.Time `json:"created_at"`
+		ExpiresAt      time.Time `json:"expires_at"`
+	}
+
+	requestResponses := []RecoveryRequestResponse{}
+	for _, request := range requests {
+		requestResponses = append(requestResponses, RecoveryRequestResponse{
+			ID:             request.ID,
+			UserID:         request.UserID,
+			Username:       request.Username,
+			Email:          request.Email,
+			PublicKey:      request.PublicKey,
+			EncryptedShare: request.EncryptedShare,
+			CreatedAt:      request.CreatedAt,
+			ExpiresAt:      request.ExpiresAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, requestResponses)
+}
+
+// handlerApproveRecoveryRequest records a trustee's share, re-wrapped on their
+// client for the request's one-time public key
+func (cfg *ApiConfig) handlerApproveRecoveryRequest(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	requestID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid recovery request ID format", err)
+		return
+	}
+
+	type parameters struct {
+		EncryptedShare string `json:"encrypted_share"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.EncryptedShare == "" {
+		respondWithError(w, http.StatusBadRequest, "encrypted_share is required", nil)
+		return
+	}
+
+	request, err := cfg.dbQueries.GetRecoveryRequest(r.Context(), requestID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Recovery request not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery request", err)
+		return
+	}
+
+	shares, err := cfg.dbQueries.GetRecoverySharesByUser(r.Context(), request.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve recovery contacts", err)
+		return
+	}
+
+	isTrustee := false
+	for _, share := range shares {
+		if share.TrusteeID == userID {
+			isTrustee = true
+		}
+	}
+	if !isTrustee {
+		respondWithError(w, http.StatusNotFound, "Recovery request not found", nil)
+		return
+	}
+
+	if status := recoveryRequestStatus(request); status != "pending" {
+		respondWithError(w, http.StatusConflict, "Recovery request is "+status, nil)
+		return
+	}
+
+	approvals, err := cfg.dbQueries.GetRecoveryApprovals(r.Context(), request.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve approvals", err)
+		return
+	}
+	for _, approval := range approvals {
+		if approval.TrusteeID == userID {
+			respondWithError(w, http.StatusConflict, "You have already approved this request", nil)
+			return
+		}
+	}
+
+	_, err = cfg.dbQueries.CreateRecoveryApproval(r.Context(), database.CreateRecoveryApprovalParams{
+		RequestID:      request.ID,
+		TrusteeID:      userID,
+		EncryptedShare: params.EncryptedShare,
+		CreatedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not approve recovery request", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Recovery request approved", "recovery_request_id", request.ID)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Recovery request approved",
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Pranay0205/VaultDrive/internal/shamir"
+)
+
+func TestRecoveryKeyFromShares(t *testing.T) {
+	raw, recoveryKey, err := newRecoveryKey()
+	if err != nil {
+		t.Fatalf("Failed to create recovery key: %v", err)
+	}
+
+	encrypted, err := encryptPrivateKey("private key", normalizeRecoveryKey(recoveryKey))
+	if err != nil {
+		t.Fatalf("Failed to encrypt private key: %v", err)
+	}
+
+	shares, err := shamir.Split(raw, 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to split recovery key: %v", err)
+	}
+	combined, err := shamir.Combine([][]byte{shares[2], shares[0]})
+	if err != nil {
+		t.Fatalf("Failed to combine shares: %v", err)
+	}
+
+	// Trustees rebuild the raw bytes; a typed key may lose its case and dashes
+	for _, key := range []string{formatRecoveryKey(combined), strings.ToLower(strings.ReplaceAll(recoveryKey, "-", " "))} {
+		if recoveryProof(key) != recoveryProof(recoveryKey) {
+			t.Errorf("Proof for %q does not match the recovery key", key)
+		}
+		decrypted, err := decryptPrivateKey(encrypted, normalizeRecoveryKey(key))
+		if err != nil || decrypted != "private key" {
+			t.Errorf("Could not decrypt private key with %q: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+const s3AccessKeyPrefix = "VD"
+
+// loadOrCreateS3GatewayKey reads the key that gateway-encryption access keys
+// wrap private keys under, creating it on first start. It lives outside the
+// database, which holds the SigV4 secrets in the clear.
+func loadOrCreateS3GatewayKey(keyPath string) ([]byte, error) {
+	key, err := os.ReadFile(keyPath)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, errors.New("S3 gateway key must be 32 bytes")
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// s3PrivateKeyPassword is what an access key's private key copy is encrypted
+// with: the secret bound to the gateway key, so neither opens it alone
+func (cfg *ApiConfig) s3PrivateKeyPassword(secretKey string) string {
+	mac := hmac.New(sha256.New, cfg.s3GatewayKey)
+	mac.Write([]byte(secretKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handlerCreateS3AccessKey issues an access key pair for the S3 gateway. With
+// gateway_encryption the gateway encrypts and decrypts objects for the user, which
+// needs the account password once to re-encrypt the private key under the secret
+// and the gateway key.
+// Otherwise objects are stored exactly as uploaded and clients encrypt themselves.
+func (cfg *ApiConfig) handlerCreateS3AccessKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.approvedDeviceUser(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		GatewayEncryption bool   `json:"gateway_encryption"`
+		Password          string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	idBytes := make([]byte, 11)
+	secretBytes := make([]byte, 30)
+	if _, err := rand.Read(idBytes); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access key", err)
+		return
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access key", err)
+		return
+	}
+	accessKeyID := s3AccessKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(idBytes)
+	secretKey := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	privateKeyEncrypted := sql.NullString{}
+	if params.GatewayEncryption {
+		if params.Password == "" {
+			respondWithError(w, http.StatusBadRequest, "Password is required for gateway encryption", nil)
+			return
+		}
+
+		user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+			return
+		}
+
+		err = auth.CheckPasswordHash(params.Password, user.PasswordHash)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+			return
+		}
+
+		privateKeyPEM, err := decryptPrivateKey(user.PrivateKeyEncrypted, params.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not unlock private key", err)
+			return
+		}
+
+		encryptedPrivKey, err := encryptPrivateKey(privateKeyPEM, cfg.s3PrivateKeyPassword(secretKey))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error securing user keys", err)
+			return
+		}
+		privateKeyEncrypted = sql.NullString{String: encryptedPrivKey, Valid: true}
+	}
+
+	key, err := cfg.dbQueries.CreateS3AccessKey(r.Context(), database.CreateS3AccessKeyParams{
+		UserID:              userID,
+		AccessKeyID:         accessKeyID,
+		SecretKey:           secretKey,
+		PrivateKeyEncrypted: privateKeyEncrypted,
+		CreatedAt:           time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save access key", err)
+		return
+	}
+
+	// The secret is only ever returned here
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":                 key.ID,
+		"access_key_id":      key.AccessKeyID,
+		"secret_access_key":  secretKey,
+		"gateway_encryption": key.PrivateKeyEncrypted.Valid,
+		"created_at":         key.CreatedAt,
+	})
+}
+
+func (cfg *ApiConfig) handlerListS3AccessKeys(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	keys, err := cfg.dbQueries.GetS3AccessKeysByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve access keys", err)
+		return
+	}
+
+	type S3KeyResponse struct {
+		ID                uuid.UUID  `json:"id"`
+		AccessKeyID       string     `json:"access_key_id"`
+		GatewayEncryption bool       `json:"gateway_encryption"`
+		CreatedAt         time.Time  `json:"created_at"`
+		LastUsedAt        *time.Time `json:"last_used_at"`
+	}
+
+	keyResponses := []S3KeyResponse{}
+	for _, k := range keys {
+		var lastUsedAt *time.Time
+		if k.LastUsedAt.Valid {
+			lastUsedAt = &k.LastUsedAt.Time
+		}
+		keyResponses = append(keyResponses, S3KeyResponse{
+			ID:                k.ID,
+			AccessKeyID:       k.AccessKeyID,
+			GatewayEncryption: k.PrivateKeyEncrypted.Valid,
+			CreatedAt:         k.CreatedAt,
+			LastUsedAt:        lastUsedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, keyResponses)
+}
+
+func (cfg *ApiConfig) handlerDeleteS3AccessKey(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	keyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid access key ID format", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteS3AccessKey(r.Context(), database.DeleteS3AccessKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete access key", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Access key deleted successfully",
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// handlerAddSSHKey registers an SSH public key for the SFTP gateway
+func (cfg *ApiConfig) handlerAddSSHKey(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	type parameters struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"public_key"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	publicKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(params.PublicKey))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid SSH public key", err)
+		return
+	}
+
+	name := params.Name
+	if name == "" {
+		name = comment
+	}
+
+	sshKey, err := cfg.dbQueries.CreateSSHKey(r.Context(), database.CreateSSHKeyParams{
+		UserID:      userID,
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save SSH key (already registered?)", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":          sshKey.ID,
+		"name":        sshKey.Name,
+		"fingerprint": sshKey.Fingerprint,
+		"created_at":  sshKey.CreatedAt,
+	})
+}
+
+func (cfg *ApiConfig) handlerListSSHKeys(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	sshKeys, err := cfg.dbQueries.GetSSHKeysByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve SSH keys", err)
+		return
+	}
+
+	type SSHKeyResponse struct {
+		ID          uuid.UUID  `json:"id"`
+		Name        string     `json:"name"`
+		PublicKey   string     `json:"public_key"`
+		Fingerprint string     `json:"fingerprint"`
+		CreatedAt   time.Time  `json:"created_at"`
+		LastUsedAt  *time.Time `json:"last_used_at"`
+	}
+
+	keyResponses := []SSHKeyResponse{}
+	for _, k := range sshKeys {
+		var lastUsedAt *time.Time
+		if k.LastUsedAt.Valid {
+			lastUsedAt = &k.LastUsedAt.Time
+		}
+		keyResponses = append(keyResponses, SSHKeyResponse{
+			ID:          k.ID,
+			Name:        k.Name,
+			PublicKey:   k.PublicKey,
+			Fingerprint: k.Fingerprint,
+			CreatedAt:   k.CreatedAt,
+			LastUsedAt:  lastUsedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, keyResponses)
+}
+
+func (cfg *ApiConfig) handlerDeleteSSHKey(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	keyID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid SSH key ID format", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteSSHKey(r.Context(), database.DeleteSSHKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete SSH key", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "SSH key deleted successfully",
+	})
+}
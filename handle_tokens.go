@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerCreateAccessToken issues a personal access token for the WebDAV gateway.
+// The account password is needed once to re-encrypt the private key under the token.
+func (cfg *ApiConfig) handlerCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.approvedDeviceUser(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		return
+	}
+
+	if params.Name == "" || params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Name and password are required", nil)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(params.Password, user.PasswordHash)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+		return
+	}
+
+	privateKeyPEM, err := decryptPrivateKey(user.PrivateKeyEncrypted, params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not unlock private key", err)
+		return
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access token", err)
+		return
+	}
+	accessToken := personalAccessTokenPrefix + secret
+
+	encryptedPrivKey, err := encryptPrivateKey(privateKeyPEM, accessToken)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error securing user keys", err)
+		return
+	}
+
+	pat, err := cfg.dbQueries.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:              userID,
+		Name:                params.Name,
+		TokenHash:           hashSecret(accessToken),
+		PrivateKeyEncrypted: encryptedPrivKey,
+		CreatedAt:           time.Now().UTC(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save access token", err)
+		return
+	}
+
+	// The token itself is only ever returned here
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":         pat.ID,
+		"name":       pat.Name,
+		"token":      accessToken,
+		"created_at": pat.CreatedAt,
+	})
+}
+
+func (cfg *ApiConfig) handlerListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	tokens, err := cfg.dbQueries.GetPersonalAccessTokensByUser(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve access tokens", err)
+		return
+	}
+
+	type TokenResponse struct {
+		ID         uuid.UUID  `json:"id"`
+		Name       string     `json:"name"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+		Revoked    bool       `json:"revoked"`
+	}
+
+	tokenResponses := []TokenResponse{}
+	for _, t := range tokens {
+		var lastUsedAt *time.Time
+		if t.LastUsedAt.Valid {
+			lastUsedAt = &t.LastUsedAt.Time
+		}
+		tokenResponses = append(tokenResponses, TokenResponse{
+			ID:         t.ID,
+			Name:       t.Name,
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: lastUsedAt,
+			Revoked:    t.RevokedAt.Valid,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, tokenResponses)
+}
+
+func (cfg *ApiConfig) handlerRevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID format", err)
+		return
+	}
+
+	err = cfg.dbQueries.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:        tokenID,
+		UserID:    userID,
+		RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not revoke access token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "Access token revoked successfully",
+	})
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -27,6 +31,12 @@ func (cfg *ApiConfig) registerUserHandler(w http.ResponseWriter, r *http.Request
 		Username  string `json:"username"`
 		Email     string `json:"email"`
 		Password  string `json:"password"`
+		// KeyAlgorithm picks the keypair type: RSA-OAEP-256 (default) or X25519
+		KeyAlgorithm string `json:"key_algorithm"`
+
+		EnableRecovery    bool     `json:"enable_recovery"`
+		RecoveryContacts  []string `json:"recovery_contacts"`
+		RecoveryThreshold int      `json:"recovery_threshold"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&newUser)
@@ -35,48 +45,154 @@ func (cfg *ApiConfig) registerUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Recovery contacts must be existing users, checked before the account is made
+	contacts, err := cfg.resolveRecoveryContacts(r.Context(), newUser.RecoveryContacts, newUser.RecoveryThreshold)
+	if err == errInvalidRecoveryThreshold || err == errInvalidRecoveryContact {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resolving recovery contacts", "error", err)
+		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(newUser.Password)
 	if err != nil {
-		log.Printf("Error hashing password: %v", err)
+		slog.ErrorContext(r.Context(), "Error hashing password", "error", err)
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate RSA Keys
-	privKeyPEM, pubKeyPEM, err := generateRSAKeys()
+	keys, err := newAccountKeys(newUser.KeyAlgorithm, newUser.Password)
+	if err == errUnknownKeyAlgorithm {
+		http.Error(w, "key_algorithm must be RSA-OAEP-256 or X25519", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		log.Printf("Error generating keys: %v", err)
+		slog.ErrorContext(r.Context(), "Error generating keys", "error", err)
 		http.Error(w, "Error creating user keys", http.StatusInternalServerError)
 		return
 	}
 
-	// Encrypt Private Key
-	encryptedPrivKey, err := encryptPrivateKey(privKeyPEM, newUser.Password)
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	queries := cfg.dbQueries.WithTx(tx)
+
+	user, err := createUserWithKeys(r.Context(), queries, database.CreateUserParams{
+		FirstName:    newUser.FirstName,
+		LastName:     newUser.LastName,
+		Username:     newUser.Username,
+		Email:        newUser.Email,
+		PasswordHash: hashedPassword,
+	}, keys)
 	if err != nil {
-		log.Printf("Error encrypting private key: %v", err)
-		http.Error(w, "Error securing user keys", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error creating user in DB", "error", err)
+		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
 
-	user, err := cfg.dbQueries.CreateUser(context.Background(), database.CreateUserParams{
-		FirstName:           newUser.FirstName,
-		LastName:            newUser.LastName,
-		Username:            newUser.Username,
-		Email:               newUser.Email,
-		PasswordHash:        hashedPassword,
-		PublicKey:           pubKeyPEM,
-		PrivateKeyEncrypted: encryptedPrivKey,
-		CreatedAt:           time.Now(),
-		UpdatedAt:           time.Now(),
-	})
+	// The recovery key is shown once here and never stored in the clear
+	recoveryKey := ""
+	if newUser.EnableRecovery || len(contacts) > 0 {
+		recoveryKey, err = enableRecovery(r.Context(), queries, user.ID, keys.privateKeyPEM, contacts, newUser.RecoveryThreshold)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error setting up recovery", "error", err)
+			http.Error(w, "Error setting up account recovery", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	if err != nil {
-		log.Printf("Error creating user in DB: %v", err)
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating user in DB", "error", err)
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, user)
+	respondWithJSON(w, http.StatusCreated, struct {
+		database.User
+		RecoveryKey string `json:"recovery_key,omitempty"`
+	}{user, recoveryKey})
+}
+
+var errUnknownKeyAlgorithm = errors.New("unknown key algorithm")
+
+// accountKeys are the keypairs of a new account. The private keys are locked
+// with the account password; privateKeyPEM stays in memory for recovery setup.
+type accountKeys struct {
+	algorithm                  string
+	publicKey                  string
+	privateKeyPEM              string
+	privateKeyEncrypted        string
+	signingPublicKey           string
+	signingPrivateKeyEncrypted string
+}
+
+// newAccountKeys generates the encryption keypair for algorithm (RSA-OAEP-256
+// when empty) and an Ed25519 signing keypair, both locked with password
+func newAccountKeys(algorithm, password string) (accountKeys, error) {
+	keys := accountKeys{algorithm: algorithm}
+
+	var err error
+	switch algorithm {
+	case "", keyWrapAlgorithm:
+		keys.algorithm = keyWrapAlgorithm
+		keys.privateKeyPEM, keys.publicKey, err = generateRSAKeys()
+	case x25519Algorithm:
+		keys.privateKeyPEM, keys.publicKey, err = generateX25519Keys()
+	default:
+		return accountKeys{}, errUnknownKeyAlgorithm
+	}
+	if err != nil {
+		return accountKeys{}, err
+	}
+
+	keys.privateKeyEncrypted, err = encryptPrivateKey(keys.privateKeyPEM, password)
+	if err != nil {
+		return accountKeys{}, err
+	}
+
+	signingPrivPEM, signingPubPEM, err := generateSigningKeys()
+	if err != nil {
+		return accountKeys{}, err
+	}
+	keys.signingPublicKey = signingPubPEM
+	keys.signingPrivateKeyEncrypted, err = encryptPrivateKey(signingPrivPEM, password)
+	if err != nil {
+		return accountKeys{}, err
+	}
+	return keys, nil
+}
+
+// createUserWithKeys inserts a user with keys and logs both public keys.
+// Registration and `vaultdrive admin create` share it so accounts come out
+// the same either way.
+func createUserWithKeys(ctx context.Context, queries *database.Queries, params database.CreateUserParams, keys accountKeys) (database.User, error) {
+	now := time.Now()
+	params.PublicKey = keys.publicKey
+	params.PrivateKeyEncrypted = keys.privateKeyEncrypted
+	params.KeyAlgorithm = keys.algorithm
+	params.SigningPublicKey = sql.NullString{String: keys.signingPublicKey, Valid: true}
+	params.SigningPrivateKeyEncrypted = sql.NullString{String: keys.signingPrivateKeyEncrypted, Valid: true}
+	params.CreatedAt = now
+	params.UpdatedAt = now
+
+	user, err := queries.CreateUser(ctx, params)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := appendKeyLog(ctx, queries, user); err != nil {
+		return database.User{}, err
+	}
+	if err := appendSigningKeyLog(ctx, queries, user.ID, keys.signingPublicKey); err != nil {
+		return database.User{}, err
+	}
+	return user, nil
 }
 
 func generateRSAKeys() (string, string, error) {
@@ -106,6 +222,39 @@ func generateRSAKeys() (string, string, error) {
 	return string(privPEM), string(pubPEM), nil
 }
 
+// generateX25519Keys returns a PKCS#8 private key and PKIX public key, both PEM
+func generateX25519Keys() (string, string, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return marshalKeyPair(key, key.PublicKey())
+}
+
+// generateSigningKeys returns an Ed25519 keypair in the same PEM encodings
+func generateSigningKeys() (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return marshalKeyPair(priv, pub)
+}
+
+func marshalKeyPair(priv, pub any) (string, string, error) {
+	privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", "", err
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", "", err
+	}
+
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
+	return string(privPEM), string(pubPEM), nil
+}
+
 func encryptPrivateKey(privateKeyPEM, password string) (string, error) {
 	// 1. Generate a random salt (16 bytes)
 	salt := make([]byte, 16)
@@ -144,3 +293,40 @@ func encryptPrivateKey(privateKeyPEM, password string) (string, error) {
 	// 7. Base64 encode
 	return base64.StdEncoding.EncodeToString(finalData), nil
 }
+
+func decryptPrivateKey(encryptedPrivateKey, password string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encryptedPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Layout matches encryptPrivateKey: salt (16 bytes) + nonce + ciphertext
+	if len(data) < 16 {
+		return "", errors.New("encrypted private key is too short")
+	}
+	salt, ciphertext := data[:16:16], data[16:]
+
+	keyHash := sha256.Sum256(append(salt, []byte(password)...))
+
+	block, err := aes.NewCipher(keyHash[:])
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return "", errors.New("encrypted private key is too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	privateKeyPEM, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(privateKeyPEM), nil
+}
@@ -1,11 +1,33 @@
 package main
 
 import (
-	"context"
-	"log"
+	"database/sql"
+	"errors"
 	"net/http"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
 )
 
+// publicUser is what the unauthenticated lookups may reveal about an account
+type publicUser struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	PublicKey    string    `json:"public_key"`
+	KeyVersion   int32     `json:"key_version"`
+	KeyAlgorithm string    `json:"key_algorithm"`
+}
+
+func newPublicUser(user database.User) publicUser {
+	return publicUser{
+		ID:           user.ID,
+		Username:     user.Username,
+		PublicKey:    user.PublicKey,
+		KeyVersion:   user.KeyVersion,
+		KeyAlgorithm: user.KeyAlgorithm,
+	}
+}
+
 func (cfg *ApiConfig) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.URL.Query().Get("username")
 
@@ -14,29 +36,35 @@ func (cfg *ApiConfig) getUserByUsernameHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	user, err := cfg.dbQueries.GetUserByUsername(context.Background(), username)
+	user, err := cfg.dbQueries.GetUserByUsername(r.Context(), username)
 	if err != nil {
-		log.Printf("Error retrieving user: %v", err)
+		respondWithUserLookupError(w, err)
 		return
 	}
 
-	log.Printf("Retrieved user: %+v", user)
-
-	respondWithJSON(w, http.StatusAccepted, user)
+	respondWithJSON(w, http.StatusAccepted, newPublicUser(user))
 }
 
 func (cfg *ApiConfig) getUserByEmailHandler(w http.ResponseWriter, r *http.Request) {
 	email := r.URL.Query().Get("email")
 
 	if email == "" {
-		http.Error(w, "Username is required", http.StatusBadRequest)
+		http.Error(w, "Email is required", http.StatusBadRequest)
 		return
 	}
-	user, err := cfg.dbQueries.GetUserByEmail(context.Background(), email)
+	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), email)
 	if err != nil {
-		log.Printf("Error retrieving user: %v", err)
+		respondWithUserLookupError(w, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusAccepted, user)
+	respondWithJSON(w, http.StatusAccepted, newPublicUser(user))
+}
+
+func respondWithUserLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
 }
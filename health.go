@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// /livez only says the process is serving. /readyz also checks the database,
+// the uploads directory and the schema version, and returns 503 when any of
+// them fails so orchestrators stop routing to the replica. Both answer with a
+// bare status unless ?verbose is given, which lists every check for operators.
+
+const healthCheckTimeout = 2 * time.Second
+
+const (
+	checkOK   = "ok"
+	checkFail = "fail"
+)
+
+//go:embed sql/schema/*.sql
+var schemaMigrations embed.FS
+
+// latestSchemaVersion returns the highest goose version among the migrations
+func latestSchemaVersion(migrations fs.FS) (int64, error) {
+	files, err := fs.Glob(migrations, "sql/schema/*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has no version prefix", file)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+type healthCheck struct {
+	Name      string         `json:"name"`
+	Status    string         `json:"status"`
+	LatencyMs float64        `json:"latency_ms"`
+	Message   string         `json:"message,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// checkDatabase pings the connection pool
+func (cfg *ApiConfig) checkDatabase(ctx context.Context) (map[string]any, error) {
+	if err := cfg.db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+	stats := cfg.db.Stats()
+	return map[string]any{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+	}, nil
+}
+
+// checkSchema compares the applied goose version with the migrations this
+// build was compiled with. The goose table is not part of the sqlc schema, so
+// it is queried directly.
+func (cfg *ApiConfig) checkSchema(ctx context.Context) (map[string]any, error) {
+	expected, err := latestSchemaVersion(schemaMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied int64
+	err = cfg.db.QueryRowContext(ctx,
+		"SELECT version_id FROM goose_db_version WHERE is_applied ORDER BY id DESC LIMIT 1").Scan(&applied)
+	if err != nil {
+		return nil, fmt.Errorf("reading migration version: %w", err)
+	}
+
+	details := map[string]any{"applied": applied, "expected": expected}
+	if applied < expected {
+		return details, fmt.Errorf("schema is at version %d, this build needs %d", applied, expected)
+	}
+	return details, nil
+}
+
+// checkBlobStore writes and removes a probe file in the uploads directory and
+// checks the free space of its volume
+func (cfg *ApiConfig) checkBlobStore(ctx context.Context) (map[string]any, error) {
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return nil, err
+	}
+
+	probe, err := os.CreateTemp(uploadDir, ".readyz-*")
+	if err != nil {
+		return nil, fmt.Errorf("uploads directory is not writable: %w", err)
+	}
+	_, err = probe.Write([]byte("ok"))
+	if err == nil {
+		err = probe.Sync()
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	if err != nil {
+		return nil, fmt.Errorf("uploads directory is not writable: %w", err)
+	}
+
+	free, err := diskFreeBytes(uploadDir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return map[string]any{"free_bytes": nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]any{"free_bytes": free, "min_free_bytes": cfg.minFreeBytes}
+	if free < uint64(cfg.minFreeBytes) {
+		return details, fmt.Errorf("only %d bytes free on the uploads volume", free)
+	}
+	return details, nil
+}
+
+// runHealthChecks runs the checks concurrently, each with its own timeout
+func runHealthChecks(ctx context.Context, checks map[string]func(context.Context) (map[string]any, error)) []healthCheck {
+	results := make([]healthCheck, 0, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := check(ctx)
+			result := healthCheck{
+				Name:      name,
+				Status:    checkOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = checkFail
+				result.Message = err.Error()
+			}
+
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// Keep the verbose output stable between calls
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+func respondWithHealth(w http.ResponseWriter, r *http.Request, checks []healthCheck) {
+	status := checkOK
+	code := http.StatusOK
+	for _, c := range checks {
+		if c.Status != checkOK {
+			status = checkFail
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !r.URL.Query().Has("verbose") {
+		respondWithJSON(w, code, map[string]string{"status": status})
+		return
+	}
+	respondWithJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+func (cfg *ApiConfig) handlerLivez(w http.ResponseWriter, r *http.Request) {
+	respondWithHealth(w, r, []healthCheck{})
+}
+
+func (cfg *ApiConfig) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := runHealthChecks(r.Context(), map[string]func(context.Context) (map[string]any, error){
+		"database":   cfg.checkDatabase,
+		"schema":     cfg.checkSchema,
+		"blob_store": cfg.checkBlobStore,
+	})
+	respondWithHealth(w, r, checks)
+}
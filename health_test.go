@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLatestSchemaVersion(t *testing.T) {
+	migrations := fstest.MapFS{
+		"sql/schema/001_users.sql":  {},
+		"sql/schema/010_events.sql": {},
+		"sql/schema/002_files.sql":  {},
+	}
+	version, err := latestSchemaVersion(migrations)
+	if err != nil || version != 10 {
+		t.Errorf("latestSchemaVersion() = %d, %v, want 10", version, err)
+	}
+
+	migrations["sql/schema/notes.sql"] = &fstest.MapFile{}
+	if _, err := latestSchemaVersion(migrations); err == nil {
+		t.Error("latestSchemaVersion() accepted a migration without a version")
+	}
+
+	version, err = latestSchemaVersion(schemaMigrations)
+	if err != nil || version == 0 {
+		t.Errorf("latestSchemaVersion(embedded) = %d, %v", version, err)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// Every upload records the SHA-256 of its blob. The scrubber re-hashes blobs in
+// the background so a failing disk shows up before someone downloads from it,
+// checking each file once per interval and reading at most a configured number
+// of bytes per second. `vaultdrive fsck` runs the same check over every file at
+// full speed, and can quarantine bad blobs or restore them from a replica.
+
+const (
+	integrityOK          = "ok"
+	integrityMissing     = "missing"
+	integrityTruncated   = "truncated"
+	integrityCorrupted   = "corrupted"
+	integrityQuarantined = "quarantined"
+)
+
+const (
+	scrubBatchSize = 100
+	// scrubIdleWait is how long the scrubber sleeps once no file is due
+	scrubIdleWait = 10 * time.Minute
+)
+
+// Quarantined blobs are kept for inspection; their rows stay so the files can
+// still be restored, and the reconciler leaves them alone
+var quarantineDir = filepath.Join(uploadDir, ".quarantine")
+
+// throttledReader reads at most rate bytes per second on average
+type throttledReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > t.rate {
+		p = p[:t.rate]
+	}
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	wait := time.Duration(float64(t.read)/float64(t.rate)*float64(time.Second)) - time.Since(t.start)
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	return n, err
+}
+
+// hashBlob returns the hex SHA-256 and size of the blob at path. A positive
+// rate limits reading to that many bytes per second.
+func hashBlob(ctx context.Context, path string, rate int64) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	return hashReader(ctx, file, rate)
+}
+
+func hashReader(ctx context.Context, src io.Reader, rate int64) (string, int64, error) {
+	if rate > 0 {
+		src = &throttledReader{ctx: ctx, r: src, rate: rate, start: time.Now()}
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, src)
+	scrubbedBytesTotal.Add(float64(size))
+	if err != nil {
+		return "", size, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// blobCheck is the outcome of checking one blob. sha256 is the hash of a blob
+// that passed, and is empty otherwise.
+type blobCheck struct {
+	status string
+	sha256 string
+}
+
+// checkBlob compares the blob at path with the size and hash recorded for it.
+// Without a recorded hash a blob of the right size passes, and its hash is
+// returned to be recorded.
+func checkBlob(ctx context.Context, path string, size int64, expected string, rate int64) (blobCheck, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return blobCheck{status: integrityMissing}, nil
+	}
+	if err != nil {
+		return blobCheck{}, err
+	}
+	if info.Size() < size {
+		return blobCheck{status: integrityTruncated}, nil
+	}
+
+	sum, n, err := hashBlob(ctx, path, rate)
+	if ctx.Err() != nil {
+		return blobCheck{}, ctx.Err()
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return blobCheck{status: integrityMissing}, nil
+	case err != nil:
+		// A blob that cannot be read back is as lost as one that reads wrong
+		slog.WarnContext(ctx, "Could not read blob", "path", path, "error", err)
+		return blobCheck{status: integrityCorrupted}, nil
+	case n < size:
+		return blobCheck{status: integrityTruncated}, nil
+	case n != size || (expected != "" && sum != expected):
+		return blobCheck{status: integrityCorrupted}, nil
+	}
+	return blobCheck{status: integrityOK, sha256: sum}, nil
+}
+
+// checkChunkedBlob compares the chunks of a file, read in order, with the
+// size and hash recorded for it
+func checkChunkedBlob(ctx context.Context, queries *database.Queries, id uuid.UUID, size int64, expected string, rate int64) (blobCheck, error) {
+	file, err := openChunkedFile(ctx, queries, id)
+	if err != nil {
+		return blobCheck{}, err
+	}
+	defer file.Close()
+	if file.size < size {
+		return blobCheck{status: integrityTruncated}, nil
+	}
+
+	sum, n, err := hashReader(ctx, file, rate)
+	if ctx.Err() != nil {
+		return blobCheck{}, ctx.Err()
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return blobCheck{status: integrityMissing}, nil
+	case err != nil:
+		slog.WarnContext(ctx, "Could not read chunks", "file_id", id, "error", err)
+		return blobCheck{status: integrityCorrupted}, nil
+	case n != size || (expected != "" && sum != expected):
+		return blobCheck{status: integrityCorrupted}, nil
+	}
+	return blobCheck{status: integrityOK, sha256: sum}, nil
+}
+
+// checkFile checks the blob of a file and records the result
+func checkFile(ctx context.Context, queries *database.Queries, id uuid.UUID, path string, size int64, expected sql.NullString, rate int64) (blobCheck, error) {
+	check, err := checkBlob(ctx, path, size, expected.String, rate)
+	if isChunked(path) {
+		check, err = checkChunkedBlob(ctx, queries, id, size, expected.String, rate)
+	}
+	if err != nil {
+		return check, err
+	}
+	if check.status != integrityOK {
+		blobIntegrityFailuresTotal.WithLabelValues(check.status).Inc()
+	}
+
+	err = queries.RecordFileCheck(ctx, database.RecordFileCheckParams{
+		FileID:    id,
+		Sha256:    sql.NullString{String: check.sha256, Valid: check.sha256 != ""},
+		Status:    check.status,
+		CheckedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return check, fmt.Errorf("recording check of file %s: %w", id, err)
+	}
+	return check, nil
+}
+
+// runScrubber checks the files not checked within interval, reading at most
+// rate bytes per second, until ctx is done
+func (cfg *ApiConfig) runScrubber(ctx context.Context, rate int64, interval time.Duration) {
+	for {
+		files, err := cfg.dbQueries.GetFilesDueForCheck(ctx, database.GetFilesDueForCheckParams{
+			CheckedAt: time.Now().UTC().Add(-interval),
+			Limit:     scrubBatchSize,
+		})
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Could not list files to scrub", "error", err)
+		}
+
+		for _, f := range files {
+			check, err := checkFile(ctx, cfg.dbQueries, f.ID, f.FilePath, f.FileSize, f.Sha256, rate)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Error("Blob check failed", "file_id", f.ID, "error", err)
+				continue
+			}
+			if check.status != integrityOK {
+				slog.Error("Blob failed integrity check", "file_id", f.ID, "path", f.FilePath, "status", check.status)
+			}
+		}
+
+		if len(files) < scrubBatchSize {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(scrubIdleWait):
+			}
+		}
+	}
+}
+
+// quarantineBlob moves a bad blob out of the served paths
+func quarantineBlob(path string) error {
+	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, filepath.Join(quarantineDir, filepath.Base(path)))
+}
+
+// repairBlob restores the blob at path from the copy of the same name in
+// replicaDir, once the copy matches the recorded size and hash
+func repairBlob(ctx context.Context, path string, size int64, expected, replicaDir string) error {
+	replica := filepath.Join(replicaDir, filepath.Base(path))
+	check, err := checkBlob(ctx, replica, size, expected, 0)
+	if err != nil {
+		return err
+	}
+	if check.status != integrityOK {
+		return fmt.Errorf("replica is %s", check.status)
+	}
+
+	src, err := os.Open(replica)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Written to the staging area first so path is never half-written; the
+	// reconciler moves it into place if the rename does not happen
+	staged := filepath.Join(stagingDir, filepath.Base(path))
+	if _, err := writeBlob(ctx, staged, src); err != nil {
+		return err
+	}
+	return os.Rename(staged, path)
+}
+
+// runFsck implements `vaultdrive fsck`
+func runFsck(ctx context.Context, queries *database.Queries, args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	quarantine := flags.Bool("quarantine", false, "move truncated and corrupted blobs to "+quarantineDir)
+	repairFrom := flags.String("repair-from", "", "directory holding replicas of the blobs to restore bad ones from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := queries.GetFileIntegrity(ctx)
+	if err != nil {
+		return fmt.Errorf("listing files: %w", err)
+	}
+
+	bad := 0
+	for _, f := range files {
+		status := f.Status.String
+		if status != integrityQuarantined {
+			check, err := checkFile(ctx, queries, f.ID, f.FilePath, f.FileSize, f.Sha256, 0)
+			if err != nil {
+				return err
+			}
+			status = check.status
+		}
+		if status == integrityOK {
+			continue
+		}
+
+		var notes []string
+		if isChunked(f.FilePath) {
+			// Shared chunks are checked and repaired one by one below
+			fmt.Printf("%-12s %s  %s  see the chunk checks\n", status, f.ID, f.FilePath)
+			bad++
+			continue
+		}
+		if *repairFrom != "" {
+			err := errors.New("no recorded hash to verify a replica against")
+			if f.Sha256.Valid {
+				err = repairBlob(ctx, f.FilePath, f.FileSize, f.Sha256.String, *repairFrom)
+			}
+			if err == nil {
+				err = queries.SetFileHash(ctx, database.SetFileHashParams{
+					FileID:    f.ID,
+					Sha256:    f.Sha256,
+					CheckedAt: time.Now().UTC(),
+				})
+			}
+			if err == nil {
+				fmt.Printf("%-12s %s  %s  repaired\n", status, f.ID, f.FilePath)
+				continue
+			}
+			notes = append(notes, fmt.Sprintf("not repaired: %v", err))
+		}
+		if *quarantine && (status == integrityTruncated || status == integrityCorrupted) {
+			if err := quarantineBlob(f.FilePath); err != nil {
+				return err
+			}
+			err := queries.RecordFileCheck(ctx, database.RecordFileCheckParams{
+				FileID:    f.ID,
+				Status:    integrityQuarantined,
+				CheckedAt: time.Now().UTC(),
+			})
+			if err != nil {
+				return err
+			}
+			notes = append(notes, "quarantined")
+		}
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("%-12s %s  %s  %s", status, f.ID, f.FilePath, strings.Join(notes, ", "))))
+		bad++
+	}
+
+	badChunks, err := fsckChunks(ctx, queries, *quarantine, *repairFrom)
+	if err != nil {
+		return err
+	}
+
+	// Orphans are only reported; `vaultdrive reconcile` removes them
+	paths, err := queries.GetBlobPaths(ctx)
+	if err != nil {
+		return fmt.Errorf("listing blob paths: %w", err)
+	}
+	referenced := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		referenced[filepath.Clean(p)] = true
+	}
+	blobs, err := oldBlobs(uploadDir, time.Now().Add(-reconcileGracePeriod))
+	if err != nil {
+		return err
+	}
+	orphans := 0
+	for _, name := range blobs {
+		blobPath := filepath.Join(uploadDir, name)
+		if !referenced[blobPath] {
+			fmt.Printf("%-12s %s\n", "orphan", blobPath)
+			orphans++
+		}
+	}
+
+	fmt.Printf("%d files checked, %d bad, %d bad chunks, %d orphan blobs\n", len(files), bad, badChunks, orphans)
+	if bad > 0 || badChunks > 0 {
+		return fmt.Errorf("%d files and %d chunks failed the check", bad, badChunks)
+	}
+	return nil
+}
+
+// fsckChunks checks every stored chunk against the hash it is named by, and
+// quarantines or repairs the bad ones like whole blobs. It returns how many
+// are still bad.
+func fsckChunks(ctx context.Context, queries *database.Queries, quarantine bool, repairFrom string) (int, error) {
+	chunks, err := queries.GetChunks(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("listing chunks: %w", err)
+	}
+
+	bad := 0
+	for _, c := range chunks {
+		check, err := checkBlob(ctx, chunkPath(c.Hash), c.Size, c.Hash, 0)
+		if err != nil {
+			return bad, err
+		}
+		if check.status == integrityOK {
+			continue
+		}
+
+		var notes []string
+		if repairFrom != "" {
+			err := repairBlob(ctx, chunkPath(c.Hash), c.Size, c.Hash, filepath.Join(repairFrom, "chunks"))
+			if err == nil {
+				fmt.Printf("%-12s chunk %s  repaired\n", check.status, c.Hash)
+				continue
+			}
+			notes = append(notes, fmt.Sprintf("not repaired: %v", err))
+		}
+		if quarantine && check.status != integrityMissing {
+			if err := quarantineBlob(chunkPath(c.Hash)); err != nil {
+				return bad, err
+			}
+			notes = append(notes, "quarantined")
+		}
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("%-12s chunk %s  %s", check.status, c.Hash, strings.Join(notes, ", "))))
+		bad++
+	}
+	return bad, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckBlob(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "blob")
+	content := []byte("ciphertext")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		size     int64
+		expected string
+		want     string
+	}{
+		{"matching", path, 10, hash, integrityOK},
+		{"no recorded hash", path, 10, "", integrityOK},
+		{"missing", path + ".gone", 10, hash, integrityMissing},
+		{"truncated", path, 11, hash, integrityTruncated},
+		{"longer", path, 9, hash, integrityCorrupted},
+		{"wrong hash", path, 10, hex.EncodeToString(make([]byte, 32)), integrityCorrupted},
+	}
+	for _, tt := range tests {
+		check, err := checkBlob(ctx, tt.path, tt.size, tt.expected, 0)
+		if err != nil {
+			t.Fatalf("%s: checkBlob() error = %v", tt.name, err)
+		}
+		if check.status != tt.want {
+			t.Errorf("%s: status = %q, want %q", tt.name, check.status, tt.want)
+		}
+		if check.status == integrityOK && check.sha256 != hash {
+			t.Errorf("%s: sha256 = %q, want %q", tt.name, check.sha256, hash)
+		}
+	}
+}
+
+func TestHashBlobThrottles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, make([]byte, 300), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, _, err := hashBlob(context.Background(), path, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("hashing 300 bytes at 1000 B/s took %v", elapsed)
+	}
+}
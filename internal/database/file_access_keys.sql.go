@@ -7,24 +7,48 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countStaleFileAccessKeys = `-- name: CountStaleFileAccessKeys :one
+SELECT COUNT(*) FROM file_access_keys
+WHERE user_id = $1 AND key_version < $2
+`
+
+type CountStaleFileAccessKeysParams struct {
+	UserID     uuid.NullUUID
+	KeyVersion int32
+}
+
+func (q *Queries) CountStaleFileAccessKeys(ctx context.Context, arg CountStaleFileAccessKeysParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countStaleFileAccessKeys, arg.UserID, arg.KeyVersion)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFileAccessKey = `-- name: CreateFileAccessKey :one
-INSERT INTO file_access_keys (file_id, user_id, wrapped_key)
-VALUES ($1, $2, $3)
-RETURNING id, file_id, user_id, wrapped_key, created_at
+INSERT INTO file_access_keys (file_id, user_id, wrapped_key, key_version)
+VALUES ($1, $2, $3, COALESCE($4, (SELECT key_version FROM users WHERE id = $2)))
+RETURNING id, file_id, user_id, wrapped_key, created_at, key_version
 `
 
 type CreateFileAccessKeyParams struct {
 	FileID     uuid.NullUUID
 	UserID     uuid.NullUUID
 	WrappedKey string
+	KeyVersion sql.NullInt32
 }
 
 func (q *Queries) CreateFileAccessKey(ctx context.Context, arg CreateFileAccessKeyParams) (FileAccessKey, error) {
-	row := q.db.QueryRowContext(ctx, createFileAccessKey, arg.FileID, arg.UserID, arg.WrappedKey)
+	row := q.db.QueryRowContext(ctx, createFileAccessKey,
+		arg.FileID,
+		arg.UserID,
+		arg.WrappedKey,
+		arg.KeyVersion,
+	)
 	var i FileAccessKey
 	err := row.Scan(
 		&i.ID,
@@ -32,6 +56,7 @@ func (q *Queries) CreateFileAccessKey(ctx context.Context, arg CreateFileAccessK
 		&i.UserID,
 		&i.WrappedKey,
 		&i.CreatedAt,
+		&i.KeyVersion,
 	)
 	return i, err
 }
@@ -51,8 +76,23 @@ func (q *Queries) DeleteFileAccessKey(ctx context.Context, arg DeleteFileAccessK
 	return err
 }
 
+const deleteOrgFileAccessKeysForUser = `-- name: DeleteOrgFileAccessKeysForUser :exec
+DELETE FROM file_access_keys
+WHERE user_id = $2 AND file_id IN (SELECT id FROM files WHERE org_id = $1)
+`
+
+type DeleteOrgFileAccessKeysForUserParams struct {
+	OrgID  uuid.NullUUID
+	UserID uuid.NullUUID
+}
+
+func (q *Queries) DeleteOrgFileAccessKeysForUser(ctx context.Context, arg DeleteOrgFileAccessKeysForUserParams) error {
+	_, err := q.db.ExecContext(ctx, deleteOrgFileAccessKeysForUser, arg.OrgID, arg.UserID)
+	return err
+}
+
 const getFileAccessKey = `-- name: GetFileAccessKey :one
-SELECT id, file_id, user_id, wrapped_key, created_at FROM file_access_keys
+SELECT id, file_id, user_id, wrapped_key, created_at, key_version FROM file_access_keys
 WHERE file_id = $1 AND user_id = $2
 `
 
@@ -70,12 +110,13 @@ func (q *Queries) GetFileAccessKey(ctx context.Context, arg GetFileAccessKeyPara
 		&i.UserID,
 		&i.WrappedKey,
 		&i.CreatedAt,
+		&i.KeyVersion,
 	)
 	return i, err
 }
 
 const getFileAccessKeysByFile = `-- name: GetFileAccessKeysByFile :many
-SELECT id, file_id, user_id, wrapped_key, created_at FROM file_access_keys
+SELECT id, file_id, user_id, wrapped_key, created_at, key_version FROM file_access_keys
 WHERE file_id = $1
 ORDER BY created_at DESC
 `
@@ -95,6 +136,7 @@ func (q *Queries) GetFileAccessKeysByFile(ctx context.Context, fileID uuid.NullU
 			&i.UserID,
 			&i.WrappedKey,
 			&i.CreatedAt,
+			&i.KeyVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -110,7 +152,7 @@ func (q *Queries) GetFileAccessKeysByFile(ctx context.Context, fileID uuid.NullU
 }
 
 const getFileAccessKeysByUser = `-- name: GetFileAccessKeysByUser :many
-SELECT id, file_id, user_id, wrapped_key, created_at FROM file_access_keys
+SELECT id, file_id, user_id, wrapped_key, created_at, key_version FROM file_access_keys
 WHERE user_id = $1
 ORDER BY created_at DESC
 `
@@ -130,6 +172,50 @@ func (q *Queries) GetFileAccessKeysByUser(ctx context.Context, userID uuid.NullU
 			&i.UserID,
 			&i.WrappedKey,
 			&i.CreatedAt,
+			&i.KeyVersion,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStaleFileAccessKeys = `-- name: GetStaleFileAccessKeys :many
+SELECT id, file_id, user_id, wrapped_key, created_at, key_version FROM file_access_keys
+WHERE user_id = $1 AND key_version < $2
+ORDER BY id
+LIMIT $3
+`
+
+type GetStaleFileAccessKeysParams struct {
+	UserID     uuid.NullUUID
+	KeyVersion int32
+	Limit      int32
+}
+
+func (q *Queries) GetStaleFileAccessKeys(ctx context.Context, arg GetStaleFileAccessKeysParams) ([]FileAccessKey, error) {
+	rows, err := q.db.QueryContext(ctx, getStaleFileAccessKeys, arg.UserID, arg.KeyVersion, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FileAccessKey
+	for rows.Next() {
+		var i FileAccessKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileID,
+			&i.UserID,
+			&i.WrappedKey,
+			&i.CreatedAt,
+			&i.KeyVersion,
 		); err != nil {
 			return nil, err
 		}
@@ -143,3 +229,31 @@ func (q *Queries) GetFileAccessKeysByUser(ctx context.Context, userID uuid.NullU
 	}
 	return items, nil
 }
+
+const rewrapFileAccessKey = `-- name: RewrapFileAccessKey :execrows
+UPDATE file_access_keys
+SET wrapped_key = $3, key_version = $4
+WHERE file_id = $1 AND user_id = $2 AND key_version = $5
+`
+
+type RewrapFileAccessKeyParams struct {
+	FileID       uuid.NullUUID
+	UserID       uuid.NullUUID
+	WrappedKey   string
+	KeyVersion   int32
+	KeyVersion_2 int32
+}
+
+func (q *Queries) RewrapFileAccessKey(ctx context.Context, arg RewrapFileAccessKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rewrapFileAccessKey,
+		arg.FileID,
+		arg.UserID,
+		arg.WrappedKey,
+		arg.KeyVersion,
+		arg.KeyVersion_2,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -34,10 +34,12 @@ INSERT INTO files (
     encrypted_metadata,
     current_key_version,
     created_at,
-    updated_at
+    updated_at,
+    folder_id,
+    id
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, gen_random_uuid()))
+RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by
 `
 
 type CreateFileParams struct {
@@ -49,6 +51,8 @@ type CreateFileParams struct {
 	CurrentKeyVersion sql.NullInt32
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
+	FolderID          uuid.NullUUID
+	ID                uuid.NullUUID
 }
 
 func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, error) {
@@ -61,6 +65,8 @@ func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, e
 		arg.CurrentKeyVersion,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.FolderID,
+		arg.ID,
 	)
 	var i File
 	err := row.Scan(
@@ -73,6 +79,70 @@ func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, e
 		&i.CurrentKeyVersion,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
+	)
+	return i, err
+}
+
+const createOrgFile = `-- name: CreateOrgFile :one
+INSERT INTO files (
+    org_id,
+    uploaded_by,
+    filename,
+    file_path,
+    file_size,
+    encrypted_metadata,
+    current_key_version,
+    created_at,
+    updated_at,
+    id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, gen_random_uuid()))
+RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by
+`
+
+type CreateOrgFileParams struct {
+	OrgID             uuid.NullUUID
+	UploadedBy        uuid.NullUUID
+	Filename          string
+	FilePath          string
+	FileSize          int64
+	EncryptedMetadata sql.NullString
+	CurrentKeyVersion sql.NullInt32
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	ID                uuid.NullUUID
+}
+
+func (q *Queries) CreateOrgFile(ctx context.Context, arg CreateOrgFileParams) (File, error) {
+	row := q.db.QueryRowContext(ctx, createOrgFile,
+		arg.OrgID,
+		arg.UploadedBy,
+		arg.Filename,
+		arg.FilePath,
+		arg.FileSize,
+		arg.EncryptedMetadata,
+		arg.CurrentKeyVersion,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.FilePath,
+		&i.FileSize,
+		&i.EncryptedMetadata,
+		&i.CurrentKeyVersion,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
 	)
 	return i, err
 }
@@ -98,7 +168,7 @@ func (q *Queries) DeleteFilesByOwnerID(ctx context.Context, ownerID uuid.NullUUI
 }
 
 const getFileByID = `-- name: GetFileByID :one
-SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at FROM files
+SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by FROM files
 WHERE id = $1
 `
 
@@ -115,12 +185,104 @@ func (q *Queries) GetFileByID(ctx context.Context, id uuid.UUID) (File, error) {
 		&i.CurrentKeyVersion,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
 	)
 	return i, err
 }
 
+const getFilesByFolder = `-- name: GetFilesByFolder :many
+SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by FROM files
+WHERE owner_id = $1 AND folder_id IS NOT DISTINCT FROM $2
+ORDER BY filename ASC
+`
+
+type GetFilesByFolderParams struct {
+	OwnerID  uuid.NullUUID
+	FolderID uuid.NullUUID
+}
+
+func (q *Queries) GetFilesByFolder(ctx context.Context, arg GetFilesByFolderParams) ([]File, error) {
+	rows, err := q.db.QueryContext(ctx, getFilesByFolder, arg.OwnerID, arg.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Filename,
+			&i.FilePath,
+			&i.FileSize,
+			&i.EncryptedMetadata,
+			&i.CurrentKeyVersion,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FolderID,
+			&i.OrgID,
+			&i.UploadedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFilesByOrgID = `-- name: GetFilesByOrgID :many
+SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by FROM files
+WHERE org_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetFilesByOrgID(ctx context.Context, orgID uuid.NullUUID) ([]File, error) {
+	rows, err := q.db.QueryContext(ctx, getFilesByOrgID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Filename,
+			&i.FilePath,
+			&i.FileSize,
+			&i.EncryptedMetadata,
+			&i.CurrentKeyVersion,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FolderID,
+			&i.OrgID,
+			&i.UploadedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFilesByOwnerID = `-- name: GetFilesByOwnerID :many
-SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at FROM files
+SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by FROM files
 WHERE owner_id = $1
 ORDER BY created_at DESC
 `
@@ -144,6 +306,9 @@ func (q *Queries) GetFilesByOwnerID(ctx context.Context, ownerID uuid.NullUUID)
 			&i.CurrentKeyVersion,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.FolderID,
+			&i.OrgID,
+			&i.UploadedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -159,7 +324,7 @@ func (q *Queries) GetFilesByOwnerID(ctx context.Context, ownerID uuid.NullUUID)
 }
 
 const getFilesByOwnerIDWithPagination = `-- name: GetFilesByOwnerIDWithPagination :many
-SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at FROM files
+SELECT id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by FROM files
 WHERE owner_id = $1
 ORDER BY created_at DESC
 LIMIT $2 OFFSET $3
@@ -190,6 +355,75 @@ func (q *Queries) GetFilesByOwnerIDWithPagination(ctx context.Context, arg GetFi
 			&i.CurrentKeyVersion,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.FolderID,
+			&i.OrgID,
+			&i.UploadedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOrgFilesWithKeyForUser = `-- name: GetOrgFilesWithKeyForUser :many
+SELECT files.id, files.owner_id, files.filename, files.file_path, files.file_size, files.encrypted_metadata, files.current_key_version, files.created_at, files.updated_at, files.folder_id, files.org_id, files.uploaded_by, file_access_keys.wrapped_key
+FROM files
+LEFT JOIN file_access_keys ON file_access_keys.file_id = files.id AND file_access_keys.user_id = $2
+WHERE files.org_id = $1
+ORDER BY files.created_at DESC
+`
+
+type GetOrgFilesWithKeyForUserParams struct {
+	OrgID  uuid.NullUUID
+	UserID uuid.NullUUID
+}
+
+type GetOrgFilesWithKeyForUserRow struct {
+	ID                uuid.UUID
+	OwnerID           uuid.NullUUID
+	Filename          string
+	FilePath          string
+	FileSize          int64
+	EncryptedMetadata sql.NullString
+	CurrentKeyVersion sql.NullInt32
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	FolderID          uuid.NullUUID
+	OrgID             uuid.NullUUID
+	UploadedBy        uuid.NullUUID
+	WrappedKey        sql.NullString
+}
+
+func (q *Queries) GetOrgFilesWithKeyForUser(ctx context.Context, arg GetOrgFilesWithKeyForUserParams) ([]GetOrgFilesWithKeyForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOrgFilesWithKeyForUser, arg.OrgID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOrgFilesWithKeyForUserRow
+	for rows.Next() {
+		var i GetOrgFilesWithKeyForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Filename,
+			&i.FilePath,
+			&i.FileSize,
+			&i.EncryptedMetadata,
+			&i.CurrentKeyVersion,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FolderID,
+			&i.OrgID,
+			&i.UploadedBy,
+			&i.WrappedKey,
 		); err != nil {
 			return nil, err
 		}
@@ -204,6 +438,117 @@ func (q *Queries) GetFilesByOwnerIDWithPagination(ctx context.Context, arg GetFi
 	return items, nil
 }
 
+const getTotalFileSizeByOrgID = `-- name: GetTotalFileSizeByOrgID :one
+SELECT COALESCE(SUM(file_size), 0)::BIGINT AS total_size FROM files
+WHERE org_id = $1
+`
+
+func (q *Queries) GetTotalFileSizeByOrgID(ctx context.Context, orgID uuid.NullUUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getTotalFileSizeByOrgID, orgID)
+	var total_size int64
+	err := row.Scan(&total_size)
+	return total_size, err
+}
+
+const getTotalFileSizeByOwnerID = `-- name: GetTotalFileSizeByOwnerID :one
+SELECT COALESCE(SUM(file_size), 0)::BIGINT AS total_size FROM files
+WHERE owner_id = $1
+`
+
+func (q *Queries) GetTotalFileSizeByOwnerID(ctx context.Context, ownerID uuid.NullUUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getTotalFileSizeByOwnerID, ownerID)
+	var total_size int64
+	err := row.Scan(&total_size)
+	return total_size, err
+}
+
+const moveFile = `-- name: MoveFile :one
+UPDATE files
+SET
+    folder_id = $2,
+    filename = $3,
+    encrypted_metadata = $4,
+    updated_at = $5
+WHERE id = $1
+RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by
+`
+
+type MoveFileParams struct {
+	ID                uuid.UUID
+	FolderID          uuid.NullUUID
+	Filename          string
+	EncryptedMetadata sql.NullString
+	UpdatedAt         time.Time
+}
+
+func (q *Queries) MoveFile(ctx context.Context, arg MoveFileParams) (File, error) {
+	row := q.db.QueryRowContext(ctx, moveFile,
+		arg.ID,
+		arg.FolderID,
+		arg.Filename,
+		arg.EncryptedMetadata,
+		arg.UpdatedAt,
+	)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.FilePath,
+		&i.FileSize,
+		&i.EncryptedMetadata,
+		&i.CurrentKeyVersion,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
+	)
+	return i, err
+}
+
+const setFileEncryptedName = `-- name: SetFileEncryptedName :one
+UPDATE files
+SET
+    filename = $2,
+    encrypted_metadata = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by
+`
+
+type SetFileEncryptedNameParams struct {
+	ID                uuid.UUID
+	Filename          string
+	EncryptedMetadata sql.NullString
+	UpdatedAt         time.Time
+}
+
+func (q *Queries) SetFileEncryptedName(ctx context.Context, arg SetFileEncryptedNameParams) (File, error) {
+	row := q.db.QueryRowContext(ctx, setFileEncryptedName,
+		arg.ID,
+		arg.Filename,
+		arg.EncryptedMetadata,
+		arg.UpdatedAt,
+	)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Filename,
+		&i.FilePath,
+		&i.FileSize,
+		&i.EncryptedMetadata,
+		&i.CurrentKeyVersion,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
+	)
+	return i, err
+}
+
 const updateFile = `-- name: UpdateFile :one
 UPDATE files
 SET 
@@ -214,7 +559,7 @@ SET
     current_key_version = $6,
     updated_at = $7
 WHERE id = $1
-RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at
+RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by
 `
 
 type UpdateFileParams struct {
@@ -248,6 +593,9 @@ func (q *Queries) UpdateFile(ctx context.Context, arg UpdateFileParams) (File, e
 		&i.CurrentKeyVersion,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
 	)
 	return i, err
 }
@@ -259,7 +607,7 @@ SET
     current_key_version = $3,
     updated_at = $4
 WHERE id = $1
-RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at
+RETURNING id, owner_id, filename, file_path, file_size, encrypted_metadata, current_key_version, created_at, updated_at, folder_id, org_id, uploaded_by
 `
 
 type UpdateFileMetadataParams struct {
@@ -287,6 +635,9 @@ func (q *Queries) UpdateFileMetadata(ctx context.Context, arg UpdateFileMetadata
 		&i.CurrentKeyVersion,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.FolderID,
+		&i.OrgID,
+		&i.UploadedBy,
 	)
 	return i, err
 }
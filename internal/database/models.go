@@ -11,6 +11,46 @@ import (
 	"github.com/google/uuid"
 )
 
+type Chunk struct {
+	Hash      string
+	Size      int64
+	Refcount  int32
+	CreatedAt time.Time
+}
+
+type Contact struct {
+	UserID            uuid.UUID
+	ContactID         uuid.UUID
+	PinnedFingerprint string
+	PinnedKeyVersion  int32
+	VerifiedAt        sql.NullTime
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type Device struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	Name             string
+	PublicKey        string
+	KeyAlgorithm     string
+	WrappedMasterKey sql.NullString
+	ApprovedAt       sql.NullTime
+	PairingCodeHash  sql.NullString
+	PairingExpiresAt sql.NullTime
+	PairingAttempts  int32
+	CreatedAt        time.Time
+	LastSeenAt       time.Time
+}
+
+type Event struct {
+	ID        int64
+	UserID    uuid.UUID
+	EventType string
+	Payload   string
+	CreatedAt time.Time
+}
+
 type File struct {
 	ID                uuid.UUID
 	OwnerID           uuid.NullUUID
@@ -21,6 +61,9 @@ type File struct {
 	CurrentKeyVersion sql.NullInt32
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
+	FolderID          uuid.NullUUID
+	OrgID             uuid.NullUUID
+	UploadedBy        uuid.NullUUID
 }
 
 type FileAccessKey struct {
@@ -29,6 +72,20 @@ type FileAccessKey struct {
 	UserID     uuid.NullUUID
 	WrappedKey string
 	CreatedAt  sql.NullTime
+	KeyVersion int32
+}
+
+type FileChunk struct {
+	FileID    uuid.UUID
+	Seq       int32
+	ChunkHash string
+}
+
+type FileIntegrity struct {
+	FileID    uuid.UUID
+	Sha256    sql.NullString
+	Status    string
+	CheckedAt time.Time
 }
 
 type FileShare struct {
@@ -39,6 +96,127 @@ type FileShare struct {
 	CreatedAt        time.Time
 }
 
+type FileSignature struct {
+	FileID           uuid.UUID
+	SignerID         uuid.NullUUID
+	SigningPublicKey string
+	CiphertextSha256 string
+	Signature        string
+	CreatedAt        time.Time
+}
+
+type Folder struct {
+	ID        uuid.UUID
+	OwnerID   uuid.UUID
+	ParentID  uuid.NullUUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Group struct {
+	ID               uuid.UUID
+	Name             string
+	CreatedBy        uuid.NullUUID
+	PublicKey        string
+	KeyAlgorithm     string
+	KeyVersion       int32
+	RotationRequired bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type GroupFileKey struct {
+	GroupID    uuid.UUID
+	FileID     uuid.UUID
+	WrappedKey string
+	SharedBy   uuid.NullUUID
+	CreatedAt  time.Time
+}
+
+type GroupMember struct {
+	GroupID           uuid.UUID
+	UserID            uuid.UUID
+	Role              string
+	WrappedPrivateKey string
+	CreatedAt         time.Time
+}
+
+type KeyLogEntry struct {
+	Idx          int64
+	UserID       uuid.UUID
+	KeyVersion   int32
+	KeyAlgorithm string
+	PublicKey    string
+	CreatedAt    time.Time
+	KeyUse       string
+}
+
+type Organization struct {
+	ID           uuid.UUID
+	Name         string
+	BillingEmail string
+	QuotaBytes   sql.NullInt64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type OrganizationMember struct {
+	OrgID     uuid.UUID
+	UserID    uuid.UUID
+	Role      string
+	CreatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID                  uuid.UUID
+	UserID              uuid.UUID
+	Name                string
+	TokenHash           string
+	PrivateKeyEncrypted string
+	CreatedAt           time.Time
+	LastUsedAt          sql.NullTime
+	RevokedAt           sql.NullTime
+}
+
+type QuotaWarning struct {
+	UserID uuid.UUID
+	SentAt time.Time
+}
+
+type RecoveryApproval struct {
+	RequestID      uuid.UUID
+	TrusteeID      uuid.UUID
+	EncryptedShare string
+	CreatedAt      time.Time
+}
+
+type RecoveryKey struct {
+	UserID               uuid.UUID
+	RecoveryKeyEncrypted string
+	ProofHash            string
+	Threshold            sql.NullInt32
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+type RecoveryRequest struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	PublicKey   string
+	SecretHash  string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	CompletedAt sql.NullTime
+}
+
+type RecoveryShare struct {
+	UserID         uuid.UUID
+	TrusteeID      uuid.UUID
+	EncryptedShare string
+	CreatedAt      time.Time
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt sql.NullTime
@@ -46,17 +224,84 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 	UserID    uuid.UUID
 	ExpiresAt time.Time
+	DeviceID  uuid.NullUUID
 }
 
-type User struct {
+type ReplicationQueue struct {
+	BlobName      string
+	Operation     string
+	Attempts      int32
+	LastError     sql.NullString
+	EnqueuedAt    time.Time
+	NextAttemptAt time.Time
+}
+
+type S3AccessKey struct {
 	ID                  uuid.UUID
-	FirstName           string
-	LastName            string
-	Username            string
-	Email               string
-	PasswordHash        string
+	UserID              uuid.UUID
+	AccessKeyID         string
+	SecretKey           string
+	PrivateKeyEncrypted sql.NullString
+	CreatedAt           time.Time
+	LastUsedAt          sql.NullTime
+}
+
+type S3MultipartPart struct {
+	UploadID          uuid.UUID
+	PartNumber        int32
+	FilePath          string
+	Size              int64
+	Etag              string
+	EncryptedMetadata sql.NullString
+	WrappedKey        sql.NullString
+	CreatedAt         time.Time
+}
+
+type S3MultipartUpload struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Bucket    string
+	ObjectKey string
+	CreatedAt time.Time
+}
+
+type SshKey struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	PublicKey   string
+	Fingerprint string
+	CreatedAt   time.Time
+	LastUsedAt  sql.NullTime
+}
+
+type User struct {
+	ID                         uuid.UUID
+	FirstName                  string
+	LastName                   string
+	Username                   string
+	Email                      string
+	PasswordHash               string
+	PublicKey                  string
+	PrivateKeyEncrypted        string
+	CreatedAt                  time.Time
+	UpdatedAt                  time.Time
+	Role                       string
+	DisabledAt                 sql.NullTime
+	QuotaBytes                 sql.NullInt64
+	TokensValidAfter           sql.NullTime
+	KeyVersion                 int32
+	KeyAlgorithm               string
+	SigningPublicKey           sql.NullString
+	SigningPrivateKeyEncrypted sql.NullString
+}
+
+type UserKey struct {
+	UserID              uuid.UUID
+	Version             int32
 	PublicKey           string
-	PrivateKeyEncrypted string
+	PrivateKeyEncrypted sql.NullString
 	CreatedAt           time.Time
-	UpdatedAt           time.Time
+	RetiredAt           sql.NullTime
+	KeyAlgorithm        string
 }
@@ -20,11 +20,12 @@ INSERT INTO refresh_tokens (
     updated_at,
     user_id,
     expires_at,
-    revoked_at
+    revoked_at,
+    device_id
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7
 )
-RETURNING token, created_at, updated_at, revoked_at, user_id, expires_at
+RETURNING token, created_at, updated_at, revoked_at, user_id, expires_at, device_id
 `
 
 type CreateRefreshTokenParams struct {
@@ -34,6 +35,7 @@ type CreateRefreshTokenParams struct {
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	DeviceID  uuid.NullUUID
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
@@ -44,6 +46,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		arg.UserID,
 		arg.ExpiresAt,
 		arg.RevokedAt,
+		arg.DeviceID,
 	)
 	var i RefreshToken
 	err := row.Scan(
@@ -53,12 +56,13 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.RevokedAt,
 		&i.UserID,
 		&i.ExpiresAt,
+		&i.DeviceID,
 	)
 	return i, err
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, revoked_at, user_id, expires_at FROM refresh_tokens
+SELECT token, created_at, updated_at, revoked_at, user_id, expires_at, device_id FROM refresh_tokens
 WHERE token = $1
 `
 
@@ -72,12 +76,13 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.RevokedAt,
 		&i.UserID,
 		&i.ExpiresAt,
+		&i.DeviceID,
 	)
 	return i, err
 }
 
 const getUserByRefreshToken = `-- name: GetUserByRefreshToken :one
-SELECT users.id, users.first_name, users.last_name, users.username, users.email, users.password_hash, users.public_key, users.private_key_encrypted, users.created_at, users.updated_at FROM users
+SELECT users.id, users.first_name, users.last_name, users.username, users.email, users.password_hash, users.public_key, users.private_key_encrypted, users.created_at, users.updated_at, users.role, users.disabled_at, users.quota_bytes, users.tokens_valid_after, users.key_version, users.key_algorithm, users.signing_public_key, users.signing_private_key_encrypted FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1
 `
@@ -96,6 +101,14 @@ func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (User
 		&i.PrivateKeyEncrypted,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
 	)
 	return i, err
 }
@@ -116,3 +129,35 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshToken
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.Token, arg.RevokedAt, arg.UpdatedAt)
 	return err
 }
+
+const revokeRefreshTokensByDevice = `-- name: RevokeRefreshTokensByDevice :exec
+UPDATE refresh_tokens
+SET revoked_at = $2, updated_at = $2
+WHERE device_id = $1 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokensByDeviceParams struct {
+	DeviceID  uuid.NullUUID
+	RevokedAt sql.NullTime
+}
+
+func (q *Queries) RevokeRefreshTokensByDevice(ctx context.Context, arg RevokeRefreshTokensByDeviceParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokensByDevice, arg.DeviceID, arg.RevokedAt)
+	return err
+}
+
+const revokeRefreshTokensByUser = `-- name: RevokeRefreshTokensByUser :exec
+UPDATE refresh_tokens
+SET revoked_at = $2, updated_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokensByUserParams struct {
+	UserID    uuid.UUID
+	RevokedAt sql.NullTime
+}
+
+func (q *Queries) RevokeRefreshTokensByUser(ctx context.Context, arg RevokeRefreshTokensByUserParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokensByUser, arg.UserID, arg.RevokedAt)
+	return err
+}
@@ -59,7 +59,7 @@ func (q *Queries) DeleteShare(ctx context.Context, arg DeleteShareParams) error
 }
 
 const getFilesBySharedWithUser = `-- name: GetFilesBySharedWithUser :many
-SELECT f.id, f.owner_id, f.filename, f.file_path, f.file_size, f.encrypted_metadata, f.current_key_version, f.created_at, f.updated_at FROM files f
+SELECT f.id, f.owner_id, f.filename, f.file_path, f.file_size, f.encrypted_metadata, f.current_key_version, f.created_at, f.updated_at, f.folder_id, f.org_id, f.uploaded_by FROM files f
 INNER JOIN file_shares fs ON f.id = fs.file_id
 WHERE fs.shared_with_user_id = $1
 ORDER BY f.created_at DESC
@@ -84,6 +84,9 @@ func (q *Queries) GetFilesBySharedWithUser(ctx context.Context, sharedWithUserID
 			&i.CurrentKeyVersion,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.FolderID,
+			&i.OrgID,
+			&i.UploadedBy,
 		); err != nil {
 			return nil, err
 		}
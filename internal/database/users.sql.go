@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,22 +23,28 @@ INSERT INTO users (
   public_key,
   private_key_encrypted,
   created_at,
-  updated_at
+  updated_at,
+  key_algorithm,
+  signing_public_key,
+  signing_private_key_encrypted
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted
 `
 
 type CreateUserParams struct {
-	FirstName           string
-	LastName            string
-	Username            string
-	Email               string
-	PasswordHash        string
-	PublicKey           string
-	PrivateKeyEncrypted string
-	CreatedAt           time.Time
-	UpdatedAt           time.Time
+	FirstName                  string
+	LastName                   string
+	Username                   string
+	Email                      string
+	PasswordHash               string
+	PublicKey                  string
+	PrivateKeyEncrypted        string
+	CreatedAt                  time.Time
+	UpdatedAt                  time.Time
+	KeyAlgorithm               string
+	SigningPublicKey           sql.NullString
+	SigningPrivateKeyEncrypted sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -51,6 +58,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.PrivateKeyEncrypted,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.KeyAlgorithm,
+		arg.SigningPublicKey,
+		arg.SigningPrivateKeyEncrypted,
 	)
 	var i User
 	err := row.Scan(
@@ -64,6 +74,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PrivateKeyEncrypted,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
 	)
 	return i, err
 }
@@ -79,7 +97,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at FROM users
+SELECT id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted FROM users
 WHERE email = $1
 `
 
@@ -97,12 +115,20 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.PrivateKeyEncrypted,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at FROM users
+SELECT id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted FROM users
 WHERE id = $1
 `
 
@@ -120,12 +146,20 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.PrivateKeyEncrypted,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at FROM users
+SELECT id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted FROM users
 WHERE username = $1
 `
 
@@ -143,10 +177,246 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.PrivateKeyEncrypted,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
+	)
+	return i, err
+}
+
+const rotateUserKey = `-- name: RotateUserKey :one
+UPDATE users
+SET public_key = $2, private_key_encrypted = $3, key_version = key_version + 1, updated_at = $4, key_algorithm = $6
+WHERE id = $1 AND key_version = $5
+RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted
+`
+
+type RotateUserKeyParams struct {
+	ID                  uuid.UUID
+	PublicKey           string
+	PrivateKeyEncrypted string
+	UpdatedAt           time.Time
+	KeyVersion          int32
+	KeyAlgorithm        string
+}
+
+func (q *Queries) RotateUserKey(ctx context.Context, arg RotateUserKeyParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, rotateUserKey,
+		arg.ID,
+		arg.PublicKey,
+		arg.PrivateKeyEncrypted,
+		arg.UpdatedAt,
+		arg.KeyVersion,
+		arg.KeyAlgorithm,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PublicKey,
+		&i.PrivateKeyEncrypted,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
+	)
+	return i, err
+}
+
+const setUserDisabled = `-- name: SetUserDisabled :one
+UPDATE users
+SET disabled_at = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted
+`
+
+type SetUserDisabledParams struct {
+	ID         uuid.UUID
+	DisabledAt sql.NullTime
+	UpdatedAt  time.Time
+}
+
+func (q *Queries) SetUserDisabled(ctx context.Context, arg SetUserDisabledParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserDisabled, arg.ID, arg.DisabledAt, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PublicKey,
+		&i.PrivateKeyEncrypted,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
 	)
 	return i, err
 }
 
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, private_key_encrypted = $3, updated_at = $4
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID                  uuid.UUID
+	PasswordHash        string
+	PrivateKeyEncrypted string
+	UpdatedAt           time.Time
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword,
+		arg.ID,
+		arg.PasswordHash,
+		arg.PrivateKeyEncrypted,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setUserQuota = `-- name: SetUserQuota :one
+UPDATE users
+SET quota_bytes = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted
+`
+
+type SetUserQuotaParams struct {
+	ID         uuid.UUID
+	QuotaBytes sql.NullInt64
+	UpdatedAt  time.Time
+}
+
+func (q *Queries) SetUserQuota(ctx context.Context, arg SetUserQuotaParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserQuota, arg.ID, arg.QuotaBytes, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PublicKey,
+		&i.PrivateKeyEncrypted,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = $3
+WHERE id = $1
+RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted
+`
+
+type SetUserRoleParams struct {
+	ID        uuid.UUID
+	Role      string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role, arg.UpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PublicKey,
+		&i.PrivateKeyEncrypted,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
+	)
+	return i, err
+}
+
+const setUserSigningKey = `-- name: SetUserSigningKey :exec
+UPDATE users
+SET signing_public_key = $2, signing_private_key_encrypted = $3, updated_at = $4
+WHERE id = $1
+`
+
+type SetUserSigningKeyParams struct {
+	ID                         uuid.UUID
+	SigningPublicKey           sql.NullString
+	SigningPrivateKeyEncrypted sql.NullString
+	UpdatedAt                  time.Time
+}
+
+func (q *Queries) SetUserSigningKey(ctx context.Context, arg SetUserSigningKeyParams) error {
+	_, err := q.db.ExecContext(ctx, setUserSigningKey,
+		arg.ID,
+		arg.SigningPublicKey,
+		arg.SigningPrivateKeyEncrypted,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setUserTokensValidAfter = `-- name: SetUserTokensValidAfter :exec
+UPDATE users
+SET tokens_valid_after = $2
+WHERE id = $1
+`
+
+type SetUserTokensValidAfterParams struct {
+	ID               uuid.UUID
+	TokensValidAfter sql.NullTime
+}
+
+func (q *Queries) SetUserTokensValidAfter(ctx context.Context, arg SetUserTokensValidAfterParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTokensValidAfter, arg.ID, arg.TokensValidAfter)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET 
@@ -155,7 +425,7 @@ SET
   email = $4,
   updated_at = $5
 WHERE id = $1
-RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at
+RETURNING id, first_name, last_name, username, email, password_hash, public_key, private_key_encrypted, created_at, updated_at, role, disabled_at, quota_bytes, tokens_valid_after, key_version, key_algorithm, signing_public_key, signing_private_key_encrypted
 `
 
 type UpdateUserParams struct {
@@ -186,6 +456,14 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.PrivateKeyEncrypted,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.QuotaBytes,
+		&i.TokensValidAfter,
+		&i.KeyVersion,
+		&i.KeyAlgorithm,
+		&i.SigningPublicKey,
+		&i.SigningPrivateKeyEncrypted,
 	)
 	return i, err
 }
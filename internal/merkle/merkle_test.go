@@ -0,0 +1,65 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = LeafHash([]byte(fmt.Sprintf("entry %d", i)))
+	}
+	return leaves
+}
+
+func TestInclusionProof(t *testing.T) {
+	for size := 1; size <= 20; size++ {
+		leaves := testLeaves(size)
+		root := Root(leaves)
+		for i := 0; i < size; i++ {
+			proof, err := InclusionProof(leaves, i)
+			if err != nil {
+				t.Fatalf("InclusionProof(%d, %d) error = %v", size, i, err)
+			}
+			if !VerifyInclusion(leaves[i], i, size, proof, root) {
+				t.Errorf("VerifyInclusion(%d, %d) failed", size, i)
+			}
+			if size > 1 && VerifyInclusion(leaves[(i+1)%size], i, size, proof, root) {
+				t.Errorf("VerifyInclusion(%d, %d) accepted the wrong leaf", size, i)
+			}
+		}
+	}
+
+	if _, err := InclusionProof(testLeaves(3), 3); err != ErrIndexOutOfRange {
+		t.Errorf("InclusionProof() error = %v, want %v", err, ErrIndexOutOfRange)
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	leaves := testLeaves(20)
+	for n := 1; n <= len(leaves); n++ {
+		newRoot := Root(leaves[:n])
+		for m := 1; m <= n; m++ {
+			oldRoot := Root(leaves[:m])
+			proof, err := ConsistencyProof(leaves[:n], m)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d) error = %v", m, n, err)
+			}
+			if !VerifyConsistency(m, n, proof, oldRoot, newRoot) {
+				t.Errorf("VerifyConsistency(%d, %d) failed", m, n)
+			}
+		}
+	}
+
+	// A rewritten history must not verify
+	forked := testLeaves(8)
+	forked[2] = LeafHash([]byte("substituted key"))
+	proof, err := ConsistencyProof(leaves[:8], 5)
+	if err != nil {
+		t.Fatalf("ConsistencyProof() error = %v", err)
+	}
+	if VerifyConsistency(5, 8, proof, Root(forked[:5]), Root(leaves[:8])) {
+		t.Error("VerifyConsistency() accepted a forked tree")
+	}
+}
@@ -0,0 +1,121 @@
+// Package shamir implements Shamir's secret sharing over GF(256). Each byte of
+// the secret is shared with its own random polynomial; a share is the x
+// coordinate followed by one y value per secret byte.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+var (
+	ErrInvalidThreshold = errors.New("threshold must be at least 2 and at most the number of parts")
+	ErrTooManyParts     = errors.New("at most 255 parts are supported")
+	ErrEmptySecret      = errors.New("secret cannot be empty")
+	ErrInvalidShares    = errors.New("shares must be at least 2, the same length and have distinct x coordinates")
+)
+
+// Split divides secret into parts shares, any threshold of which recover it
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	if parts > 255 {
+		return nil, ErrTooManyParts
+	}
+	if threshold < 2 || threshold > parts {
+		return nil, ErrInvalidThreshold
+	}
+
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for b, s := range secret {
+		coefficients[0] = s
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share[b+1] = evaluate(coefficients, share[0])
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from threshold or more shares. Fewer shares
+// produce a wrong secret rather than an error, as Shamir's scheme cannot tell.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrInvalidShares
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, ErrInvalidShares
+	}
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if len(share) != size || share[0] == 0 || seen[share[0]] {
+			return nil, ErrInvalidShares
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for b := range secret {
+		// Lagrange interpolation at x = 0
+		var value byte
+		for i, si := range shares {
+			var num, den byte = 1, 1
+			for j, sj := range shares {
+				if i == j {
+					continue
+				}
+				num = mul(num, sj[0])
+				den = mul(den, si[0]^sj[0])
+			}
+			value ^= mul(si[b+1], div(num, den))
+		}
+		secret[b] = value
+	}
+	return secret, nil
+}
+
+// evaluate returns the polynomial with the given coefficients at x (Horner's rule)
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// mul multiplies in GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1
+func mul(a, b byte) byte {
+	var product byte
+	for b > 0 {
+		if b&1 != 0 {
+			product ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return product
+}
+
+// div divides in GF(256); b must not be zero
+func div(a, b byte) byte {
+	// b^254 is the inverse of b since b^255 = 1
+	inverse := byte(1)
+	for i := 0; i < 254; i++ {
+		inverse = mul(inverse, b)
+	}
+	return mul(a, inverse)
+}
@@ -0,0 +1,65 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("ABCD-EFGH-IJKL-MNOP-QRST-UVWX-YZ23-4567")
+
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("Split() returned %d shares, want 5", len(shares))
+	}
+
+	subsets := [][][]byte{
+		{shares[0], shares[1], shares[2]},
+		{shares[4], shares[2], shares[0]},
+		{shares[1], shares[3], shares[4]},
+		shares,
+	}
+	for _, subset := range subsets {
+		got, err := Combine(subset)
+		if err != nil {
+			t.Fatalf("Combine() error = %v", err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("Combine() = %q, want %q", got, secret)
+		}
+	}
+
+	got, err := Combine(shares[:2])
+	if err != nil {
+		t.Fatalf("Combine() error = %v", err)
+	}
+	if bytes.Equal(got, secret) {
+		t.Error("Combine() recovered the secret from fewer shares than the threshold")
+	}
+}
+
+func TestSplitCombineErrors(t *testing.T) {
+	if _, err := Split([]byte("secret"), 3, 4); err != ErrInvalidThreshold {
+		t.Errorf("Split() with threshold above parts error = %v, want %v", err, ErrInvalidThreshold)
+	}
+	if _, err := Split([]byte("secret"), 3, 1); err != ErrInvalidThreshold {
+		t.Errorf("Split() with threshold 1 error = %v, want %v", err, ErrInvalidThreshold)
+	}
+	if _, err := Split(nil, 3, 2); err != ErrEmptySecret {
+		t.Errorf("Split() with empty secret error = %v, want %v", err, ErrEmptySecret)
+	}
+
+	shares, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if _, err := Combine([][]byte{shares[0], shares[0]}); err != ErrInvalidShares {
+		t.Errorf("Combine() with duplicate shares error = %v, want %v", err, ErrInvalidShares)
+	}
+	if _, err := Combine([][]byte{shares[0], shares[1][:3]}); err != ErrInvalidShares {
+		t.Errorf("Combine() with mismatched lengths error = %v, want %v", err, ErrInvalidShares)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logs are JSON lines written through log/slog. Every request gets an ID, taken
+// from a well-formed X-Request-ID header or generated, which is echoed in the
+// response and added to every record logged with the request's context. One
+// "request" record is written per request with its route, status, latency and
+// size. Records logged while tracing is on carry the trace and span IDs.
+// Attributes and query parameters that name credentials or personal data are
+// redacted before anything is written.
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+	redacted           = "[REDACTED]"
+)
+
+// sensitiveKeys are matched against lowercased attribute and query names
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"cookie",
+	"private_key",
+	"wrapped_key",
+	"recovery_key",
+	"email",
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// redactQuery returns the query string with sensitive values replaced
+func redactQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	cleaned := url.Values{}
+	for key, values := range query {
+		for _, v := range values {
+			if isSensitiveKey(key) {
+				v = redacted
+			}
+			cleaned.Add(key, v)
+		}
+	}
+	return cleaned.Encode()
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// newLogger returns a JSON logger that redacts sensitive attributes and tags
+// records with the request they were logged for
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(requestContextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})})
+}
+
+type requestContextHandler struct {
+	slog.Handler
+}
+
+func (h requestContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info := requestInfoFrom(ctx); info != nil {
+		record.AddAttrs(slog.String("request_id", info.id))
+		if info.userID != uuid.Nil {
+			record.AddAttrs(slog.String("user_id", info.userID.String()))
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestContextHandler) WithGroup(name string) slog.Handler {
+	return requestContextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestInfo collects per-request fields as the request passes through the
+// middleware and the handler
+type requestInfo struct {
+	id     string
+	route  string
+	userID uuid.UUID
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// setRequestRoute records the mux pattern that matched the request
+func setRequestRoute(r *http.Request) {
+	if info := requestInfoFrom(r.Context()); info != nil {
+		info.route = r.Pattern
+	}
+}
+
+// setRequestUser records the authenticated user of the request
+func setRequestUser(ctx context.Context, userID uuid.UUID) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.userID = userID
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// loggingResponseWriter records the status and size of a response. It passes
+// Flush through so server-sent events keep streaming.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	message string
+	err     error
+}
+
+func (lw *loggingResponseWriter) WriteHeader(code int) {
+	if lw.status == 0 {
+		lw.status = code
+	}
+	lw.ResponseWriter.WriteHeader(code)
+}
+
+func (lw *loggingResponseWriter) Write(b []byte) (int, error) {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	n, err := lw.ResponseWriter.Write(b)
+	lw.bytes += int64(n)
+	return n, err
+}
+
+func (lw *loggingResponseWriter) Flush() {
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (lw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// noteResponseError attaches an error response to the request log, returning
+// false when w is not being logged
+func noteResponseError(w http.ResponseWriter, msg string, err error) bool {
+	lw, ok := w.(*loggingResponseWriter)
+	if !ok {
+		return false
+	}
+	lw.message = msg
+	lw.err = err
+	return true
+}
+
+// middlewareRequestLog assigns the request ID and logs one record per request.
+// Requests are traced by otelhttp, continuing W3C traceparent headers.
+func middlewareRequestLog(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		info := &requestInfo{id: id}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		ctx := r.Context()
+		lw := &loggingResponseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(lw, r)
+
+		status := lw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		nameRequestSpan(trace.SpanFromContext(ctx), info.route)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", info.route),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes_in", max(r.ContentLength, 0)),
+			slog.Int64("bytes_out", lw.bytes),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		// Matched requests are identified by their route; paths of gateway
+		// requests are file names and are never logged
+		if info.route == "" {
+			attrs = append(attrs, slog.String("path", r.URL.Path))
+		}
+		if query := redactQuery(r.URL.Query()); query != "" {
+			attrs = append(attrs, slog.String("query", query))
+		}
+		if lw.message != "" {
+			attrs = append(attrs, slog.String("message", lw.message))
+		}
+		if lw.err != nil {
+			attrs = append(attrs, slog.String("error", lw.err.Error()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	}), "request", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method
+	}))
+}
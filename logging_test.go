@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRedactsSensitiveFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, parseLogLevel("info"))
+
+	logger.Info("login",
+		"email", "alice@example.com",
+		"password", "hunter2",
+		"refresh_token", "abc.def.ghi",
+		"status", 200,
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"alice@example.com", "hunter2", "abc.def.ghi"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log output contains %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"status":200`) {
+		t.Errorf("log output is missing non-sensitive fields: %s", out)
+	}
+
+	query, _ := url.ParseQuery("email=alice%40example.com&token=abc&limit=10")
+	got := redactQuery(query)
+	if strings.Contains(got, "alice") || strings.Contains(got, "abc") || !strings.Contains(got, "limit=10") {
+		t.Errorf("redactQuery() = %q", got)
+	}
+}
@@ -1,30 +1,49 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"sync/atomic"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/Pranay0205/VaultDrive/internal/database"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type ApiConfig struct {
-	apiHits   atomic.Int32
-	dbQueries *database.Queries
-	jwtSecret string
+	db             *sql.DB
+	dbQueries      *database.Queries
+	jwtSecret      string
+	events         *eventBroker
+	userQuotaBytes int64
+	minFreeBytes   int64
+	maxUploadBytes int64
+	chunkSize      int64
+	keyLogKey      ed25519.PrivateKey
+	keyLog         *keyLogCache
+	s3GatewayKey   []byte
 }
 
 func (cfg *ApiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s - User-Agent: %s", r.Method, r.URL.String(), r.UserAgent())
-		cfg.apiHits.Add(1)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		setRequestRoute(r)
+
+		lw, ok := w.(*loggingResponseWriter)
+		if !ok {
+			lw = &loggingResponseWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(lw, r)
+
+		observeRequest(r.Pattern, lw.status, time.Since(start))
 	})
 }
 
@@ -32,8 +51,8 @@ func middlewareCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "X-File-Metadata, X-Wrapped-Key")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, X-Recovery-Secret, X-Request-ID, traceparent, tracestate, X-File-ID, X-Encrypted-Name, X-File-IV, X-File-Salt, X-File-Algorithm, X-Wrapped-Key, X-Key-Version, X-File-Signature")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-File-Metadata, X-Wrapped-Key, X-Wrapped-Key-Group, X-Wrapped-Key-Version, X-File-Signature, X-File-Signature-Key, X-File-Signer, X-File-Version, X-File-Ciphertext-SHA256")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -48,28 +67,186 @@ func main() {
 
 	godotenv.Load()
 
-	dbURL := os.Getenv("DB_URL")
+	// Also routes the standard log package through the JSON handler
+	slog.SetDefault(newLogger(os.Stdout, parseLogLevel(os.Getenv("LOG_LEVEL"))))
 
-	fmt.Printf("Database URL: %s...\n", dbURL[:12])
+	dbURL := os.Getenv("DB_URL")
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	db, err := sql.Open("postgres", dbURL)
+	serverConfig, err := loadServerConfig()
 	if err != nil {
-		fmt.Printf("Error connecting to the database: %v\n", err)
-		return
+		slog.Error("Error loading server settings", "error", err)
+		os.Exit(1)
+	}
+
+	tracerProvider, err := setupTracing()
+	if err != nil {
+		slog.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
+	if tracerProvider != nil {
+		defer tracerProvider.Shutdown(context.Background())
+	}
+
+	// Every query gets a span named after its sqlc query
+	connector, err := pq.NewConnector(dbURL)
+	if err != nil {
+		slog.Error("Error connecting to the database", "error", err)
+		os.Exit(1)
 	}
+	db := sql.OpenDB(traceConnector(connector))
 	defer db.Close()
+	registerDBMetrics(db)
+
+	userQuotaBytes, err := strconv.ParseInt(os.Getenv("USER_QUOTA_BYTES"), 10, 64)
+	if err != nil {
+		userQuotaBytes = 0
+	}
+
+	// Readiness fails when the uploads volume has less space than this
+	minFreeBytes, err := strconv.ParseInt(os.Getenv("STORAGE_MIN_FREE_BYTES"), 10, 64)
+	if err != nil {
+		minFreeBytes = 1 << 30
+	}
+
+	// Hard cap on the size of an upload request
+	maxUploadBytes, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_BYTES"), 10, 64)
+	if err != nil || maxUploadBytes <= 0 {
+		maxUploadBytes = defaultMaxUploadBytes
+	}
+
+	// Splits API uploads into chunks of this many bytes stored once by their
+	// hash; 0 (the default) stores every upload as one blob
+	var chunkSize int64
+	if raw := os.Getenv("CHUNK_SIZE"); raw != "" {
+		chunkSize, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || chunkSize < 0 {
+			slog.Error("Invalid CHUNK_SIZE", "value", raw)
+			os.Exit(1)
+		}
+	}
+
+	// Signs key transparency tree heads; clients pin its public key
+	keyLogKeyPath := os.Getenv("KEY_LOG_KEY")
+	if keyLogKeyPath == "" {
+		keyLogKeyPath = "key_log_key"
+	}
+	keyLogKey, err := loadOrCreateKeyLogKey(keyLogKeyPath)
+	if err != nil {
+		slog.Error("Error loading key log key", "error", err)
+		os.Exit(1)
+	}
 
-	apiConfig := ApiConfig{apiHits: atomic.Int32{}, dbQueries: database.New(db)}
+	// Wraps the private keys of S3 access keys with gateway encryption; keep it
+	// out of database backups
+	s3GatewayKeyPath := os.Getenv("S3_GATEWAY_KEY")
+	if s3GatewayKeyPath == "" {
+		s3GatewayKeyPath = "s3_gateway_key"
+	}
+	s3GatewayKey, err := loadOrCreateS3GatewayKey(s3GatewayKeyPath)
+	if err != nil {
+		slog.Error("Error loading S3 gateway key", "error", err)
+		os.Exit(1)
+	}
 
-	fmt.Println("Connected to the database successfully.")
+	apiConfig := ApiConfig{
+		db:             db,
+		dbQueries:      database.New(db),
+		events:         newEventBroker(),
+		userQuotaBytes: userQuotaBytes,
+		minFreeBytes:   minFreeBytes,
+		maxUploadBytes: maxUploadBytes,
+		chunkSize:      chunkSize,
+		keyLogKey:      keyLogKey,
+		keyLog:         &keyLogCache{},
+		s3GatewayKey:   s3GatewayKey,
+	}
+
+	// Copies blobs to REPLICA_URL, before an upload completes when
+	// REPLICATION_MODE=sync and from a queue otherwise
+	if raw := os.Getenv("REPLICA_URL"); raw != "" {
+		target, err := newReplicaTarget(raw)
+		if err != nil {
+			slog.Error("Invalid REPLICA_URL", "error", err)
+			os.Exit(1)
+		}
+		mode := os.Getenv("REPLICATION_MODE")
+		if mode == "" {
+			mode = "async"
+		}
+		if mode != "sync" && mode != "async" {
+			slog.Error("Invalid REPLICATION_MODE, expected sync or async", "value", mode)
+			os.Exit(1)
+		}
+		replication = newReplicator(target, mode == "sync", apiConfig.dbQueries)
+		slog.Info("Replicating blobs", "target", target.String(), "mode", mode)
+	}
+
+	// Subcommands, e.g. `vaultdrive admin create`, run instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), apiConfig.db, apiConfig.dbQueries, os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	go apiConfig.listenForEvents(dbURL)
+
+	// Serve until SIGINT or SIGTERM, then drain in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var servers []*http.Server
+
+	// Repairs blobs and rows left inconsistent by crashes; 0 disables it
+	reconcileInterval := time.Hour
+	if raw := os.Getenv("RECONCILE_INTERVAL"); raw != "" {
+		reconcileInterval, err = time.ParseDuration(raw)
+		if err != nil {
+			slog.Error("Invalid RECONCILE_INTERVAL", "error", err)
+			os.Exit(1)
+		}
+	}
+	if reconcileInterval > 0 {
+		go apiConfig.runReconciler(ctx, reconcileInterval)
+	}
+
+	// Re-hashes every blob once per SCRUB_INTERVAL, reading at most
+	// SCRUB_BYTES_PER_SECOND; a rate of 0 disables it
+	scrubRate := int64(8 << 20)
+	if raw := os.Getenv("SCRUB_BYTES_PER_SECOND"); raw != "" {
+		scrubRate, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || scrubRate < 0 {
+			slog.Error("Invalid SCRUB_BYTES_PER_SECOND", "value", raw)
+			os.Exit(1)
+		}
+	}
+	scrubInterval := 7 * 24 * time.Hour
+	if raw := os.Getenv("SCRUB_INTERVAL"); raw != "" {
+		scrubInterval, err = time.ParseDuration(raw)
+		if err != nil {
+			slog.Error("Invalid SCRUB_INTERVAL", "error", err)
+			os.Exit(1)
+		}
+	}
+	if scrubRate > 0 {
+		go apiConfig.runScrubber(ctx, scrubRate, scrubInterval)
+	}
+	// Sync mode still queues deletions and retries
+	if replication != nil {
+		go replication.run(ctx)
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("GET /healthz", apiConfig.middlewareMetricsInc(http.HandlerFunc(healthCheckHandler)))
+	mux.Handle("GET /livez", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerLivez)))
+
+	mux.Handle("GET /readyz", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerReadyz)))
+
+	mux.Handle("GET /healthz", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerLivez)))
 
 	mux.Handle("POST /register", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.registerUserHandler)))
 
@@ -81,9 +258,21 @@ func main() {
 
 	mux.Handle("GET /user/public-key", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetPublicKey)))
 
-	mux.Handle("POST /files/upload", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateFiles)))
+	mux.Handle("GET /users/{id}/safety-number", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetSafetyNumber)))
+
+	mux.Handle("GET /keylog/head", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetKeyLogHead)))
+
+	mux.Handle("GET /keylog/entries", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetKeyLogEntries)))
+
+	mux.Handle("GET /keylog/inclusion", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetKeyLogInclusion)))
+
+	mux.Handle("GET /keylog/consistency", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetKeyLogConsistency)))
+
+	mux.Handle("POST /files/upload", withTimeouts(serverConfig.transfer, apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateFiles))))
+
+	mux.Handle("PUT /files/upload", withTimeouts(serverConfig.transfer, apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerPutFile))))
 
-	mux.Handle("GET /files/{id}/download", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDownloadFile)))
+	mux.Handle("GET /files/{id}/download", withTimeouts(serverConfig.transfer, apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDownloadFile))))
 
 	mux.Handle("POST /files/{id}/share", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerShareFile)))
 
@@ -97,20 +286,233 @@ func main() {
 
 	mux.Handle("DELETE /files/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteFile)))
 
-	fmt.Printf("Starting server on port %s...\n", port)
-	err = http.ListenAndServe(":"+port, middlewareCORS(mux))
-	if err != nil {
-		log.Fatalf("Error starting server: %v\n", err)
+	mux.Handle("PUT /files/{id}/name", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerSetFileName)))
+
+	mux.Handle("GET /events", withTimeouts(serverConfig.stream, apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerEvents))))
+
+	mux.Handle("POST /me/tokens", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateAccessToken)))
+
+	mux.Handle("GET /me/tokens", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListAccessTokens)))
+
+	mux.Handle("DELETE /me/tokens/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerRevokeAccessToken)))
+
+	mux.Handle("POST /me/ssh-keys", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerAddSSHKey)))
+
+	mux.Handle("GET /me/ssh-keys", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListSSHKeys)))
+
+	mux.Handle("DELETE /me/ssh-keys/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteSSHKey)))
+
+	mux.Handle("POST /me/s3-keys", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateS3AccessKey)))
+
+	mux.Handle("GET /me/s3-keys", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListS3AccessKeys)))
+
+	mux.Handle("DELETE /me/s3-keys/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteS3AccessKey)))
+
+	mux.Handle("GET /me/devices", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListDevices)))
+
+	mux.Handle("POST /me/devices/{id}/approve", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerApproveDevice)))
+
+	mux.Handle("DELETE /me/devices/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerRevokeDevice)))
+
+	mux.Handle("GET /me/keys", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetKeys)))
+
+	mux.Handle("POST /me/keys/rotate", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerRotateKeys)))
+
+	mux.Handle("GET /me/keys/rewrap", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetRewrapBatch)))
+
+	mux.Handle("POST /me/keys/rewrap", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerSubmitRewrapBatch)))
+
+	mux.Handle("PUT /me/signing-key", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerSetSigningKey)))
+
+	mux.Handle("GET /me/contacts", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListContacts)))
+
+	mux.Handle("POST /me/contacts", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerAddContact)))
+
+	mux.Handle("POST /me/contacts/{id}/verify", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerVerifyContact)))
+
+	mux.Handle("DELETE /me/contacts/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteContact)))
+
+	mux.Handle("GET /me/recovery", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetRecovery)))
+
+	mux.Handle("PUT /me/recovery", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerSetRecovery)))
+
+	mux.Handle("DELETE /me/recovery", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteRecovery)))
+
+	mux.Handle("GET /me/recovery/requests", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListRecoveryRequests)))
+
+	mux.Handle("POST /me/recovery/requests/{id}/approve", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerApproveRecoveryRequest)))
+
+	mux.Handle("POST /recovery/requests", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateRecoveryRequest)))
+
+	mux.Handle("GET /recovery/requests/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetRecoveryRequest)))
+
+	mux.Handle("POST /recovery/requests/{id}/complete", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCompleteRecovery)))
+
+	mux.Handle("GET /admin/users", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminListUsers), roleAdmin, roleAuditor)))
+
+	mux.Handle("POST /admin/users/{id}/disable", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminDisableUser), roleAdmin)))
+
+	mux.Handle("POST /admin/users/{id}/enable", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminEnableUser), roleAdmin)))
+
+	mux.Handle("POST /admin/users/{id}/logout", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminLogoutUser), roleAdmin)))
+
+	mux.Handle("PUT /admin/users/{id}/quota", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminSetQuota), roleAdmin)))
+
+	mux.Handle("PUT /admin/users/{id}/role", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminSetRole), roleAdmin)))
+
+	mux.Handle("GET /admin/stats", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminStorageStats), roleAdmin, roleAuditor)))
+
+	mux.Handle("DELETE /admin/files/{id}", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminDeleteFile), roleAdmin)))
+
+	mux.Handle("PUT /admin/orgs/{id}/quota", apiConfig.middlewareMetricsInc(apiConfig.middlewareRequireRole(http.HandlerFunc(apiConfig.handlerAdminSetOrgQuota), roleAdmin)))
+
+	mux.Handle("POST /orgs", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateOrganization)))
+
+	mux.Handle("GET /orgs", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListOrganizations)))
+
+	mux.Handle("PUT /orgs/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerUpdateOrganization)))
+
+	mux.Handle("DELETE /orgs/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteOrganization)))
+
+	mux.Handle("GET /orgs/{id}/admin", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerOrganizationAdmin)))
+
+	mux.Handle("GET /orgs/{id}/members", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListOrganizationMembers)))
+
+	mux.Handle("POST /orgs/{id}/members", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerAddOrganizationMember)))
+
+	mux.Handle("PUT /orgs/{id}/members/{user_id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerUpdateOrganizationMember)))
+
+	mux.Handle("DELETE /orgs/{id}/members/{user_id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerRemoveOrganizationMember)))
+
+	mux.Handle("GET /orgs/{id}/files", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListOrganizationFiles)))
+
+	mux.Handle("POST /orgs/{id}/files/upload", withTimeouts(serverConfig.transfer, apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerUploadOrganizationFile))))
+
+	mux.Handle("DELETE /orgs/{id}/files/{file_id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteOrganizationFile)))
+
+	mux.Handle("POST /groups", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerCreateGroup)))
+
+	mux.Handle("GET /groups", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListGroups)))
+
+	mux.Handle("GET /groups/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerGetGroup)))
+
+	mux.Handle("DELETE /groups/{id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerDeleteGroup)))
+
+	mux.Handle("POST /groups/{id}/members", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerAddGroupMember)))
+
+	mux.Handle("DELETE /groups/{id}/members/{user_id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerRemoveGroupMember)))
+
+	mux.Handle("POST /groups/{id}/rotate", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerRotateGroupKey)))
+
+	mux.Handle("GET /groups/{id}/files", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerListGroupFiles)))
+
+	mux.Handle("POST /files/{id}/groups", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerShareFileWithGroup)))
+
+	mux.Handle("DELETE /files/{id}/groups/{group_id}", apiConfig.middlewareMetricsInc(http.HandlerFunc(apiConfig.handlerUnshareFileFromGroup)))
+
+	// Optional WebDAV gateway on its own listener
+	if webdavAddr := os.Getenv("WEBDAV_ADDR"); webdavAddr != "" {
+		srv := newHTTPServer(webdavAddr, middlewareRequestLog(gatewayRoute("WebDAV", withTimeouts(serverConfig.transfer, apiConfig.middlewareMetricsInc(apiConfig.newWebDAVGateway())))), serverConfig)
+		servers = append(servers, srv)
+		go func() {
+			slog.Info("Starting WebDAV gateway", "addr", webdavAddr)
+			err := srv.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				slog.Error("Error starting WebDAV gateway", "error", err)
+			}
+		}()
 	}
 
-}
+	// Optional SFTP gateway
+	sftpConns := &sshConns{}
+	if sftpAddr := os.Getenv("SFTP_ADDR"); sftpAddr != "" {
+		hostKeyPath := os.Getenv("SFTP_HOST_KEY")
+		if hostKeyPath == "" {
+			hostKeyPath = "sftp_host_key"
+		}
+		go func() {
+			slog.Info("Starting SFTP gateway", "addr", sftpAddr)
+			err := apiConfig.serveSFTP(ctx, sftpAddr, hostKeyPath, sftpConns)
+			if err != nil {
+				slog.Error("Error starting SFTP gateway", "error", err)
+			}
+		}()
+	}
 
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	// Optional S3-compatible gateway on its own listener
+	if s3Addr := os.Getenv("S3_ADDR"); s3Addr != "" {
+		srv := newHTTPServer(s3Addr, middlewareRequestLog(gatewayRoute("S3", withTimeouts(serverConfig.transfer, apiConfig.middlewareMetricsInc(apiConfig.newS3Gateway())))), serverConfig)
+		servers = append(servers, srv)
+		go func() {
+			slog.Info("Starting S3 gateway", "addr", s3Addr)
+			err := srv.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				slog.Error("Error starting S3 gateway", "error", err)
+			}
+		}()
+	}
+
+	// Metrics get their own listener, or sit on the API behind a token
+	metricsHandler := http.Handler(http.HandlerFunc(apiConfig.handlerMetrics))
+	if metricsToken := os.Getenv("METRICS_TOKEN"); metricsToken != "" {
+		metricsHandler = middlewareMetricsToken(metricsToken, metricsHandler)
+	}
+	if metricsAddr := os.Getenv("METRICS_ADDR"); metricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metricsHandler)
+		srv := newHTTPServer(metricsAddr, withTimeouts(serverConfig.api, metricsMux), serverConfig)
+		servers = append(servers, srv)
+		go func() {
+			slog.Info("Starting metrics listener", "addr", metricsAddr)
+			err := srv.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				slog.Error("Error starting metrics listener", "error", err)
+			}
+		}()
+	} else if os.Getenv("METRICS_TOKEN") != "" {
+		mux.Handle("GET /metrics", metricsHandler)
+	}
+
+	apiServer := newHTTPServer(":"+port, middlewareRequestLog(middlewareCORS(apiConfig.middlewareAccountStatus(withTimeouts(serverConfig.api, mux)))), serverConfig)
+	// Event streams never finish on their own, so end them when draining starts
+	apiServer.RegisterOnShutdown(apiConfig.events.close)
+	servers = append(servers, apiServer)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", port)
+		err := apiServer.ListenAndServe()
+		if err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	failed := false
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down", "timeout", serverConfig.shutdownTimeout.String())
+	case err := <-serverErr:
+		slog.Error("Error starting server", "error", err)
+		failed = true
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), serverConfig.shutdownTimeout)
+	defer cancel()
+	shutdownServers(drainCtx, servers)
+	sftpConns.wait(drainCtx)
+
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(drainCtx); err != nil {
+			slog.Warn("Could not flush traces", "error", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		slog.Warn("Error closing the database pool", "error", err)
+	}
+	slog.Info("Shutdown complete")
+	os.Stdout.Sync()
 
-	response := map[string]string{
-		"status": "ok",
+	if failed {
+		os.Exit(1)
 	}
-	json.NewEncoder(w).Encode(response)
 }
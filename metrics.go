@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics, served on GET /metrics. The endpoint either gets its own
+// listener (METRICS_ADDR) or sits on the API behind METRICS_TOKEN, and is not
+// served at all when neither is set.
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+	metricsFactory  = promauto.With(metricsRegistry)
+
+	httpRequestsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "vaultdrive_http_requests_total",
+		Help: "HTTP requests by route pattern and status.",
+	}, []string{"route", "status"})
+	httpRequestDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vaultdrive_http_request_duration_seconds",
+		Help:    "HTTP request latency by route pattern and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+	uploadedBytesTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Name: "vaultdrive_uploaded_bytes_total",
+		Help: "Bytes written to blob storage.",
+	})
+	downloadedBytesTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Name: "vaultdrive_downloaded_bytes_total",
+		Help: "Bytes read from blob storage for downloads.",
+	})
+	activeUploads = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Name: "vaultdrive_active_uploads",
+		Help: "Uploads currently being written to blob storage.",
+	})
+	storageBytes = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vaultdrive_storage_bytes",
+		Help: "Bytes stored by backend: whole blobs (local), the chunk store (chunks) and the replica, when configured.",
+	}, []string{"backend"})
+	loginFailuresTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "vaultdrive_login_failures_total",
+		Help: "Failed logins by reason.",
+	}, []string{"reason"})
+	scrubbedBytesTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Name: "vaultdrive_scrubbed_bytes_total",
+		Help: "Bytes of stored blobs re-hashed by integrity checks.",
+	})
+	blobIntegrityFailuresTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "vaultdrive_blob_integrity_failures_total",
+		Help: "Blobs that failed an integrity check, by status.",
+	}, []string{"status"})
+	replicatedBytesTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Name: "vaultdrive_replicated_bytes_total",
+		Help: "Bytes copied to the replica.",
+	})
+	replicationFailuresTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "vaultdrive_replication_failures_total",
+		Help: "Failed replica operations by operation.",
+	}, []string{"operation"})
+	replicationQueueLength = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Name: "vaultdrive_replication_queue_length",
+		Help: "Blob operations waiting to be applied to the replica.",
+	})
+	replicationLagSeconds = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Name: "vaultdrive_replication_lag_seconds",
+		Help: "Age of the oldest operation waiting to be applied to the replica.",
+	})
+	replicaFailoversTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "vaultdrive_replica_failovers_total",
+		Help: "Blobs restored from the replica on read, by why the primary copy was rejected.",
+	}, []string{"reason"})
+
+	metricsExposition = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{
+		ErrorLog: metricsErrorLog{},
+	})
+)
+
+// metricsErrorLog passes errors from gathering and writing metrics to slog
+type metricsErrorLog struct{}
+
+func (metricsErrorLog) Println(v ...interface{}) {
+	slog.Warn("Could not write metrics", "error", fmt.Sprint(v...))
+}
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// registerDBMetrics exposes the connection pool statistics of db as go_sql_*
+// metrics
+func registerDBMetrics(db *sql.DB) {
+	metricsRegistry.MustRegister(collectors.NewDBStatsCollector(db, "vaultdrive"))
+}
+
+// observeRequest records a finished request under its route pattern
+func observeRequest(route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	code := strconv.Itoa(status)
+	httpRequestsTotal.WithLabelValues(route, code).Inc()
+	httpRequestDuration.WithLabelValues(route, code).Observe(elapsed.Seconds())
+}
+
+// gatewayRoute gives every request on a gateway listener one route name
+func gatewayRoute(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Pattern = name
+		next.ServeHTTP(w, r)
+	})
+}
+
+// collectStorageMetrics refreshes the stored bytes per backend
+func (cfg *ApiConfig) collectStorageMetrics(ctx context.Context) {
+	stored, err := cfg.dbQueries.GetBackendStorageBytes(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not collect storage metrics", "error", err)
+		return
+	}
+	storageBytes.WithLabelValues("local").Set(float64(stored.BlobBytes))
+	storageBytes.WithLabelValues("chunks").Set(float64(stored.ChunkBytes))
+
+	// The replica is meant to hold every blob and chunk; how far it trails
+	// behind is in the replication lag metrics
+	if replication != nil {
+		storageBytes.WithLabelValues("replica").Set(float64(stored.BlobBytes + stored.ChunkBytes))
+	}
+}
+
+func (cfg *ApiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
+	cfg.collectStorageMetrics(r.Context())
+
+	metricsExposition.ServeHTTP(w, r)
+}
+
+// middlewareMetricsToken only lets through requests carrying the metrics token
+func middlewareMetricsToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := auth.GetBearerToken(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "Missing or invalid metrics token", err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	observeRequest("GET /files/{id}/download", 404, 20*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	metricsExposition.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`vaultdrive_http_requests_total{route="GET /files/{id}/download",status="404"} 1`,
+		`vaultdrive_http_request_duration_seconds_bucket{route="GET /files/{id}/download",status="404",le="0.025"} 1`,
+		"# TYPE vaultdrive_uploaded_bytes_total counter",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+}
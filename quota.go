@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// quotaFor returns userID's storage quota in bytes: the per-user quota set by an
+// admin, or USER_QUOTA_BYTES otherwise. Zero means unlimited.
+func (cfg *ApiConfig) quotaFor(ctx context.Context, userID uuid.UUID) (int64, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.QuotaBytes.Valid {
+		return user.QuotaBytes.Int64, nil
+	}
+	return cfg.userQuotaBytes, nil
+}
+
+// checkStorageAvailable returns errQuotaExceeded when storing size more bytes
+// would take userID over their quota
+func (cfg *ApiConfig) checkStorageAvailable(ctx context.Context, userID uuid.UUID, size int64) error {
+	quota, err := cfg.quotaFor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if quota <= 0 {
+		return nil
+	}
+
+	used, err := cfg.dbQueries.GetTotalFileSizeByOwnerID(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return err
+	}
+	if used+size > quota {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// checkOrgStorageAvailable returns errQuotaExceeded when storing size more bytes
+// in orgID's team space would take it over the organization quota
+func (cfg *ApiConfig) checkOrgStorageAvailable(ctx context.Context, orgID uuid.UUID, size int64) error {
+	org, err := cfg.dbQueries.GetOrganizationByID(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if !org.QuotaBytes.Valid {
+		return nil
+	}
+
+	used, err := cfg.dbQueries.GetTotalFileSizeByOrgID(ctx, uuid.NullUUID{UUID: orgID, Valid: true})
+	if err != nil {
+		return err
+	}
+	if used+size > org.QuotaBytes.Int64 {
+		return errQuotaExceeded
+	}
+	return nil
+}
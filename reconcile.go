@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// The reconciler repairs what a crash or a failed cleanup leaves between the
+// uploads directory and the files table:
+//   - staged blobs whose rows were committed are moved into place, other
+//     staged blobs are removed
+//   - blobs no row points at are removed
+//   - file rows whose blob is gone are deleted, with their keys and shares,
+//     unless fsck quarantined the blob
+//   - chunks no file refers to any more are removed, as are chunk files
+//     without a row
+//
+// Anything younger than reconcileGracePeriod may belong to an upload still in
+// progress and is left alone.
+
+const reconcileGracePeriod = 30 * time.Minute
+
+type reconcileReport struct {
+	PromotedBlobs   []string
+	RemovedStaged   []string
+	OrphanBlobs     []string
+	DanglingFiles   []uuid.UUID
+	CollectedChunks []string
+	OrphanChunks    []string
+}
+
+func (r reconcileReport) empty() bool {
+	return len(r.PromotedBlobs) == 0 && len(r.RemovedStaged) == 0 && len(r.OrphanBlobs) == 0 && len(r.DanglingFiles) == 0 &&
+		len(r.CollectedChunks) == 0 && len(r.OrphanChunks) == 0
+}
+
+// oldBlobs lists the regular files in dir last modified before cutoff,
+// skipping dot files such as readiness probes
+func oldBlobs(dir string, cutoff time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(cutoff) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// reconcileBlobs finds and, unless dryRun, fixes inconsistencies between blobs
+// and rows
+func reconcileBlobs(ctx context.Context, db *sql.DB, queries *database.Queries, dryRun bool) (reconcileReport, error) {
+	var report reconcileReport
+	cutoff := time.Now().Add(-reconcileGracePeriod)
+
+	// Without the uploads directory every row would look dangling
+	if _, err := os.Stat(uploadDir); err != nil {
+		return report, fmt.Errorf("uploads directory is not available: %w", err)
+	}
+
+	paths, err := queries.GetBlobPaths(ctx)
+	if err != nil {
+		return report, fmt.Errorf("listing blob paths: %w", err)
+	}
+	referenced := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		referenced[filepath.Clean(p)] = true
+	}
+
+	staged, err := oldBlobs(stagingDir, cutoff)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return report, err
+	}
+	promoted := map[string]bool{}
+	for _, name := range staged {
+		stagedPath := filepath.Join(stagingDir, name)
+		finalPath := filepath.Join(uploadDir, name)
+		if referenced[finalPath] {
+			report.PromotedBlobs = append(report.PromotedBlobs, finalPath)
+			promoted[finalPath] = true
+			if !dryRun {
+				if err := os.Rename(stagedPath, finalPath); err != nil {
+					return report, err
+				}
+			}
+			continue
+		}
+		report.RemovedStaged = append(report.RemovedStaged, stagedPath)
+		if !dryRun {
+			if err := removeBlob(ctx, stagedPath); err != nil && !os.IsNotExist(err) {
+				return report, err
+			}
+		}
+	}
+
+	blobs, err := oldBlobs(uploadDir, cutoff)
+	if err != nil {
+		return report, err
+	}
+	for _, name := range blobs {
+		blobPath := filepath.Join(uploadDir, name)
+		if referenced[blobPath] {
+			continue
+		}
+		report.OrphanBlobs = append(report.OrphanBlobs, blobPath)
+		if !dryRun {
+			if err := removeBlob(ctx, blobPath); err != nil && !os.IsNotExist(err) {
+				return report, err
+			}
+		}
+	}
+
+	files, err := queries.GetFilePathsCreatedBefore(ctx, cutoff)
+	if err != nil {
+		return report, fmt.Errorf("listing files: %w", err)
+	}
+	for _, f := range files {
+		// Missing chunks are restored from the replica or reported by fsck
+		if promoted[filepath.Clean(f.FilePath)] || isChunked(f.FilePath) {
+			continue
+		}
+		if _, err := os.Stat(f.FilePath); errors.Is(err, fs.ErrNotExist) {
+			report.DanglingFiles = append(report.DanglingFiles, f.ID)
+		}
+	}
+	// A wrong working directory or a half-mounted volume looks the same as
+	// mass data loss; refuse rather than delete most of the table
+	if len(report.DanglingFiles) > 1 && len(report.DanglingFiles)*2 > len(files) {
+		return report, fmt.Errorf("%d of %d files have no blob, refusing to delete them", len(report.DanglingFiles), len(files))
+	}
+	if !dryRun {
+		for _, id := range report.DanglingFiles {
+			if err := queries.DeleteFile(ctx, id); err != nil {
+				return report, fmt.Errorf("deleting file %s: %w", id, err)
+			}
+		}
+	}
+
+	report.CollectedChunks, err = collectChunks(ctx, db, queries, dryRun)
+	if err != nil {
+		return report, err
+	}
+
+	chunkFiles, err := oldBlobs(chunkDir, cutoff)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return report, err
+	}
+	if len(chunkFiles) > 0 {
+		chunks, err := queries.GetChunks(ctx)
+		if err != nil {
+			return report, fmt.Errorf("listing chunks: %w", err)
+		}
+		known := make(map[string]bool, len(chunks))
+		for _, c := range chunks {
+			known[c.Hash] = true
+		}
+		for _, name := range chunkFiles {
+			if known[name] {
+				continue
+			}
+			report.OrphanChunks = append(report.OrphanChunks, chunkPath(name))
+			if !dryRun {
+				if err := removeBlob(ctx, chunkPath(name)); err != nil && !os.IsNotExist(err) {
+					return report, err
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// runReconciler reconciles blobs every interval until ctx is done
+func (cfg *ApiConfig) runReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := reconcileBlobs(ctx, cfg.db, cfg.dbQueries, false)
+			if err != nil {
+				slog.Error("Blob reconciliation failed", "error", err)
+				continue
+			}
+			if !report.empty() {
+				slog.Warn("Reconciled blobs",
+					"promoted_blobs", len(report.PromotedBlobs),
+					"removed_staged_blobs", len(report.RemovedStaged),
+					"orphan_blobs", len(report.OrphanBlobs),
+					"dangling_files", len(report.DanglingFiles),
+					"collected_chunks", len(report.CollectedChunks),
+					"orphan_chunks", len(report.OrphanChunks))
+			}
+		}
+	}
+}
+
+// runReconcile implements `vaultdrive reconcile`
+func runReconcile(ctx context.Context, db *sql.DB, queries *database.Queries, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report inconsistencies without fixing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := reconcileBlobs(ctx, db, queries, *dryRun)
+	for _, p := range report.PromotedBlobs {
+		fmt.Printf("committed staged blob  %s\n", p)
+	}
+	for _, p := range report.RemovedStaged {
+		fmt.Printf("unreferenced staged    %s\n", p)
+	}
+	for _, p := range report.OrphanBlobs {
+		fmt.Printf("orphan blob            %s\n", p)
+	}
+	for _, id := range report.DanglingFiles {
+		fmt.Printf("file without blob      %s\n", id)
+	}
+	for _, hash := range report.CollectedChunks {
+		fmt.Printf("unreferenced chunk     %s\n", hash)
+	}
+	for _, p := range report.OrphanChunks {
+		fmt.Printf("orphan chunk           %s\n", p)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case report.empty():
+		fmt.Println("nothing to reconcile")
+	case *dryRun:
+		fmt.Println("dry run, nothing was changed")
+	}
+	return nil
+}
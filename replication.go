@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Blobs can be copied to a replica: another directory, usually on a separate
+// mount, or an S3-compatible bucket. In sync mode an upload fails unless its
+// blob reached the replica; in async mode new blobs are queued in the database
+// and copied by a background worker, which retries with backoff. Deletions are
+// always queued. A primary copy that is missing, has the wrong size or failed
+// verification is restored from the replica when it is read.
+
+const (
+	replicaPut    = "put"
+	replicaDelete = "delete"
+)
+
+const (
+	replicationBatchSize    = 100
+	replicationPollInterval = 30 * time.Second
+	replicationMaxBackoff   = 10 * time.Minute
+)
+
+// errBlobNotCommitted means a queued blob is still in the staging area
+var errBlobNotCommitted = errors.New("blob is not committed yet")
+
+// replication is the configured replica, nil when blobs are kept in one copy
+var replication *replicator
+
+// replicaTarget stores copies of blobs under their names, see blobName. get
+// returns an error wrapping fs.ErrNotExist for a blob it does not have.
+type replicaTarget interface {
+	put(ctx context.Context, name string, src io.Reader, size int64) error
+	get(ctx context.Context, name string) (io.ReadCloser, error)
+	remove(ctx context.Context, name string) error
+	String() string
+}
+
+// dirTarget keeps copies in a directory
+type dirTarget struct {
+	dir string
+}
+
+func (t *dirTarget) put(ctx context.Context, name string, src io.Reader, size int64) error {
+	dst := filepath.Join(t.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (t *dirTarget) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(t.dir, filepath.FromSlash(name)))
+}
+
+func (t *dirTarget) remove(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(t.dir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (t *dirTarget) String() string {
+	return "dir:" + t.dir
+}
+
+// s3Target keeps copies in an S3-compatible bucket, addressed path-style so
+// it works with self-hosted servers
+type s3Target struct {
+	endpoint    *url.URL
+	bucket      string
+	prefix      string
+	region      string
+	accessKeyID string
+	secretKey   string
+	client      *http.Client
+}
+
+func (t *s3Target) do(ctx context.Context, method, name string, body io.Reader, size int64) (*http.Response, error) {
+	u := *t.endpoint
+	u.Path = path.Join("/", t.bucket, t.prefix, name)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("X-Amz-Content-Sha256", auth.SigV4UnsignedPayload)
+	}
+	auth.SignSigV4(req, t.accessKeyID, t.secretKey, t.region, "s3", time.Now())
+	return t.client.Do(req)
+}
+
+// replicaError reads the error a replica returned
+func replicaError(method string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("replica %s returned %s: %s", method, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (t *s3Target) put(ctx context.Context, name string, src io.Reader, size int64) error {
+	resp, err := t.do(ctx, http.MethodPut, name, src, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return replicaError(http.MethodPut, resp)
+	}
+	return nil
+}
+
+func (t *s3Target) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := t.do(ctx, http.MethodGet, name, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("replica has no %s: %w", name, fs.ErrNotExist)
+	}
+	defer resp.Body.Close()
+	return nil, replicaError(http.MethodGet, resp)
+}
+
+func (t *s3Target) remove(ctx context.Context, name string) error {
+	resp, err := t.do(ctx, http.MethodDelete, name, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return replicaError(http.MethodDelete, resp)
+	}
+	return nil
+}
+
+func (t *s3Target) String() string {
+	return "s3:" + t.endpoint.Host + "/" + path.Join(t.bucket, t.prefix)
+}
+
+// defaultReplicaTimeout bounds one request to an S3 replica, body included,
+// so a hung replica cannot hold up sync uploads
+const defaultReplicaTimeout = 10 * time.Minute
+
+// newReplicaTarget parses REPLICA_URL: file:///path for a directory, or
+// s3://bucket/prefix with the REPLICA_S3_* variables for a bucket
+func newReplicaTarget(rawURL string) (replicaTarget, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, errors.New("file replica needs a path, e.g. file:///mnt/replica")
+		}
+		return &dirTarget{dir: u.Path}, nil
+	case "s3":
+		region := os.Getenv("REPLICA_S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := os.Getenv("REPLICA_S3_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil || endpointURL.Host == "" {
+			return nil, fmt.Errorf("invalid REPLICA_S3_ENDPOINT %q", endpoint)
+		}
+		timeout := defaultReplicaTimeout
+		if raw := os.Getenv("REPLICA_S3_TIMEOUT"); raw != "" {
+			timeout, err = time.ParseDuration(raw)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid REPLICA_S3_TIMEOUT %q", raw)
+			}
+		}
+		target := &s3Target{
+			endpoint:    endpointURL,
+			bucket:      u.Host,
+			prefix:      strings.Trim(u.Path, "/"),
+			region:      region,
+			accessKeyID: os.Getenv("REPLICA_S3_ACCESS_KEY_ID"),
+			secretKey:   os.Getenv("REPLICA_S3_SECRET_ACCESS_KEY"),
+			client:      &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		}
+		if target.bucket == "" || target.accessKeyID == "" || target.secretKey == "" {
+			return nil, errors.New("s3 replica needs a bucket, REPLICA_S3_ACCESS_KEY_ID and REPLICA_S3_SECRET_ACCESS_KEY")
+		}
+		return target, nil
+	}
+	return nil, fmt.Errorf("unsupported replica scheme %q", u.Scheme)
+}
+
+// blobName is the name a stored blob is replicated under: its file name, or
+// chunks/ and its hash for a chunk
+func blobName(blobPath string) string {
+	if filepath.Dir(blobPath) == chunkDir {
+		return path.Join("chunks", filepath.Base(blobPath))
+	}
+	return filepath.Base(blobPath)
+}
+
+type replicator struct {
+	target  replicaTarget
+	sync    bool
+	queries *database.Queries
+	wake    chan struct{}
+}
+
+func newReplicator(target replicaTarget, sync bool, queries *database.Queries) *replicator {
+	return &replicator{
+		target:  target,
+		sync:    sync,
+		queries: queries,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// putFile copies the blob at blobPath to the replica as name
+func (r *replicator) putFile(ctx context.Context, blobPath, name string) error {
+	ctx, span := tracer.Start(ctx, "replica.put", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	file, err := os.Open(blobPath)
+	if err != nil {
+		recordSpanError(span, err)
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		recordSpanError(span, err)
+		return err
+	}
+
+	span.SetAttributes(attribute.Int64("blob.size", info.Size()))
+	err = r.target.put(ctx, name, file, info.Size())
+	recordSpanError(span, err)
+	if err == nil {
+		replicatedBytesTotal.Add(float64(info.Size()))
+	}
+	return err
+}
+
+// replicate copies a newly written blob as name now in sync mode, or queues
+// it. blobPath differs from where name is stored for blobs still staged.
+func (r *replicator) replicate(ctx context.Context, blobPath, name string) error {
+	if r.sync {
+		if err := r.putFile(ctx, blobPath, name); err != nil {
+			replicationFailuresTotal.WithLabelValues(replicaPut).Inc()
+			return fmt.Errorf("replicating blob: %w", err)
+		}
+		return nil
+	}
+	return r.enqueue(ctx, name, replicaPut)
+}
+
+func (r *replicator) enqueue(ctx context.Context, name, operation string) error {
+	err := r.queries.EnqueueReplication(ctx, database.EnqueueReplicationParams{
+		BlobName:   name,
+		Operation:  operation,
+		EnqueuedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("queueing replication: %w", err)
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// run applies queued operations until ctx is done
+func (r *replicator) run(ctx context.Context) {
+	ticker := time.NewTicker(replicationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain applies the operations that are due, and updates the lag metrics
+func (r *replicator) drain(ctx context.Context) {
+	defer r.observeLag(ctx)
+
+	for {
+		jobs, err := r.queries.GetDueReplicationJobs(ctx, database.GetDueReplicationJobsParams{
+			NextAttemptAt: time.Now().UTC(),
+			Limit:         replicationBatchSize,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Could not list replication jobs", "error", err)
+			}
+			return
+		}
+
+		for _, job := range jobs {
+			err := r.process(ctx, job)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = r.queries.CompleteReplicationJob(ctx, database.CompleteReplicationJobParams{
+					BlobName:   job.BlobName,
+					EnqueuedAt: job.EnqueuedAt,
+				})
+				if err != nil {
+					slog.Error("Could not complete replication job", "blob", job.BlobName, "error", err)
+				}
+				continue
+			}
+
+			if !errors.Is(err, errBlobNotCommitted) {
+				replicationFailuresTotal.WithLabelValues(job.Operation).Inc()
+				slog.Warn("Replication failed", "blob", job.BlobName, "operation", job.Operation,
+					"attempts", job.Attempts+1, "error", err)
+			}
+			backoff := min(time.Second<<min(job.Attempts, 10), replicationMaxBackoff)
+			err = r.queries.RetryReplicationJob(ctx, database.RetryReplicationJobParams{
+				BlobName:      job.BlobName,
+				LastError:     sql.NullString{String: err.Error(), Valid: true},
+				NextAttemptAt: time.Now().UTC().Add(backoff),
+			})
+			if err != nil {
+				slog.Error("Could not reschedule replication job", "blob", job.BlobName, "error", err)
+			}
+		}
+
+		if len(jobs) < replicationBatchSize {
+			return
+		}
+	}
+}
+
+func (r *replicator) process(ctx context.Context, job database.ReplicationQueue) error {
+	if job.Operation == replicaDelete {
+		ctx, span := tracer.Start(ctx, "replica.delete", trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+		err := r.target.remove(ctx, job.BlobName)
+		recordSpanError(span, err)
+		return err
+	}
+
+	err := r.putFile(ctx, filepath.Join(uploadDir, filepath.FromSlash(job.BlobName)), job.BlobName)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// A blob still staged is retried once its upload commits; one that is
+	// in neither place was discarded and has nothing left to copy
+	if _, err := os.Stat(filepath.Join(stagingDir, job.BlobName)); err == nil {
+		return errBlobNotCommitted
+	}
+	return nil
+}
+
+func (r *replicator) observeLag(ctx context.Context) {
+	stats, err := r.queries.GetReplicationQueueStats(ctx, time.Now().UTC())
+	if err != nil {
+		return
+	}
+	replicationQueueLength.Set(float64(stats.Pending))
+	replicationLagSeconds.Set(stats.LagSeconds)
+}
+
+// primaryFault says why the primary copy of file should not be served, or
+// returns "" when it looks sound. Only cheap checks are made here: existence,
+// size and the outcome of the last integrity check.
+func (r *replicator) primaryFault(ctx context.Context, file database.File) (string, database.FileIntegrity) {
+	integrity, err := r.queries.GetFileIntegrityByID(ctx, file.ID)
+	if err != nil && err != sql.ErrNoRows {
+		slog.WarnContext(ctx, "Could not read file integrity", "file_id", file.ID, "error", err)
+	}
+
+	info, err := os.Stat(file.FilePath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return integrityMissing, integrity
+	case err == nil && info.Size() != file.FileSize:
+		return "size", integrity
+	case integrity.Status != "" && integrity.Status != integrityOK:
+		return integrity.Status, integrity
+	}
+	return "", integrity
+}
+
+// restore replaces the primary copy of file with the replica's, after
+// checking the replica against the recorded size and hash
+func (r *replicator) restore(ctx context.Context, file database.File, expected sql.NullString) error {
+	ctx, span := tracer.Start(ctx, "replica.restore", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	src, err := r.target.get(ctx, blobName(file.FilePath))
+	if err != nil {
+		recordSpanError(span, err)
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(stagingDir, filepath.Base(file.FilePath)+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		recordSpanError(span, err)
+		return err
+	}
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if size != file.FileSize || (expected.Valid && sum != expected.String) {
+		err := errors.New("replica copy does not match the recorded size and hash")
+		recordSpanError(span, err)
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file.FilePath); err != nil {
+		return err
+	}
+
+	return r.queries.SetFileHash(ctx, database.SetFileHashParams{
+		FileID:    file.ID,
+		Sha256:    sql.NullString{String: sum, Valid: true},
+		CheckedAt: time.Now().UTC(),
+	})
+}
+
+// failover restores the primary copy of file from the replica when it is
+// unfit to serve. It returns the hash recorded for file, so callers holding
+// the whole blob can verify it.
+func (r *replicator) failover(ctx context.Context, file database.File) sql.NullString {
+	reason, integrity := r.primaryFault(ctx, file)
+	if reason != "" {
+		r.restoreFrom(ctx, file, integrity.Sha256, reason)
+	}
+	return integrity.Sha256
+}
+
+func (r *replicator) restoreFrom(ctx context.Context, file database.File, expected sql.NullString, reason string) {
+	if err := r.restore(ctx, file, expected); err != nil {
+		slog.ErrorContext(ctx, "Could not restore blob from replica", "file_id", file.ID, "reason", reason, "error", err)
+		return
+	}
+	replicaFailoversTotal.WithLabelValues(reason).Inc()
+	slog.WarnContext(ctx, "Restored blob from replica", "file_id", file.ID, "reason", reason)
+}
+
+// restoreChunk fetches a missing chunk from the replica. Chunks are named by
+// their hash, so the copy is verified without a database lookup.
+func (r *replicator) restoreChunk(ctx context.Context, hash string) {
+	ctx, span := tracer.Start(ctx, "replica.restore", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	err := r.fetchChunk(ctx, hash)
+	recordSpanError(span, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not restore chunk from replica", "chunk", hash, "error", err)
+		return
+	}
+	replicaFailoversTotal.WithLabelValues(integrityMissing).Inc()
+	slog.WarnContext(ctx, "Restored chunk from replica", "chunk", hash)
+}
+
+func (r *replicator) fetchChunk(ctx context.Context, hash string) error {
+	src, err := r.target.get(ctx, blobName(chunkPath(hash)))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(stagingDir, hash+".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return errors.New("replica copy does not match the chunk hash")
+	}
+	if err := os.MkdirAll(chunkDir, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), chunkPath(hash))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+)
+
+func TestNewReplicaTarget(t *testing.T) {
+	target, err := newReplicaTarget("file:///mnt/replica")
+	if err != nil || target.String() != "dir:/mnt/replica" {
+		t.Errorf("newReplicaTarget(file) = %v, %v", target, err)
+	}
+
+	if _, err := newReplicaTarget("s3://backups/vaultdrive"); err == nil {
+		t.Error("newReplicaTarget(s3) accepted missing credentials")
+	}
+	t.Setenv("REPLICA_S3_ACCESS_KEY_ID", "AKID")
+	t.Setenv("REPLICA_S3_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("REPLICA_S3_ENDPOINT", "http://minio:9000")
+	target, err = newReplicaTarget("s3://backups/vaultdrive/")
+	if err != nil || target.String() != "s3:minio:9000/backups/vaultdrive" {
+		t.Errorf("newReplicaTarget(s3) = %v, %v", target, err)
+	}
+
+	if _, err := newReplicaTarget("ftp://host/dir"); err == nil {
+		t.Error("newReplicaTarget accepted an unsupported scheme")
+	}
+}
+
+func TestS3TargetRoundTrip(t *testing.T) {
+	const secret = "replica-secret"
+	var mu sync.Mutex
+	objects := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred, err := auth.ParseSigV4Authorization(r.Header.Get("Authorization"))
+		if err == nil {
+			err = auth.VerifySigV4(r, cred, secret)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("REPLICA_S3_ACCESS_KEY_ID", "AKID")
+	t.Setenv("REPLICA_S3_SECRET_ACCESS_KEY", secret)
+	t.Setenv("REPLICA_S3_ENDPOINT", server.URL)
+	target, err := newReplicaTarget("s3://backups/blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := target.put(ctx, "a.bin", strings.NewReader("ciphertext"), 10); err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+	if _, ok := objects["/backups/blobs/a.bin"]; !ok {
+		t.Fatalf("put() stored %v", objects)
+	}
+
+	body, err := target.get(ctx, "a.bin")
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "ciphertext" {
+		t.Errorf("get() = %q", data)
+	}
+
+	if err := target.remove(ctx, "a.bin"); err != nil {
+		t.Fatalf("remove() error = %v", err)
+	}
+	if _, err := target.get(ctx, "a.bin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("get() after remove error = %v, want fs.ErrNotExist", err)
+	}
+}
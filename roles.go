@@ -0,0 +1,113 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/google/uuid"
+)
+
+const (
+	roleUser    = "user"
+	roleAdmin   = "admin"
+	roleAuditor = "auditor"
+)
+
+// claimsRole treats tokens issued before roles existed as regular users
+func claimsRole(claims *auth.Claims) string {
+	if claims.Role == "" {
+		return roleUser
+	}
+	return claims.Role
+}
+
+// middlewareAccountStatus rejects access tokens of disabled accounts, tokens issued
+// before a forced logout, tokens whose role no longer matches the account, and
+// tokens of revoked or foreign devices. Tokens of devices still waiting for
+// approval only reach GET /me/devices, where they pick up their wrapped key.
+// Requests without a valid token pass through for the handler to reject.
+func (cfg *ApiConfig) middlewareAccountStatus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			// EventSource cannot set headers, so /events also takes the token as a query parameter
+			token = r.URL.Query().Get("token")
+		}
+
+		claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := cfg.dbQueries.GetUserByID(r.Context(), uuid.MustParse(claims.Subject))
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error retrieving user", err)
+			return
+		}
+
+		if user.DisabledAt.Valid {
+			respondWithError(w, http.StatusForbidden, "Account disabled", nil)
+			return
+		}
+
+		if user.TokensValidAfter.Valid && claims.IssuedAt != nil && claims.IssuedAt.Time.Before(user.TokensValidAfter.Time) {
+			respondWithError(w, http.StatusUnauthorized, "Session has been signed out", nil)
+			return
+		}
+
+		if claimsRole(claims) != user.Role {
+			respondWithError(w, http.StatusUnauthorized, "Role has changed, please log in again", nil)
+			return
+		}
+
+		if claims.DeviceID != "" {
+			device, err := cfg.dbQueries.GetDeviceByID(r.Context(), uuid.MustParse(claims.DeviceID))
+			if err == sql.ErrNoRows || (err == nil && device.UserID != user.ID) {
+				respondWithError(w, http.StatusUnauthorized, "Device has been revoked", err)
+				return
+			}
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error retrieving device", err)
+				return
+			}
+			if !device.ApprovedAt.Valid && !(r.Method == http.MethodGet && r.URL.Path == "/me/devices") {
+				respondWithError(w, http.StatusForbidden, "Device is waiting for approval", nil)
+				return
+			}
+		}
+
+		setRequestUser(r.Context(), user.ID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareRequireRole only lets through access tokens carrying one of roles
+func (cfg *ApiConfig) middlewareRequireRole(next http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token", err)
+			return
+		}
+
+		claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+			return
+		}
+
+		if !slices.Contains(roles, claimsRole(claims)) {
+			respondWithError(w, http.StatusForbidden, "Insufficient permissions", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
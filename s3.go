@@ -0,0 +1,1325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/auth"
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+)
+
+// The S3 gateway serves path-style requests (http://host/bucket/key) signed with
+// access keys from /me/s3-keys. Buckets are a user's top-level folders and object
+// keys map onto the folders below them. Keys issued with gateway encryption get
+// objects encrypted exactly like WebDAV uploads; other keys store and return the
+// bytes as sent, for clients that encrypt before uploading.
+
+const (
+	s3Namespace      = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3MaxClockSkew   = 15 * time.Minute
+	s3DefaultMaxKeys = 1000
+	s3MaxPartNumber  = 10000
+
+	// clientSideAlgorithm marks objects stored as sent, without server-side encryption
+	clientSideAlgorithm = "client-side"
+)
+
+type s3Gateway struct {
+	cfg      *ApiConfig
+	sessions *gatewaySessionCache
+}
+
+func (cfg *ApiConfig) newS3Gateway() *s3Gateway {
+	return &s3Gateway{
+		cfg:      cfg,
+		sessions: newGatewaySessionCache(),
+	}
+}
+
+// s3Request is an authenticated request resolved to a bucket and object key
+type s3Request struct {
+	vfs    *vaultFS
+	bucket string
+	key    string
+	body   io.Reader
+}
+
+// gatewayEncryption reports whether the session can encrypt and decrypt objects
+func (req *s3Request) gatewayEncryption() bool {
+	return req.vfs.session.privateKey != nil
+}
+
+func (g *s3Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	session, body, err := g.authenticate(r)
+	if err != nil {
+		var s3Err *s3Error
+		if !errors.As(err, &s3Err) {
+			s3Err = &s3Error{Status: http.StatusForbidden, Code: "AccessDenied", Message: "Access Denied"}
+		}
+		writeS3Error(w, r, s3Err)
+		return
+	}
+	setRequestUser(r.Context(), session.user.ID)
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	req := &s3Request{
+		vfs:    &vaultFS{cfg: g.cfg, session: session},
+		bucket: bucket,
+		key:    key,
+		body:   body,
+	}
+
+	query := r.URL.Query()
+	switch {
+	case bucket == "":
+		if r.Method != http.MethodGet {
+			err = errS3NotImplemented
+			break
+		}
+		err = g.listBuckets(w, r, req)
+	case key == "":
+		err = g.serveBucket(w, r, req, query)
+	default:
+		err = g.serveObject(w, r, req, query)
+	}
+
+	if err != nil {
+		var s3Err *s3Error
+		switch {
+		case errors.As(err, &s3Err):
+		case errors.Is(err, errQuotaExceeded):
+			s3Err = &s3Error{Status: http.StatusForbidden, Code: "QuotaExceeded", Message: "Storing this object would exceed your storage quota."}
+		case errors.Is(err, auth.ErrSigV4ChunkTooLarge):
+			s3Err = errS3TooLarge
+		case errors.Is(err, errFileKeyUnavailable):
+			s3Err = &s3Error{Status: http.StatusForbidden, Code: "AccessDenied", Message: "This object can only be decrypted by the web client"}
+		default:
+			slog.ErrorContext(r.Context(), "S3 request failed", "error", err)
+			s3Err = &s3Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: "We encountered an internal error. Please try again."}
+		}
+		writeS3Error(w, r, s3Err)
+	}
+}
+
+func (g *s3Gateway) serveBucket(w http.ResponseWriter, r *http.Request, req *s3Request, query url.Values) error {
+	_, hasLocation := query["location"]
+	_, hasDelete := query["delete"]
+
+	switch r.Method {
+	case http.MethodPut:
+		return g.createBucket(w, r, req)
+	case http.MethodHead:
+		if _, err := g.bucketFolder(r.Context(), req); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case http.MethodDelete:
+		return g.deleteBucket(w, r, req)
+	case http.MethodGet:
+		if hasLocation {
+			return g.bucketLocation(w, r, req)
+		}
+		return g.listObjects(w, r, req)
+	case http.MethodPost:
+		if hasDelete {
+			return g.deleteObjects(w, r, req)
+		}
+	}
+	return errS3NotImplemented
+}
+
+func (g *s3Gateway) serveObject(w http.ResponseWriter, r *http.Request, req *s3Request, query url.Values) error {
+	_, hasUploads := query["uploads"]
+	uploadID := query.Get("uploadId")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return g.getObject(w, r, req)
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return errS3NotImplemented
+		}
+		if uploadID != "" {
+			return g.uploadPart(w, r, req, uploadID)
+		}
+		return g.putObject(w, r, req)
+	case http.MethodDelete:
+		if uploadID != "" {
+			return g.abortMultipartUpload(w, r, req, uploadID)
+		}
+		return g.deleteObject(w, r, req)
+	case http.MethodPost:
+		if hasUploads {
+			return g.createMultipartUpload(w, r, req)
+		}
+		if uploadID != "" {
+			return g.completeMultipartUpload(w, r, req, uploadID)
+		}
+	}
+	return errS3NotImplemented
+}
+
+// authenticate checks the request's SigV4 signature and returns the session for
+// its access key along with a body reader that verifies the signed payload
+func (g *s3Gateway) authenticate(r *http.Request) (*gatewaySession, io.Reader, error) {
+	ctx := r.Context()
+
+	cred, err := auth.ParseSigV4Authorization(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, nil, &s3Error{Status: http.StatusForbidden, Code: "AccessDenied", Message: "Requests must be signed with AWS Signature Version 4"}
+	}
+
+	accessKey, err := g.cfg.dbQueries.GetS3AccessKeyByAccessKeyID(ctx, cred.AccessKeyID)
+	if err != nil {
+		return nil, nil, &s3Error{Status: http.StatusForbidden, Code: "InvalidAccessKeyId", Message: "The AWS Access Key Id you provided does not exist in our records."}
+	}
+
+	if err := auth.VerifySigV4(r, cred, accessKey.SecretKey); err != nil {
+		return nil, nil, &s3Error{Status: http.StatusForbidden, Code: "SignatureDoesNotMatch", Message: "The request signature we calculated does not match the signature you provided."}
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(auth.SigV4TimeFormat, amzDate)
+	if err != nil || time.Since(signedAt).Abs() > s3MaxClockSkew {
+		return nil, nil, &s3Error{Status: http.StatusForbidden, Code: "RequestTimeTooSkewed", Message: "The difference between the request time and the current time is too large."}
+	}
+
+	var body io.Reader
+	switch payloadHash := r.Header.Get("X-Amz-Content-Sha256"); payloadHash {
+	case auth.SigV4UnsignedPayload:
+		body = r.Body
+	case auth.SigV4StreamingPayload:
+		signingKey := auth.SigV4SigningKey(accessKey.SecretKey, cred.Date, cred.Region, cred.Service)
+		body = auth.NewSigV4ChunkedReader(r.Body, signingKey, amzDate, cred.Scope(), cred.Signature)
+	case auth.SigV4UnsignedTrailer:
+		body = auth.NewUnsignedChunkedReader(r.Body)
+	default:
+		if strings.HasPrefix(payloadHash, "STREAMING-") {
+			return nil, nil, errS3NotImplemented
+		}
+		if payloadHash == "" {
+			payloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+		}
+		body = &verifyingReader{
+			r:        r.Body,
+			hash:     sha256.New(),
+			expected: payloadHash,
+			err:      &s3Error{Status: http.StatusBadRequest, Code: "XAmzContentSHA256Mismatch", Message: "The provided 'x-amz-content-sha256' header does not match what was computed."},
+		}
+	}
+
+	if session := g.sessions.get(accessKey.ID.String()); session != nil && g.cfg.sessionCurrent(ctx, session) {
+		return session, body, nil
+	}
+
+	user, err := g.cfg.dbQueries.GetUserByID(ctx, accessKey.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.DisabledAt.Valid {
+		return nil, nil, &s3Error{Status: http.StatusForbidden, Code: "AccountProblem", Message: "This account has been disabled."}
+	}
+
+	session := &gatewaySession{user: user}
+	if accessKey.PrivateKeyEncrypted.Valid {
+		privateKeyPEM, err := decryptPrivateKey(accessKey.PrivateKeyEncrypted.String, g.cfg.s3PrivateKeyPassword(accessKey.SecretKey))
+		if err != nil {
+			return nil, nil, err
+		}
+		session.privateKey, err = parsePrivateKey(privateKeyPEM)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = g.cfg.dbQueries.TouchS3AccessKey(ctx, database.TouchS3AccessKeyParams{
+		ID:         accessKey.ID,
+		LastUsedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Could not update S3 access key last use", "error", err)
+	}
+
+	g.sessions.put(accessKey.ID.String(), session)
+	return session, body, nil
+}
+
+// verifyingReader fails at EOF when the data read does not hash to expected
+type verifyingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string
+	err      error
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(v.hash.Sum(nil)) != v.expected {
+		return n, v.err
+	}
+	return n, err
+}
+
+// Buckets
+
+func (g *s3Gateway) bucketFolder(ctx context.Context, req *s3Request) (uuid.NullUUID, error) {
+	folderID, err := req.vfs.resolveFolder(ctx, []string{req.bucket})
+	if errors.Is(err, os.ErrNotExist) {
+		return uuid.NullUUID{}, &s3Error{Status: http.StatusNotFound, Code: "NoSuchBucket", Message: "The specified bucket does not exist"}
+	}
+	return folderID, err
+}
+
+func (g *s3Gateway) listBuckets(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	folders, err := g.cfg.dbQueries.GetFoldersByParent(r.Context(), database.GetFoldersByParentParams{
+		OwnerID: req.vfs.session.user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	type bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	type owner struct {
+		ID          string `xml:"ID"`
+		DisplayName string `xml:"DisplayName"`
+	}
+	type listAllMyBucketsResult struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Owner   owner    `xml:"Owner"`
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}
+
+	result := listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: owner{ID: req.vfs.session.user.ID.String(), DisplayName: req.vfs.session.user.Username},
+	}
+	for _, folder := range folders {
+		result.Buckets = append(result.Buckets, bucket{Name: folder.Name, CreationDate: s3Time(folder.CreatedAt)})
+	}
+	return writeS3XML(w, http.StatusOK, result)
+}
+
+func (g *s3Gateway) createBucket(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	err := req.vfs.Mkdir(r.Context(), req.bucket, 0755)
+	if errors.Is(err, os.ErrExist) {
+		return &s3Error{Status: http.StatusConflict, Code: "BucketAlreadyOwnedByYou", Message: "Your previous request to create the named bucket succeeded and you already own it."}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", "/"+req.bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (g *s3Gateway) deleteBucket(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	folderID, err := g.bucketFolder(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	dir := &vaultDir{vfs: req.vfs, ctx: r.Context(), entry: vaultEntry{folder: &database.Folder{ID: folderID.UUID}}}
+	children, err := dir.Readdir(1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(children) > 0 {
+		return &s3Error{Status: http.StatusConflict, Code: "BucketNotEmpty", Message: "The bucket you tried to delete is not empty"}
+	}
+
+	if err := g.cfg.dbQueries.DeleteFolder(r.Context(), folderID.UUID); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (g *s3Gateway) bucketLocation(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	if _, err := g.bucketFolder(r.Context(), req); err != nil {
+		return err
+	}
+
+	type locationConstraint struct {
+		XMLName xml.Name `xml:"LocationConstraint"`
+		Xmlns   string   `xml:"xmlns,attr"`
+	}
+	return writeS3XML(w, http.StatusOK, locationConstraint{Xmlns: s3Namespace})
+}
+
+// Listing
+
+// s3Entry is a listed object, or a common prefix when file is nil
+type s3Entry struct {
+	key  string
+	file *database.File
+}
+
+func (g *s3Gateway) listObjects(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	bucketID, err := g.bucketFolder(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	listV2 := query.Get("list-type") == "2"
+
+	maxKeys := s3DefaultMaxKeys
+	if raw := query.Get("max-keys"); raw != "" {
+		maxKeys, err = strconv.Atoi(raw)
+		if err != nil || maxKeys < 0 {
+			return &s3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "Invalid max-keys"}
+		}
+		maxKeys = min(maxKeys, s3DefaultMaxKeys)
+	}
+
+	after := query.Get("marker")
+	if listV2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				return &s3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "The continuation token provided is incorrect"}
+			}
+			after = string(decoded)
+		}
+	}
+
+	// Only the folder holding the prefix's directory part needs to be walked
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	entries := []s3Entry{}
+	if parts, ok := splitS3Key(dir); ok {
+		folderID := bucketID
+		if len(parts) > 0 {
+			folderID, err = req.vfs.resolveFolder(ctx, append([]string{req.bucket}, parts...))
+		}
+		if err == nil {
+			err = g.collect(ctx, req.vfs, folderID, dir, prefix, delimiter, &entries)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	type object struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	type listBucketResult struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Xmlns                 string         `xml:"xmlns,attr"`
+		Name                  string         `xml:"Name"`
+		Prefix                string         `xml:"Prefix"`
+		Delimiter             string         `xml:"Delimiter,omitempty"`
+		Marker                *string        `xml:"Marker,omitempty"`
+		NextMarker            string         `xml:"NextMarker,omitempty"`
+		StartAfter            string         `xml:"StartAfter,omitempty"`
+		ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+		KeyCount              *int           `xml:"KeyCount,omitempty"`
+		MaxKeys               int            `xml:"MaxKeys"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		Contents              []object       `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+	}
+
+	result := listBucketResult{
+		Xmlns:     s3Namespace,
+		Name:      req.bucket,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   maxKeys,
+	}
+
+	count := 0
+	last := ""
+	for _, entry := range entries {
+		key := entry.key
+		isPrefix := entry.file == nil
+		if delimiter != "" && !isPrefix {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				key = key[:len(prefix)+i+len(delimiter)]
+				isPrefix = true
+			}
+		}
+		if key <= after || (isPrefix && key == last) {
+			continue
+		}
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: key})
+		} else {
+			result.Contents = append(result.Contents, object{
+				Key:          key,
+				LastModified: s3Time(entry.file.UpdatedAt),
+				ETag:         `"` + s3ETag(*entry.file) + `"`,
+				Size:         req.objectSize(*entry.file),
+				StorageClass: "STANDARD",
+			})
+		}
+		last = key
+		count++
+	}
+
+	if listV2 {
+		result.KeyCount = &count
+		result.StartAfter = query.Get("start-after")
+		result.ContinuationToken = query.Get("continuation-token")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		marker := query.Get("marker")
+		result.Marker = &marker
+		if result.IsTruncated {
+			result.NextMarker = last
+		}
+	}
+
+	return writeS3XML(w, http.StatusOK, result)
+}
+
+// collect gathers the objects below folderID whose keys start with prefix. With a
+// "/" delimiter subfolders become common prefixes instead of being walked.
+func (g *s3Gateway) collect(ctx context.Context, vfs *vaultFS, folderID uuid.NullUUID, keyPrefix, prefix, delimiter string, entries *[]s3Entry) error {
+	folders, err := g.cfg.dbQueries.GetFoldersByParent(ctx, database.GetFoldersByParentParams{
+		OwnerID:  vfs.session.user.ID,
+		ParentID: folderID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range folders {
+		key := keyPrefix + folder.Name + "/"
+		if !strings.HasPrefix(key, prefix) && !strings.HasPrefix(prefix, key) {
+			continue
+		}
+		if delimiter == "/" && strings.HasPrefix(key, prefix) {
+			*entries = append(*entries, s3Entry{key: key})
+			continue
+		}
+		err := g.collect(ctx, vfs, uuid.NullUUID{UUID: folder.ID, Valid: true}, key, prefix, delimiter, entries)
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := vfs.folderFiles(ctx, folderID)
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		key := keyPrefix + files[i].name
+		if strings.HasPrefix(key, prefix) {
+			*entries = append(*entries, s3Entry{key: key, file: &files[i].file})
+		}
+	}
+	return nil
+}
+
+// Objects
+
+// objectPath splits the request's key into folder names below the bucket and the
+// object name; keys ending in "/" name folders and have an empty object name
+func (req *s3Request) objectPath() ([]string, string, error) {
+	parts, ok := splitS3Key(req.key)
+	if !ok || len(parts) == 0 {
+		return nil, "", &s3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "Object keys must be valid folder paths"}
+	}
+
+	folders := append([]string{req.bucket}, parts...)
+	if strings.HasSuffix(req.key, "/") {
+		return folders, "", nil
+	}
+	return folders[:len(folders)-1], parts[len(parts)-1], nil
+}
+
+// splitS3Key splits a key on "/", rejecting empty, "." and ".." segments
+func splitS3Key(key string) ([]string, bool) {
+	key = strings.TrimSuffix(key, "/")
+	if key == "" {
+		return nil, true
+	}
+
+	parts := strings.Split(key, "/")
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return nil, false
+		}
+	}
+	return parts, true
+}
+
+// existingObject returns the file a write to folders/name replaces, if any
+func (req *s3Request) existingObject(ctx context.Context, folders []string, name string) (*database.File, error) {
+	entry, err := req.vfs.lookup(ctx, path.Join(append(folders, name)...))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.folder != nil {
+		return nil, &s3Error{Status: http.StatusConflict, Code: "InvalidRequest", Message: "A folder already exists under this key"}
+	}
+	return entry.file, nil
+}
+
+// lookupObject finds the newest file stored under the request's key
+func (g *s3Gateway) lookupObject(ctx context.Context, req *s3Request) (*database.File, error) {
+	if _, err := g.bucketFolder(ctx, req); err != nil {
+		return nil, err
+	}
+
+	folders, name, err := req.objectPath()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errS3NoSuchKey
+	}
+
+	folderID, err := req.vfs.resolveFolder(ctx, folders)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errS3NoSuchKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := req.vfs.findFile(ctx, folderID, name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errS3NoSuchKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (g *s3Gateway) getObject(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	ctx := r.Context()
+
+	// Folder markers read as empty objects
+	if strings.HasSuffix(req.key, "/") {
+		folders, _, err := req.objectPath()
+		if err != nil {
+			return err
+		}
+		if _, err := req.vfs.resolveFolder(ctx, folders); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return errS3NoSuchKey
+			}
+			return err
+		}
+		w.Header().Set("Content-Type", "application/x-directory")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	file, err := g.lookupObject(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	var content io.ReadSeeker
+	if req.decrypts(*file) {
+		plaintext, err := g.cfg.loadFile(ctx, req.vfs.session, *file)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(plaintext)
+	} else {
+		blob, err := g.cfg.openBlob(ctx, *file)
+		if err != nil {
+			return err
+		}
+		defer blob.Close()
+		content = blob
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(req.key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+s3ETag(*file)+`"`)
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	http.ServeContent(w, r, "", file.UpdatedAt, content)
+	return nil
+}
+
+func (g *s3Gateway) putObject(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	ctx := r.Context()
+
+	if _, err := g.bucketFolder(ctx, req); err != nil {
+		return err
+	}
+
+	folders, name, err := req.objectPath()
+	if err != nil {
+		return err
+	}
+
+	folderID, err := req.vfs.mkdirAll(ctx, folders)
+	if err != nil {
+		return err
+	}
+
+	// A trailing slash creates a folder, which is all a folder marker needs to be
+	if name == "" {
+		io.Copy(io.Discard, req.body)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(md5.New().Sum(nil))+`"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	existing, err := req.existingObject(ctx, folders, name)
+	if err != nil {
+		return err
+	}
+
+	blob, metadata, wrappedKey, etag, err := req.saveBody(r, name)
+	if err != nil {
+		return err
+	}
+	defer blob.discard()
+
+	// A multipart-style ETag tells clients not to compare it with the MD5 of what
+	// they sent, since with gateway encryption it hashes the ciphertext instead
+	if req.gatewayEncryption() {
+		etag += "-1"
+	}
+
+	metadata, err = s3Metadata(metadata, etag)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.cfg.recordFile(ctx, req.vfs.session.user, folderID, name, blob, metadata, wrappedKey)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		if err := g.cfg.removeFile(ctx, *existing); err != nil {
+			slog.WarnContext(ctx, "S3 could not remove replaced object", "file_id", existing.ID, "error", err)
+		}
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// saveBody stages the request body as a blob, encrypting it first when the
+// session has gateway encryption. It returns the blob, which the caller must
+// commit or discard, its metadata, wrapped key and the hex MD5 of the stored
+// bytes. Bodies are capped at cfg.maxUploadBytes.
+func (req *s3Request) saveBody(r *http.Request, name string) (*stagedBlob, string, string, string, error) {
+	maxBytes := req.vfs.cfg.maxUploadBytes
+	if r.ContentLength > maxBytes {
+		return nil, "", "", "", errS3TooLarge
+	}
+	if decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil && decoded > maxBytes {
+		return nil, "", "", "", errS3TooLarge
+	}
+
+	bodyMD5 := md5.New()
+	body := io.TeeReader(io.LimitReader(req.body, maxBytes+1), bodyMD5)
+
+	if !req.gatewayEncryption() {
+		blob, err := stageBlob(r.Context(), body)
+		if err != nil {
+			return nil, "", "", "", err
+		}
+		if blob.size > maxBytes {
+			blob.discard()
+			return nil, "", "", "", errS3TooLarge
+		}
+		if err := checkContentMD5(r, bodyMD5); err != nil {
+			blob.discard()
+			return nil, "", "", "", err
+		}
+		return blob, "", "", hex.EncodeToString(bodyMD5.Sum(nil)), nil
+	}
+
+	plaintext, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", "", "", err
+	}
+	if int64(len(plaintext)) > maxBytes {
+		return nil, "", "", "", errS3TooLarge
+	}
+	if err := checkContentMD5(r, bodyMD5); err != nil {
+		return nil, "", "", "", err
+	}
+
+	ciphertext, metadata, wrappedKey, err := encryptFileForRecipient(plaintext, req.vfs.session.user.PublicKey)
+	if err != nil {
+		return nil, "", "", "", err
+	}
+
+	blob, err := stageBlob(r.Context(), bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, "", "", "", err
+	}
+
+	sum := md5.Sum(ciphertext)
+	return blob, metadata, wrappedKey, hex.EncodeToString(sum[:]), nil
+}
+
+// checkContentMD5 compares the body's MD5 with the optional Content-MD5 header
+func checkContentMD5(r *http.Request, bodyMD5 hash.Hash) error {
+	contentMD5 := r.Header.Get("Content-MD5")
+	if contentMD5 == "" {
+		return nil
+	}
+	if contentMD5 != base64.StdEncoding.EncodeToString(bodyMD5.Sum(nil)) {
+		return &s3Error{Status: http.StatusBadRequest, Code: "BadDigest", Message: "The Content-MD5 you specified did not match what we received."}
+	}
+	return nil
+}
+
+func (g *s3Gateway) deleteObject(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	if err := g.removeObject(r.Context(), req); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// removeObject deletes the object under the request's key. Deleting a missing key
+// succeeds, and folder markers only remove folders that are already empty.
+func (g *s3Gateway) removeObject(ctx context.Context, req *s3Request) error {
+	if strings.HasSuffix(req.key, "/") {
+		folders, _, err := req.objectPath()
+		if err != nil {
+			return err
+		}
+		entry, err := req.vfs.lookup(ctx, path.Join(folders...))
+		if err != nil || entry.folder == nil {
+			return nil
+		}
+		dir := &vaultDir{vfs: req.vfs, ctx: ctx, entry: entry}
+		if children, _ := dir.Readdir(1); len(children) > 0 {
+			return nil
+		}
+		return g.cfg.dbQueries.DeleteFolder(ctx, entry.folder.ID)
+	}
+
+	file, err := g.lookupObject(ctx, req)
+	if errors.Is(err, errS3NoSuchKey) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return g.cfg.removeFile(ctx, *file)
+}
+
+func (g *s3Gateway) deleteObjects(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	if _, err := g.bucketFolder(r.Context(), req); err != nil {
+		return err
+	}
+
+	var params struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	data, err := io.ReadAll(req.body)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, &params); err != nil {
+		return errS3MalformedXML
+	}
+
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	type deleteError struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	type deleteResult struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Xmlns   string        `xml:"xmlns,attr"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}
+
+	result := deleteResult{Xmlns: s3Namespace}
+	for _, object := range params.Objects {
+		objectReq := &s3Request{vfs: req.vfs, bucket: req.bucket, key: object.Key}
+		if err := g.removeObject(r.Context(), objectReq); err != nil {
+			slog.ErrorContext(r.Context(), "S3 could not delete object", "error", err)
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: "InternalError", Message: "Could not delete object"})
+			continue
+		}
+		if !params.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+		}
+	}
+	return writeS3XML(w, http.StatusOK, result)
+}
+
+// decrypts reports whether reads of file should return plaintext. Objects stored
+// without gateway encryption, and every object read with a key that has none,
+// are returned exactly as stored.
+func (req *s3Request) decrypts(file database.File) bool {
+	if !req.gatewayEncryption() {
+		return false
+	}
+	var metadata fileMetadata
+	json.Unmarshal([]byte(file.EncryptedMetadata.String), &metadata)
+	return metadata.Algorithm != clientSideAlgorithm
+}
+
+// objectSize is the size a read of file returns
+func (req *s3Request) objectSize(file database.File) int64 {
+	if req.decrypts(file) {
+		return file.FileSize - gcmTagSize
+	}
+	return file.FileSize
+}
+
+// s3ETag returns the ETag stored with an object, or a stable opaque one for
+// files uploaded through other interfaces
+func s3ETag(file database.File) string {
+	var metadata fileMetadata
+	json.Unmarshal([]byte(file.EncryptedMetadata.String), &metadata)
+	if metadata.ETag != "" {
+		return metadata.ETag
+	}
+	return strings.ReplaceAll(file.ID.String(), "-", "") + "-1"
+}
+
+// s3Metadata adds etag to an object's metadata JSON. Empty metadata marks an
+// object stored without gateway encryption.
+func s3Metadata(metadataJSON, etag string) (string, error) {
+	metadata := fileMetadata{Algorithm: clientSideAlgorithm}
+	if metadataJSON != "" {
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return "", err
+		}
+	}
+	metadata.ETag = etag
+	updated, err := json.Marshal(metadata)
+	return string(updated), err
+}
+
+// mkdirAll resolves folder names from the root, creating any that are missing
+func (vfs *vaultFS) mkdirAll(ctx context.Context, parts []string) (uuid.NullUUID, error) {
+	parentID := uuid.NullUUID{}
+	for _, part := range parts {
+		folder, err := vfs.cfg.dbQueries.GetFolderByName(ctx, database.GetFolderByNameParams{
+			OwnerID:  vfs.session.user.ID,
+			ParentID: parentID,
+			Name:     part,
+		})
+		if err == sql.ErrNoRows {
+			folder, err = vfs.cfg.dbQueries.CreateFolder(ctx, database.CreateFolderParams{
+				OwnerID:   vfs.session.user.ID,
+				ParentID:  parentID,
+				Name:      part,
+				CreatedAt: time.Now().UTC(),
+				UpdatedAt: time.Now().UTC(),
+			})
+		}
+		if err != nil {
+			return uuid.NullUUID{}, err
+		}
+		parentID = uuid.NullUUID{UUID: folder.ID, Valid: true}
+	}
+	return parentID, nil
+}
+
+// Multipart uploads
+
+func (g *s3Gateway) createMultipartUpload(w http.ResponseWriter, r *http.Request, req *s3Request) error {
+	if _, err := g.bucketFolder(r.Context(), req); err != nil {
+		return err
+	}
+	if _, name, err := req.objectPath(); err != nil {
+		return err
+	} else if name == "" {
+		return &s3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "Folder markers cannot be uploaded in parts"}
+	}
+
+	upload, err := g.cfg.dbQueries.CreateMultipartUpload(r.Context(), database.CreateMultipartUploadParams{
+		UserID:    req.vfs.session.user.ID,
+		Bucket:    req.bucket,
+		ObjectKey: req.key,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	type initiateMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}
+	return writeS3XML(w, http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   req.bucket,
+		Key:      req.key,
+		UploadID: upload.ID.String(),
+	})
+}
+
+// multipartUpload loads an upload in progress and checks it targets the request's key
+func (g *s3Gateway) multipartUpload(ctx context.Context, req *s3Request, uploadID string) (database.S3MultipartUpload, error) {
+	id, err := uuid.Parse(uploadID)
+	if err != nil {
+		return database.S3MultipartUpload{}, errS3NoSuchUpload
+	}
+
+	upload, err := g.cfg.dbQueries.GetMultipartUpload(ctx, database.GetMultipartUploadParams{
+		ID:     id,
+		UserID: req.vfs.session.user.ID,
+	})
+	if err == sql.ErrNoRows || (err == nil && (upload.Bucket != req.bucket || upload.ObjectKey != req.key)) {
+		return database.S3MultipartUpload{}, errS3NoSuchUpload
+	}
+	return upload, err
+}
+
+func (g *s3Gateway) uploadPart(w http.ResponseWriter, r *http.Request, req *s3Request, uploadID string) error {
+	ctx := r.Context()
+
+	upload, err := g.multipartUpload(ctx, req, uploadID)
+	if err != nil {
+		return err
+	}
+
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > s3MaxPartNumber {
+		return &s3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "Part number must be an integer between 1 and 10000, inclusive"}
+	}
+
+	parts, err := g.cfg.dbQueries.GetMultipartParts(ctx, upload.ID)
+	if err != nil {
+		return err
+	}
+
+	blob, metadata, wrappedKey, etag, err := req.saveBody(r, "")
+	if err != nil {
+		return err
+	}
+	defer blob.discard()
+
+	// Parts have no files row to commit with; once in place, their row in
+	// s3_multipart_parts keeps the reconciler away from the blob
+	if err := blob.commit(); err != nil {
+		return err
+	}
+	filePath := blob.path
+
+	_, err = g.cfg.dbQueries.UpsertMultipartPart(ctx, database.UpsertMultipartPartParams{
+		UploadID:          upload.ID,
+		PartNumber:        int32(partNumber),
+		FilePath:          filePath,
+		Size:              blob.size,
+		Etag:              etag,
+		EncryptedMetadata: sql.NullString{String: metadata, Valid: metadata != ""},
+		WrappedKey:        sql.NullString{String: wrappedKey, Valid: wrappedKey != ""},
+		CreatedAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		removeBlob(ctx, filePath)
+		return err
+	}
+
+	// A re-uploaded part replaces the earlier blob
+	for _, part := range parts {
+		if part.PartNumber == int32(partNumber) {
+			removeBlob(ctx, part.FilePath)
+		}
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (g *s3Gateway) completeMultipartUpload(w http.ResponseWriter, r *http.Request, req *s3Request, uploadID string) error {
+	ctx := r.Context()
+
+	upload, err := g.multipartUpload(ctx, req, uploadID)
+	if err != nil {
+		return err
+	}
+
+	var params struct {
+		Parts []struct {
+			PartNumber int32  `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	data, err := io.ReadAll(req.body)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, &params); err != nil || len(params.Parts) == 0 {
+		return errS3MalformedXML
+	}
+
+	stored, err := g.cfg.dbQueries.GetMultipartParts(ctx, upload.ID)
+	if err != nil {
+		return err
+	}
+	byNumber := make(map[int32]database.S3MultipartPart)
+	for _, part := range stored {
+		byNumber[part.PartNumber] = part
+	}
+
+	// The object ETag is the MD5 of the concatenated part MD5s, suffixed with the part count
+	etagHash := md5.New()
+	parts := []database.S3MultipartPart{}
+	for i, requested := range params.Parts {
+		if i > 0 && requested.PartNumber <= params.Parts[i-1].PartNumber {
+			return &s3Error{Status: http.StatusBadRequest, Code: "InvalidPartOrder", Message: "The list of parts was not in ascending order."}
+		}
+		part, ok := byNumber[requested.PartNumber]
+		if !ok || strings.Trim(requested.ETag, `"`) != part.Etag {
+			return &s3Error{Status: http.StatusBadRequest, Code: "InvalidPart", Message: "One or more of the specified parts could not be found."}
+		}
+		sum, err := hex.DecodeString(part.Etag)
+		if err != nil {
+			return err
+		}
+		etagHash.Write(sum)
+		parts = append(parts, part)
+	}
+	etag := fmt.Sprintf("%s-%d", hex.EncodeToString(etagHash.Sum(nil)), len(parts))
+
+	folders, name, err := req.objectPath()
+	if err != nil {
+		return err
+	}
+	folderID, err := req.vfs.mkdirAll(ctx, folders)
+	if err != nil {
+		return err
+	}
+
+	existing, err := req.existingObject(ctx, folders, name)
+	if err != nil {
+		return err
+	}
+
+	var blob *stagedBlob
+	var metadata, wrappedKey string
+	if req.gatewayEncryption() {
+		var plaintext bytes.Buffer
+		for _, part := range parts {
+			ciphertext, err := os.ReadFile(part.FilePath)
+			if err != nil {
+				return err
+			}
+			data, err := decryptFileContents(ciphertext, part.EncryptedMetadata.String, part.WrappedKey.String, req.vfs.session.privateKey, "")
+			if err != nil {
+				return err
+			}
+			plaintext.Write(data)
+		}
+
+		var ciphertext []byte
+		ciphertext, metadata, wrappedKey, err = encryptFileForRecipient(plaintext.Bytes(), req.vfs.session.user.PublicKey)
+		if err != nil {
+			return err
+		}
+		blob, err = stageBlob(ctx, bytes.NewReader(ciphertext))
+		if err != nil {
+			return err
+		}
+	} else {
+		readers := []io.Reader{}
+		for _, part := range parts {
+			blob, err := os.Open(part.FilePath)
+			if err != nil {
+				return err
+			}
+			defer blob.Close()
+			readers = append(readers, blob)
+		}
+
+		blob, err = stageBlob(ctx, io.MultiReader(readers...))
+		if err != nil {
+			return err
+		}
+	}
+	defer blob.discard()
+
+	metadata, err = s3Metadata(metadata, etag)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.cfg.recordFile(ctx, req.vfs.session.user, folderID, name, blob, metadata, wrappedKey)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		if err := g.cfg.removeFile(ctx, *existing); err != nil {
+			slog.WarnContext(ctx, "S3 could not remove replaced object", "file_id", existing.ID, "error", err)
+		}
+	}
+	g.discardUpload(ctx, upload.ID, stored)
+
+	type completeMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}
+	return writeS3XML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: "/" + req.bucket + "/" + req.key,
+		Bucket:   req.bucket,
+		Key:      req.key,
+		ETag:     `"` + etag + `"`,
+	})
+}
+
+func (g *s3Gateway) abortMultipartUpload(w http.ResponseWriter, r *http.Request, req *s3Request, uploadID string) error {
+	upload, err := g.multipartUpload(r.Context(), req, uploadID)
+	if err != nil {
+		return err
+	}
+
+	parts, err := g.cfg.dbQueries.GetMultipartParts(r.Context(), upload.ID)
+	if err != nil {
+		return err
+	}
+	g.discardUpload(r.Context(), upload.ID, parts)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// discardUpload deletes an upload's part blobs; its rows cascade with the upload
+func (g *s3Gateway) discardUpload(ctx context.Context, uploadID uuid.UUID, parts []database.S3MultipartPart) {
+	for _, part := range parts {
+		if err := removeBlob(ctx, part.FilePath); err != nil && !os.IsNotExist(err) {
+			slog.WarnContext(ctx, "Could not delete part from disk", "error", err)
+		}
+	}
+	if err := g.cfg.dbQueries.DeleteMultipartUpload(ctx, uploadID); err != nil {
+		slog.ErrorContext(ctx, "S3 could not delete multipart upload", "upload_id", uploadID, "error", err)
+	}
+}
+
+// Responses
+
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errS3NotImplemented = &s3Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: "A header or query you provided implies functionality that is not implemented."}
+	errS3NoSuchKey      = &s3Error{Status: http.StatusNotFound, Code: "NoSuchKey", Message: "The specified key does not exist."}
+	errS3NoSuchUpload   = &s3Error{Status: http.StatusNotFound, Code: "NoSuchUpload", Message: "The specified multipart upload does not exist."}
+	errS3MalformedXML   = &s3Error{Status: http.StatusBadRequest, Code: "MalformedXML", Message: "The XML you provided was not well-formed or did not validate against our published schema."}
+	errS3TooLarge       = &s3Error{Status: http.StatusBadRequest, Code: "EntityTooLarge", Message: "Your proposed upload exceeds the maximum allowed object size."}
+)
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, s3Err *s3Error) {
+	// HEAD responses carry no body, so the status code is all the client gets
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.Status)
+		return
+	}
+
+	type errorResponse struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string   `xml:"Code"`
+		Message  string   `xml:"Message"`
+		Resource string   `xml:"Resource"`
+	}
+	writeS3XML(w, s3Err.Status, errorResponse{
+		Code:     s3Err.Code,
+		Message:  s3Err.Message,
+		Resource: r.URL.Path,
+	})
+}
+
+func writeS3XML(w http.ResponseWriter, status int, payload interface{}) error {
+	data, err := xml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+	return nil
+}
+
+func s3Time(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
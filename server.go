@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Every listener reads request headers and idles under server-wide limits.
+// Read and write deadlines are set per request by route class instead, since
+// an upload may take an hour while a slow client holding an API request open
+// should be cut off in seconds. A read deadline cancels the request context
+// when it passes, so event streams get no deadlines here; handlerEvents pushes
+// its write deadline forward with every message instead.
+
+// routeTimeouts bound reading the body and writing the response of one
+// request; zero means no deadline
+type routeTimeouts struct {
+	read  time.Duration
+	write time.Duration
+}
+
+type serverConfig struct {
+	readHeaderTimeout time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+
+	api      routeTimeouts
+	transfer routeTimeouts
+	stream   routeTimeouts
+}
+
+// loadServerConfig reads timeouts from the environment as Go durations, e.g.
+// HTTP_TRANSFER_TIMEOUT=2h
+func loadServerConfig() (serverConfig, error) {
+	var cfg serverConfig
+	durations := []struct {
+		env   string
+		value *time.Duration
+		def   time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &cfg.readHeaderTimeout, 10 * time.Second},
+		{"HTTP_IDLE_TIMEOUT", &cfg.idleTimeout, 2 * time.Minute},
+		{"SHUTDOWN_TIMEOUT", &cfg.shutdownTimeout, 30 * time.Second},
+		{"HTTP_READ_TIMEOUT", &cfg.api.read, 30 * time.Second},
+		{"HTTP_WRITE_TIMEOUT", &cfg.api.write, time.Minute},
+		{"HTTP_TRANSFER_TIMEOUT", &cfg.transfer.read, time.Hour},
+	}
+	for _, d := range durations {
+		*d.value = d.def
+		s := os.Getenv(d.env)
+		if s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil || v < 0 {
+			return serverConfig{}, fmt.Errorf("invalid %s %q", d.env, s)
+		}
+		*d.value = v
+	}
+
+	cfg.transfer.write = cfg.transfer.read
+	return cfg, nil
+}
+
+// withTimeouts sets the deadlines of requests to next, replacing those of an
+// outer route class
+func withTimeouts(t routeTimeouts, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(deadline(t.read))
+		rc.SetWriteDeadline(deadline(t.write))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func deadline(d time.Duration) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// newHTTPServer applies the server-wide limits to a listener
+func newHTTPServer(addr string, handler http.Handler, cfg serverConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+		MaxHeaderBytes:    1 << 20,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// shutdownServers stops accepting connections and waits for in-flight
+// requests until ctx expires, then closes whatever is left
+func shutdownServers(ctx context.Context, servers []*http.Server) {
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				slog.Warn("Requests still in flight at shutdown deadline", "addr", srv.Addr, "error", err)
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
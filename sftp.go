@@ -0,0 +1,473 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// The SFTP gateway authenticates with SSH keys registered through /me/ssh-keys.
+// Public key auth never reveals the account password, so the gateway can encrypt
+// uploads for the user but cannot decrypt: downloads return the stored ciphertext,
+// exactly as GET /files/{id}/download does.
+
+const sshUserIDExtension = "vaultdrive-user-id"
+
+var errSFTPUploadTooLarge = errors.New("upload exceeds the maximum upload size")
+
+// sshConns tracks open SSH connections so shutdown can wait for sessions to
+// finish before the database pool closes
+type sshConns struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// add registers conn, or reports false once shutdown has started
+func (c *sshConns) add(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	if c.conns == nil {
+		c.conns = make(map[net.Conn]struct{})
+	}
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+	return true
+}
+
+func (c *sshConns) remove(conn net.Conn) {
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+	c.wg.Done()
+}
+
+// wait blocks until every connection has ended, closing the ones still open
+// when ctx expires
+func (c *sshConns) wait(ctx context.Context) {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	slog.Warn("SFTP sessions still open at shutdown deadline", "sessions", len(c.conns))
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.mu.Unlock()
+	<-done
+}
+
+// serveSFTP accepts SSH connections on addr and serves the sftp subsystem until
+// ctx is done. Open sessions are left to conns to drain.
+func (cfg *ApiConfig) serveSFTP(ctx context.Context, addr, hostKeyPath string, conns *sshConns) error {
+	hostKey, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		return fmt.Errorf("could not load SFTP host key: %w", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: cfg.sshPublicKeyCallback,
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	// Stop accepting at shutdown
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			slog.Warn("SFTP accept error", "error", err)
+			continue
+		}
+		if !conns.add(conn) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer conns.remove(conn)
+			cfg.handleSSHConn(conn, config)
+		}()
+	}
+}
+
+// loadOrCreateHostKey reads the server's host key, generating an Ed25519 key on first start
+func loadOrCreateHostKey(hostKeyPath string) (ssh.Signer, error) {
+	keyBytes, err := os.ReadFile(hostKeyPath)
+	if err == nil {
+		return ssh.ParsePrivateKey(keyBytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "vaultdrive sftp host key")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(hostKeyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromKey(privateKey)
+}
+
+func (cfg *ApiConfig) sshPublicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	ctx := context.Background()
+
+	sshKey, err := cfg.dbQueries.GetSSHKeyByFingerprint(ctx, ssh.FingerprintSHA256(key))
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(ctx, sshKey.UserID)
+	if err != nil || user.DisabledAt.Valid {
+		return nil, errInvalidCredentials
+	}
+
+	// The SSH login name must identify the key's owner
+	if conn.User() != user.Username && conn.User() != user.Email {
+		return nil, errInvalidCredentials
+	}
+
+	err = cfg.dbQueries.TouchSSHKey(ctx, database.TouchSSHKeyParams{
+		ID:         sshKey.ID,
+		LastUsedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		slog.Warn("Could not update SSH key last use", "error", err)
+	}
+
+	return &ssh.Permissions{
+		Extensions: map[string]string{sshUserIDExtension: user.ID.String()},
+	}, nil
+}
+
+func (cfg *ApiConfig) handleSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		slog.Warn("SFTP handshake failed", "remote_addr", conn.RemoteAddr().String(), "error", err)
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	userID, err := uuid.Parse(sshConn.Permissions.Extensions[sshUserIDExtension])
+	if err != nil {
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		slog.Error("SFTP could not load user", "user_id", userID, "error", err)
+		return
+	}
+
+	session := &gatewaySession{user: user, createdAt: time.Now()}
+	handler := &sftpHandler{vfs: &vaultFS{cfg: cfg, session: session}}
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			slog.Warn("SFTP could not accept channel", "error", err)
+			continue
+		}
+
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				// Payload is an SSH string: 4-byte length followed by the subsystem name
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(isSFTP, nil)
+				if !isSFTP {
+					continue
+				}
+
+				server := sftp.NewRequestServer(channel, sftp.Handlers{
+					FileGet:  handler,
+					FilePut:  handler,
+					FileCmd:  handler,
+					FileList: handler,
+				})
+				if err := server.Serve(); err != nil && err != io.EOF {
+					slog.Warn("SFTP session error", "error", err)
+				}
+				server.Close()
+				return
+			}
+		}()
+	}
+}
+
+// sftpHandler implements the pkg/sftp request handlers on top of vaultFS
+type sftpHandler struct {
+	vfs *vaultFS
+}
+
+func (h *sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	entry, err := h.vfs.lookup(r.Context(), r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if entry.file == nil {
+		return nil, os.ErrInvalid
+	}
+	return h.vfs.cfg.openBlob(r.Context(), *entry.file)
+}
+
+func (h *sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	entry, err := h.vfs.lookup(r.Context(), r.Filepath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil && entry.file == nil {
+		return nil, os.ErrInvalid
+	}
+
+	parts := splitPath(r.Filepath)
+	if len(parts) == 0 {
+		return nil, os.ErrInvalid
+	}
+	parentID, err := h.vfs.resolveFolder(r.Context(), parts[:len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &sftpUpload{
+		vfs:      h.vfs,
+		folderID: parentID,
+		name:     parts[len(parts)-1],
+		existing: entry.file,
+	}, nil
+}
+
+func (h *sftpHandler) Filecmd(r *sftp.Request) error {
+	ctx := r.Context()
+
+	switch r.Method {
+	case "Setstat":
+		// Permissions and times are not stored
+		return nil
+	case "Rename":
+		return h.vfs.Rename(ctx, r.Filepath, r.Target)
+	case "Mkdir":
+		return h.vfs.Mkdir(ctx, r.Filepath, 0755)
+	case "Remove":
+		entry, err := h.vfs.lookup(ctx, r.Filepath)
+		if err != nil {
+			return err
+		}
+		if entry.file == nil {
+			return os.ErrInvalid
+		}
+		return h.vfs.cfg.removeFile(ctx, *entry.file)
+	case "Rmdir":
+		entry, err := h.vfs.lookup(ctx, r.Filepath)
+		if err != nil {
+			return err
+		}
+		if entry.folder == nil {
+			return os.ErrInvalid
+		}
+		dir := &vaultDir{vfs: h.vfs, ctx: ctx, entry: entry}
+		children, err := dir.Readdir(1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(children) > 0 {
+			return errors.New("directory not empty")
+		}
+		return h.vfs.cfg.dbQueries.DeleteFolder(ctx, entry.folder.ID)
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (h *sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	ctx := r.Context()
+
+	entry, err := h.vfs.lookup(ctx, r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		if entry.file != nil {
+			return nil, os.ErrInvalid
+		}
+		dir := &vaultDir{vfs: h.vfs, ctx: ctx, entry: entry}
+		infos, err := dir.Readdir(0)
+		if err != nil {
+			return nil, err
+		}
+		return sftpListerAt(ciphertextSizes(ctx, h.vfs, entry, infos)), nil
+	case "Stat":
+		info := entryInfo(entry)
+		if entry.file != nil {
+			info.size = entry.file.FileSize
+		}
+		if entry.isRoot() {
+			info.name = path.Base(r.Filepath)
+		}
+		return sftpListerAt{info}, nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+// ciphertextSizes reports stored sizes, since SFTP downloads return the ciphertext
+func ciphertextSizes(ctx context.Context, vfs *vaultFS, entry vaultEntry, infos []os.FileInfo) []os.FileInfo {
+	files, err := vfs.folderFiles(ctx, entry.folderID())
+	if err != nil {
+		return infos
+	}
+
+	sizes := make(map[string]int64)
+	for _, f := range files {
+		sizes[f.name] = f.file.FileSize
+	}
+
+	for i, info := range infos {
+		if fi, ok := info.(vaultFileInfo); ok && !fi.dir {
+			if size, found := sizes[fi.name]; found {
+				fi.size = size
+				infos[i] = fi
+			}
+		}
+	}
+	return infos
+}
+
+type sftpListerAt []os.FileInfo
+
+func (l sftpListerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sftpUpload collects an upload in memory and encrypts it for the owner on
+// close. The plaintext is kept off disk, and uploads are capped at
+// cfg.maxUploadBytes.
+type sftpUpload struct {
+	vfs      *vaultFS
+	folderID uuid.NullUUID
+	name     string
+	existing *database.File
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (u *sftpUpload) WriteAt(p []byte, off int64) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	maxBytes := u.vfs.cfg.maxUploadBytes
+	if off < 0 {
+		return 0, os.ErrInvalid
+	}
+	if off > maxBytes-int64(len(p)) {
+		return 0, errSFTPUploadTooLarge
+	}
+
+	// Capacity doubles so sequential writes copy the buffer O(log n) times
+	end := off + int64(len(p))
+	if end > int64(cap(u.buf)) {
+		grown := make([]byte, end, min(max(end, 2*int64(cap(u.buf))), maxBytes))
+		copy(grown, u.buf)
+		u.buf = grown
+	} else if end > int64(len(u.buf)) {
+		u.buf = u.buf[:end]
+	}
+	copy(u.buf[off:], p)
+	return len(p), nil
+}
+
+func (u *sftpUpload) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ctx := context.Background()
+
+	// The connection may outlive a key rotation, which would leave the upload
+	// wrapped for the old public key
+	if !u.vfs.cfg.sessionCurrent(ctx, u.vfs.session) {
+		return errInvalidCredentials
+	}
+
+	_, err := u.vfs.cfg.storeFile(ctx, u.vfs.session.user, u.folderID, u.name, u.buf)
+	if err != nil {
+		slog.Error("SFTP upload failed", "error", err)
+		return err
+	}
+
+	if u.existing != nil {
+		return u.vfs.cfg.removeFile(ctx, *u.existing)
+	}
+	return nil
+}
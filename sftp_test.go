@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSFTPUploadWriteAt(t *testing.T) {
+	upload := &sftpUpload{vfs: &vaultFS{cfg: &ApiConfig{maxUploadBytes: 16}}}
+
+	if _, err := upload.WriteAt([]byte("world"), 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload.WriteAt([]byte("hello "), 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(upload.buf, []byte("hello world")) {
+		t.Errorf("buf = %q", upload.buf)
+	}
+
+	if _, err := upload.WriteAt([]byte("x"), -1); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("WriteAt(-1) error = %v, want os.ErrInvalid", err)
+	}
+	if _, err := upload.WriteAt([]byte("!"), 16); !errors.Is(err, errSFTPUploadTooLarge) {
+		t.Errorf("WriteAt past the limit error = %v, want errSFTPUploadTooLarge", err)
+	}
+	if _, err := upload.WriteAt([]byte("!"), 1<<62); !errors.Is(err, errSFTPUploadTooLarge) {
+		t.Errorf("WriteAt at a huge offset error = %v, want errSFTPUploadTooLarge", err)
+	}
+	if _, err := upload.WriteAt([]byte("!"), 15); err != nil || len(upload.buf) != 16 {
+		t.Errorf("WriteAt up to the limit = %v, len %d", err, len(upload.buf))
+	}
+}
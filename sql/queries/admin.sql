@@ -0,0 +1,34 @@
+-- name: SearchUsers :many
+SELECT
+    users.id, users.first_name, users.last_name, users.username, users.email, users.role,
+    users.disabled_at, users.quota_bytes, users.created_at,
+    COUNT(files.id) AS file_count,
+    COALESCE(SUM(files.file_size), 0)::BIGINT AS storage_used
+FROM users
+LEFT JOIN files ON files.owner_id = users.id
+WHERE sqlc.arg(query)::TEXT = ''
+    OR users.email ILIKE '%' || sqlc.arg(query) || '%'
+    OR users.username ILIKE '%' || sqlc.arg(query) || '%'
+GROUP BY users.id
+ORDER BY users.created_at DESC
+LIMIT sqlc.arg(limit_count) OFFSET sqlc.arg(offset_count);
+
+-- name: GetStorageStats :one
+SELECT
+    (SELECT COUNT(*) FROM users) AS total_users,
+    (SELECT COUNT(*) FROM users WHERE disabled_at IS NOT NULL) AS disabled_users,
+    (SELECT COUNT(*) FROM files) AS total_files,
+    (SELECT COALESCE(SUM(file_size), 0)::BIGINT FROM files) AS total_bytes;
+
+-- name: GetBackendStorageBytes :one
+SELECT
+    (SELECT COALESCE(SUM(file_size), 0)::BIGINT FROM files WHERE file_path NOT LIKE 'chunks:%') AS blob_bytes,
+    (SELECT COALESCE(SUM(size), 0)::BIGINT FROM chunks) AS chunk_bytes;
+
+-- name: GetTopStorageUsers :many
+SELECT users.id, users.username, COALESCE(SUM(files.file_size), 0)::BIGINT AS storage_used
+FROM users
+JOIN files ON files.owner_id = users.id
+GROUP BY users.id
+ORDER BY storage_used DESC
+LIMIT $1;
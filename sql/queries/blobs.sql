@@ -0,0 +1,9 @@
+-- name: GetBlobPaths :many
+SELECT file_path FROM files
+UNION
+SELECT file_path FROM s3_multipart_parts;
+
+-- name: GetFilePathsCreatedBefore :many
+SELECT id, file_path FROM files
+WHERE created_at < $1
+AND id NOT IN (SELECT file_id FROM file_integrity WHERE status = 'quarantined');
@@ -0,0 +1,29 @@
+-- name: UpsertChunk :exec
+INSERT INTO chunks (hash, size, refcount, created_at)
+VALUES ($1, $2, 0, $3)
+ON CONFLICT (hash) DO UPDATE SET size = chunks.size;
+
+-- name: AddFileChunk :exec
+INSERT INTO file_chunks (file_id, seq, chunk_hash)
+VALUES ($1, $2, $3);
+
+-- name: GetFileChunks :many
+SELECT file_chunks.chunk_hash, chunks.size
+FROM file_chunks
+JOIN chunks ON chunks.hash = file_chunks.chunk_hash
+WHERE file_chunks.file_id = $1
+ORDER BY file_chunks.seq;
+
+-- name: GetChunks :many
+SELECT * FROM chunks
+ORDER BY hash;
+
+-- name: LockUnreferencedChunks :many
+SELECT hash FROM chunks
+WHERE refcount = 0
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteChunk :exec
+DELETE FROM chunks
+WHERE hash = $1;
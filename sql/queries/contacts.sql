@@ -0,0 +1,25 @@
+-- name: CreateContact :one
+INSERT INTO contacts (user_id, contact_id, pinned_fingerprint, pinned_key_version, verified_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+
+-- name: GetContact :one
+SELECT * FROM contacts
+WHERE user_id = $1 AND contact_id = $2;
+
+-- name: GetContactsByUser :many
+SELECT contacts.*, users.username, users.email, users.public_key, users.key_version
+FROM contacts
+JOIN users ON users.id = contacts.contact_id
+WHERE contacts.user_id = $1
+ORDER BY users.username;
+
+-- name: PinContactKey :one
+UPDATE contacts
+SET pinned_fingerprint = $3, pinned_key_version = $4, verified_at = $5, updated_at = $6
+WHERE user_id = $1 AND contact_id = $2
+RETURNING *;
+
+-- name: DeleteContact :execrows
+DELETE FROM contacts
+WHERE user_id = $1 AND contact_id = $2;
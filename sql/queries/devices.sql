@@ -0,0 +1,63 @@
+-- name: CreateDevice :one
+INSERT INTO devices (
+    user_id,
+    name,
+    public_key,
+    key_algorithm,
+    approved_at,
+    pairing_code_hash,
+    pairing_expires_at,
+    created_at,
+    last_seen_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetDeviceByID :one
+SELECT * FROM devices
+WHERE id = $1;
+
+-- name: GetDevicesByUser :many
+SELECT * FROM devices
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: CountApprovedDevices :one
+SELECT COUNT(*) FROM devices
+WHERE user_id = $1 AND approved_at IS NOT NULL;
+
+-- name: TouchDevice :exec
+UPDATE devices
+SET last_seen_at = $2
+WHERE id = $1;
+
+-- name: SetDevicePairingCode :exec
+UPDATE devices
+SET pairing_code_hash = $2, pairing_expires_at = $3, pairing_attempts = 0
+WHERE id = $1;
+
+-- name: RecordDevicePairingAttempt :one
+UPDATE devices
+SET pairing_attempts = pairing_attempts + 1
+WHERE id = $1
+RETURNING pairing_attempts;
+
+-- name: ApproveDevice :one
+UPDATE devices
+SET
+    wrapped_master_key = $2,
+    approved_at = $3,
+    pairing_code_hash = NULL,
+    pairing_expires_at = NULL,
+    pairing_attempts = 0
+WHERE id = $1
+RETURNING *;
+
+-- name: RewrapDeviceKey :execrows
+UPDATE devices
+SET wrapped_master_key = $3
+WHERE id = $1 AND user_id = $2 AND approved_at IS NOT NULL;
+
+-- name: DeleteDevice :exec
+DELETE FROM devices
+WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,30 @@
+-- name: LockEvents :exec
+-- LockEvents holds the user's event lock until the transaction ends.
+SELECT pg_advisory_xact_lock(hashtext('events'), hashtext(sqlc.arg(user_id)::TEXT));
+
+-- name: CreateEvent :one
+INSERT INTO events (user_id, event_type, payload)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetEventByID :one
+SELECT * FROM events
+WHERE id = $1;
+
+-- name: GetEventsByUserAfterID :many
+SELECT * FROM events
+WHERE user_id = $1 AND id > $2
+ORDER BY id ASC;
+
+-- name: DeleteEventsBefore :exec
+DELETE FROM events
+WHERE created_at < $1;
+
+-- name: MarkQuotaWarningSent :execrows
+INSERT INTO quota_warnings (user_id, sent_at)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: ClearQuotaWarning :exec
+DELETE FROM quota_warnings
+WHERE user_id = $1;
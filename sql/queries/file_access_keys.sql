@@ -1,6 +1,6 @@
 -- name: CreateFileAccessKey :one
-INSERT INTO file_access_keys (file_id, user_id, wrapped_key)
-VALUES ($1, $2, $3)
+INSERT INTO file_access_keys (file_id, user_id, wrapped_key, key_version)
+VALUES ($1, $2, $3, COALESCE(sqlc.narg(key_version), (SELECT key_version FROM users WHERE id = $2)))
 RETURNING *;
 
 -- name: GetFileAccessKey :one
@@ -20,3 +20,22 @@ ORDER BY created_at DESC;
 SELECT * FROM file_access_keys
 WHERE file_id = $1
 ORDER BY created_at DESC;
+
+-- name: DeleteOrgFileAccessKeysForUser :exec
+DELETE FROM file_access_keys
+WHERE user_id = $2 AND file_id IN (SELECT id FROM files WHERE org_id = $1);
+
+-- name: GetStaleFileAccessKeys :many
+SELECT * FROM file_access_keys
+WHERE user_id = $1 AND key_version < $2
+ORDER BY id
+LIMIT $3;
+
+-- name: CountStaleFileAccessKeys :one
+SELECT COUNT(*) FROM file_access_keys
+WHERE user_id = $1 AND key_version < $2;
+
+-- name: RewrapFileAccessKey :execrows
+UPDATE file_access_keys
+SET wrapped_key = $3, key_version = $4
+WHERE file_id = $1 AND user_id = $2 AND key_version = $5;
@@ -0,0 +1,32 @@
+-- name: SetFileHash :exec
+INSERT INTO file_integrity (file_id, sha256, status, checked_at)
+VALUES ($1, $2, 'ok', $3)
+ON CONFLICT (file_id) DO UPDATE
+SET sha256 = EXCLUDED.sha256, status = 'ok', checked_at = EXCLUDED.checked_at;
+
+-- name: RecordFileCheck :exec
+INSERT INTO file_integrity (file_id, sha256, status, checked_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (file_id) DO UPDATE
+SET sha256 = COALESCE(file_integrity.sha256, EXCLUDED.sha256),
+    status = EXCLUDED.status,
+    checked_at = EXCLUDED.checked_at;
+
+-- name: GetFilesDueForCheck :many
+SELECT f.id, f.file_path, f.file_size, fi.sha256
+FROM files f
+LEFT JOIN file_integrity fi ON fi.file_id = f.id
+WHERE (fi.checked_at IS NULL OR fi.checked_at < $1)
+AND (fi.status IS NULL OR fi.status <> 'quarantined')
+ORDER BY fi.checked_at NULLS FIRST
+LIMIT $2;
+
+-- name: GetFileIntegrity :many
+SELECT f.id, f.file_path, f.file_size, fi.sha256, fi.status
+FROM files f
+LEFT JOIN file_integrity fi ON fi.file_id = f.id
+ORDER BY f.created_at;
+
+-- name: GetFileIntegrityByID :one
+SELECT * FROM file_integrity
+WHERE file_id = $1;
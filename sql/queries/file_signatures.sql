@@ -0,0 +1,12 @@
+-- name: CreateFileSignature :one
+INSERT INTO file_signatures (file_id, signer_id, signing_public_key, ciphertext_sha256, signature, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetFileSignature :one
+SELECT * FROM file_signatures
+WHERE file_id = $1;
+
+-- name: DeleteFileSignature :exec
+DELETE FROM file_signatures
+WHERE file_id = $1;
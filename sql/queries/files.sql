@@ -7,9 +7,11 @@ INSERT INTO files (
     encrypted_metadata,
     current_key_version,
     created_at,
-    updated_at
+    updated_at,
+    folder_id,
+    id
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE(sqlc.narg(id), gen_random_uuid()))
 RETURNING *;
 
 -- name: GetFileByID :one
@@ -59,3 +61,63 @@ LIMIT $2 OFFSET $3;
 -- name: CountFilesByOwnerID :one
 SELECT COUNT(*) FROM files
 WHERE owner_id = $1;
+
+-- name: GetTotalFileSizeByOwnerID :one
+SELECT COALESCE(SUM(file_size), 0)::BIGINT AS total_size FROM files
+WHERE owner_id = $1;
+
+-- name: GetFilesByFolder :many
+SELECT * FROM files
+WHERE owner_id = $1 AND folder_id IS NOT DISTINCT FROM $2
+ORDER BY filename ASC;
+
+-- name: MoveFile :one
+UPDATE files
+SET
+    folder_id = $2,
+    filename = $3,
+    encrypted_metadata = $4,
+    updated_at = $5
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateOrgFile :one
+INSERT INTO files (
+    org_id,
+    uploaded_by,
+    filename,
+    file_path,
+    file_size,
+    encrypted_metadata,
+    current_key_version,
+    created_at,
+    updated_at,
+    id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE(sqlc.narg(id), gen_random_uuid()))
+RETURNING *;
+
+-- name: GetFilesByOrgID :many
+SELECT * FROM files
+WHERE org_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetOrgFilesWithKeyForUser :many
+SELECT files.*, file_access_keys.wrapped_key
+FROM files
+LEFT JOIN file_access_keys ON file_access_keys.file_id = files.id AND file_access_keys.user_id = $2
+WHERE files.org_id = $1
+ORDER BY files.created_at DESC;
+
+-- name: GetTotalFileSizeByOrgID :one
+SELECT COALESCE(SUM(file_size), 0)::BIGINT AS total_size FROM files
+WHERE org_id = $1;
+
+-- name: SetFileEncryptedName :one
+UPDATE files
+SET
+    filename = $2,
+    encrypted_metadata = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING *;
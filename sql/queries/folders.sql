@@ -0,0 +1,30 @@
+-- name: CreateFolder :one
+INSERT INTO folders (owner_id, parent_id, name, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetFolderByID :one
+SELECT * FROM folders
+WHERE id = $1;
+
+-- name: GetFolderByName :one
+SELECT * FROM folders
+WHERE owner_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND name = $3;
+
+-- name: GetFoldersByParent :many
+SELECT * FROM folders
+WHERE owner_id = $1 AND parent_id IS NOT DISTINCT FROM $2
+ORDER BY name ASC;
+
+-- name: MoveFolder :one
+UPDATE folders
+SET
+    parent_id = $2,
+    name = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFolder :exec
+DELETE FROM folders
+WHERE id = $1;
@@ -0,0 +1,99 @@
+-- name: CreateGroup :one
+INSERT INTO groups (name, created_by, public_key, key_algorithm, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetGroupByID :one
+SELECT * FROM groups
+WHERE id = $1;
+
+-- name: GetGroupsByUser :many
+SELECT groups.*, group_members.role, group_members.wrapped_private_key
+FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = $1
+ORDER BY groups.name ASC;
+
+-- name: RotateGroupKey :one
+UPDATE groups
+SET
+    public_key = $2,
+    key_algorithm = $3,
+    key_version = key_version + 1,
+    rotation_required = false,
+    updated_at = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: SetGroupRotationRequired :exec
+UPDATE groups
+SET rotation_required = true, updated_at = $2
+WHERE id = $1;
+
+-- name: DeleteGroup :exec
+DELETE FROM groups
+WHERE id = $1;
+
+-- name: AddGroupMember :one
+INSERT INTO group_members (group_id, user_id, role, wrapped_private_key, created_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetGroupMember :one
+SELECT * FROM group_members
+WHERE group_id = $1 AND user_id = $2;
+
+-- name: GetGroupMembers :many
+SELECT group_members.*, users.username, users.email, users.public_key
+FROM group_members
+JOIN users ON users.id = group_members.user_id
+WHERE group_members.group_id = $1
+ORDER BY group_members.created_at ASC;
+
+-- name: SetGroupMemberKey :exec
+UPDATE group_members
+SET wrapped_private_key = $3
+WHERE group_id = $1 AND user_id = $2;
+
+-- name: RemoveGroupMember :exec
+DELETE FROM group_members
+WHERE group_id = $1 AND user_id = $2;
+
+-- name: CountGroupOwners :one
+SELECT COUNT(*) FROM group_members
+WHERE group_id = $1 AND role = 'owner';
+
+-- name: ShareFileWithGroup :one
+INSERT INTO group_file_keys (group_id, file_id, wrapped_key, shared_by, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (group_id, file_id) DO UPDATE
+SET wrapped_key = EXCLUDED.wrapped_key, shared_by = EXCLUDED.shared_by
+RETURNING *;
+
+-- name: GetGroupFileKeys :many
+SELECT * FROM group_file_keys
+WHERE group_id = $1;
+
+-- name: GetGroupFiles :many
+SELECT files.*, group_file_keys.wrapped_key, group_file_keys.shared_by, group_file_keys.created_at AS shared_at
+FROM group_file_keys
+JOIN files ON files.id = group_file_keys.file_id
+WHERE group_file_keys.group_id = $1
+ORDER BY group_file_keys.created_at DESC;
+
+-- name: GetGroupFileKeyForUser :one
+SELECT group_file_keys.group_id, group_file_keys.wrapped_key, groups.key_version
+FROM group_file_keys
+JOIN group_members ON group_members.group_id = group_file_keys.group_id
+JOIN groups ON groups.id = group_file_keys.group_id
+WHERE group_file_keys.file_id = $1 AND group_members.user_id = $2
+LIMIT 1;
+
+-- name: SetGroupFileKey :exec
+UPDATE group_file_keys
+SET wrapped_key = $3
+WHERE group_id = $1 AND file_id = $2;
+
+-- name: DeleteGroupFileKey :exec
+DELETE FROM group_file_keys
+WHERE group_id = $1 AND file_id = $2;
@@ -0,0 +1,29 @@
+-- name: LockKeyLog :exec
+SELECT pg_advisory_xact_lock(hashtext('key_log_entries'));
+
+-- name: AppendKeyLogEntry :one
+INSERT INTO key_log_entries (idx, user_id, key_version, key_algorithm, public_key, created_at, key_use)
+VALUES ((SELECT COALESCE(MAX(idx) + 1, 0) FROM key_log_entries), $1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: CountKeyLogEntries :one
+-- Indexes are gapless, so the size comes from the primary key
+SELECT COALESCE(MAX(idx) + 1, 0)::BIGINT FROM key_log_entries;
+
+-- name: GetKeyLogEntries :many
+SELECT * FROM key_log_entries
+WHERE idx >= $1 AND idx < $2
+ORDER BY idx;
+
+-- name: GetKeyLogEntry :one
+SELECT * FROM key_log_entries
+WHERE user_id = $1 AND key_use = $2 AND key_version = $3;
+
+-- name: GetKeyLogEntriesByUser :many
+SELECT * FROM key_log_entries
+WHERE user_id = $1
+ORDER BY idx;
+
+-- name: NextSigningKeyVersion :one
+SELECT (COALESCE(MAX(key_version), 0) + 1)::INTEGER FROM key_log_entries
+WHERE user_id = $1 AND key_use = 'signing';
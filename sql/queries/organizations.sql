@@ -0,0 +1,76 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name, billing_email, created_at, updated_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetOrganizationByID :one
+SELECT * FROM organizations
+WHERE id = $1;
+
+-- name: GetOrganizationsByUser :many
+SELECT organizations.*, organization_members.role
+FROM organizations
+JOIN organization_members ON organization_members.org_id = organizations.id
+WHERE organization_members.user_id = $1
+ORDER BY organizations.name ASC;
+
+-- name: UpdateOrganization :one
+UPDATE organizations
+SET
+    name = $2,
+    billing_email = $3,
+    updated_at = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: SetOrganizationQuota :one
+UPDATE organizations
+SET quota_bytes = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: LockOrganization :one
+SELECT id FROM organizations
+WHERE id = $1
+FOR UPDATE;
+
+-- name: DeleteOrganization :exec
+DELETE FROM organizations
+WHERE id = $1;
+
+-- name: AddOrganizationMember :one
+INSERT INTO organization_members (org_id, user_id, role, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members
+WHERE org_id = $1 AND user_id = $2;
+
+-- name: GetOrganizationMembers :many
+SELECT organization_members.*, users.username, users.email, users.public_key
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.org_id = $1
+ORDER BY organization_members.created_at ASC;
+
+-- name: UpdateOrganizationMemberRole :one
+UPDATE organization_members
+SET role = $3
+WHERE org_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: RemoveOrganizationMember :exec
+DELETE FROM organization_members
+WHERE org_id = $1 AND user_id = $2;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members
+WHERE org_id = $1 AND role = 'owner';
+
+-- name: GetOrganizationUsageByUploader :many
+SELECT files.uploaded_by, COUNT(*) AS file_count, COALESCE(SUM(files.file_size), 0)::BIGINT AS storage_used
+FROM files
+WHERE files.org_id = $1
+GROUP BY files.uploaded_by
+ORDER BY storage_used DESC;
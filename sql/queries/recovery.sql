@@ -0,0 +1,84 @@
+-- name: UpsertRecoveryKey :one
+INSERT INTO recovery_keys (user_id, recovery_key_encrypted, proof_hash, threshold, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET
+    recovery_key_encrypted = EXCLUDED.recovery_key_encrypted,
+    proof_hash = EXCLUDED.proof_hash,
+    threshold = EXCLUDED.threshold,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetRecoveryKey :one
+SELECT * FROM recovery_keys
+WHERE user_id = $1;
+
+-- name: RewrapRecoveryKey :execrows
+UPDATE recovery_keys
+SET recovery_key_encrypted = $2, updated_at = $3
+WHERE user_id = $1;
+
+-- name: DeleteRecoveryKey :exec
+DELETE FROM recovery_keys
+WHERE user_id = $1;
+
+-- name: CreateRecoveryShare :one
+INSERT INTO recovery_shares (user_id, trustee_id, encrypted_share, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetRecoverySharesByUser :many
+SELECT recovery_shares.*, users.username, users.email
+FROM recovery_shares
+JOIN users ON users.id = recovery_shares.trustee_id
+WHERE recovery_shares.user_id = $1
+ORDER BY recovery_shares.created_at ASC;
+
+-- name: DeleteRecoverySharesByUser :exec
+DELETE FROM recovery_shares
+WHERE user_id = $1;
+
+-- name: CreateRecoveryRequest :one
+INSERT INTO recovery_requests (user_id, public_key, secret_hash, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetRecoveryRequest :one
+SELECT * FROM recovery_requests
+WHERE id = $1;
+
+-- name: CompleteRecoveryRequest :execrows
+UPDATE recovery_requests
+SET completed_at = $2
+WHERE id = $1 AND completed_at IS NULL;
+
+-- name: GetPendingRecoveryRequestsForTrustee :many
+SELECT
+    recovery_requests.id,
+    recovery_requests.user_id,
+    recovery_requests.public_key,
+    recovery_requests.created_at,
+    recovery_requests.expires_at,
+    users.username,
+    users.email,
+    recovery_shares.encrypted_share
+FROM recovery_requests
+JOIN recovery_shares ON recovery_shares.user_id = recovery_requests.user_id
+JOIN users ON users.id = recovery_requests.user_id
+LEFT JOIN recovery_approvals ON recovery_approvals.request_id = recovery_requests.id
+    AND recovery_approvals.trustee_id = recovery_shares.trustee_id
+WHERE recovery_shares.trustee_id = $1
+    AND recovery_requests.completed_at IS NULL
+    AND recovery_requests.expires_at > $2
+    AND recovery_approvals.request_id IS NULL
+ORDER BY recovery_requests.created_at DESC;
+
+-- name: CreateRecoveryApproval :one
+INSERT INTO recovery_approvals (request_id, trustee_id, encrypted_share, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetRecoveryApprovals :many
+SELECT * FROM recovery_approvals
+WHERE request_id = $1
+ORDER BY created_at ASC;
@@ -5,14 +5,15 @@ INSERT INTO refresh_tokens (
     updated_at,
     user_id,
     expires_at,
-    revoked_at
+    revoked_at,
+    device_id
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7
 )
-RETURNING token, created_at, updated_at, revoked_at, user_id, expires_at;
+RETURNING token, created_at, updated_at, revoked_at, user_id, expires_at, device_id;
 
 -- name: GetRefreshToken :one
-SELECT token, created_at, updated_at, revoked_at, user_id, expires_at FROM refresh_tokens
+SELECT token, created_at, updated_at, revoked_at, user_id, expires_at, device_id FROM refresh_tokens
 WHERE token = $1;
 
 -- name: RevokeRefreshToken :exec
@@ -20,8 +21,18 @@ UPDATE refresh_tokens
 SET revoked_at = $2, updated_at = $3
 WHERE token = $1;
 
+-- name: RevokeRefreshTokensByUser :exec
+UPDATE refresh_tokens
+SET revoked_at = $2, updated_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokensByDevice :exec
+UPDATE refresh_tokens
+SET revoked_at = $2, updated_at = $2
+WHERE device_id = $1 AND revoked_at IS NULL;
+
 -- name: GetUserByRefreshToken :one
-SELECT users.id, users.first_name, users.last_name, users.username, users.email, users.password_hash, users.public_key, users.private_key_encrypted, users.created_at, users.updated_at FROM users
+SELECT users.id, users.first_name, users.last_name, users.username, users.email, users.password_hash, users.public_key, users.private_key_encrypted, users.created_at, users.updated_at, users.role, users.disabled_at, users.quota_bytes, users.tokens_valid_after, users.key_version, users.key_algorithm, users.signing_public_key, users.signing_private_key_encrypted FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1;
 
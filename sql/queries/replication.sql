@@ -0,0 +1,29 @@
+-- name: EnqueueReplication :exec
+INSERT INTO replication_queue (blob_name, operation, attempts, last_error, enqueued_at, next_attempt_at)
+VALUES ($1, $2, 0, NULL, $3, $3)
+ON CONFLICT (blob_name) DO UPDATE
+SET operation = EXCLUDED.operation,
+    attempts = 0,
+    last_error = NULL,
+    enqueued_at = EXCLUDED.enqueued_at,
+    next_attempt_at = EXCLUDED.next_attempt_at;
+
+-- name: GetDueReplicationJobs :many
+SELECT * FROM replication_queue
+WHERE next_attempt_at <= $1
+ORDER BY next_attempt_at
+LIMIT $2;
+
+-- name: CompleteReplicationJob :exec
+DELETE FROM replication_queue
+WHERE blob_name = $1 AND enqueued_at = $2;
+
+-- name: RetryReplicationJob :exec
+UPDATE replication_queue
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE blob_name = $1;
+
+-- name: GetReplicationQueueStats :one
+SELECT COUNT(*) AS pending,
+    COALESCE(EXTRACT(EPOCH FROM sqlc.arg(now)::timestamp - MIN(enqueued_at)), 0)::float8 AS lag_seconds
+FROM replication_queue;
@@ -0,0 +1,57 @@
+-- name: CreateS3AccessKey :one
+INSERT INTO s3_access_keys (user_id, access_key_id, secret_key, private_key_encrypted, created_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetS3AccessKeyByAccessKeyID :one
+SELECT * FROM s3_access_keys
+WHERE access_key_id = $1;
+
+-- name: GetS3AccessKeysByUser :many
+SELECT * FROM s3_access_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: TouchS3AccessKey :exec
+UPDATE s3_access_keys
+SET last_used_at = $2
+WHERE id = $1;
+
+-- name: DeleteS3AccessKey :exec
+DELETE FROM s3_access_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteGatewayS3AccessKeysByUser :exec
+DELETE FROM s3_access_keys
+WHERE user_id = $1 AND private_key_encrypted IS NOT NULL;
+
+-- name: CreateMultipartUpload :one
+INSERT INTO s3_multipart_uploads (user_id, bucket, object_key, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetMultipartUpload :one
+SELECT * FROM s3_multipart_uploads
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteMultipartUpload :exec
+DELETE FROM s3_multipart_uploads
+WHERE id = $1;
+
+-- name: UpsertMultipartPart :one
+INSERT INTO s3_multipart_parts (upload_id, part_number, file_path, size, etag, encrypted_metadata, wrapped_key, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (upload_id, part_number) DO UPDATE
+SET
+    file_path = EXCLUDED.file_path,
+    size = EXCLUDED.size,
+    etag = EXCLUDED.etag,
+    encrypted_metadata = EXCLUDED.encrypted_metadata,
+    wrapped_key = EXCLUDED.wrapped_key,
+    created_at = EXCLUDED.created_at
+RETURNING *;
+
+-- name: GetMultipartParts :many
+SELECT * FROM s3_multipart_parts
+WHERE upload_id = $1
+ORDER BY part_number ASC;
@@ -0,0 +1,22 @@
+-- name: CreateSSHKey :one
+INSERT INTO ssh_keys (user_id, name, public_key, fingerprint, created_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetSSHKeyByFingerprint :one
+SELECT * FROM ssh_keys
+WHERE fingerprint = $1;
+
+-- name: GetSSHKeysByUser :many
+SELECT * FROM ssh_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: TouchSSHKey :exec
+UPDATE ssh_keys
+SET last_used_at = $2
+WHERE id = $1;
+
+-- name: DeleteSSHKey :exec
+DELETE FROM ssh_keys
+WHERE id = $1 AND user_id = $2;
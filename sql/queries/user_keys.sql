@@ -0,0 +1,18 @@
+-- name: CreateUserKey :one
+INSERT INTO user_keys (user_id, version, public_key, private_key_encrypted, created_at, key_algorithm)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetUserKey :one
+SELECT * FROM user_keys
+WHERE user_id = $1 AND version = $2;
+
+-- name: GetUserKeys :many
+SELECT * FROM user_keys
+WHERE user_id = $1
+ORDER BY version DESC;
+
+-- name: RetireUserKeys :exec
+UPDATE user_keys
+SET retired_at = $2, private_key_encrypted = NULL
+WHERE user_id = $1 AND retired_at IS NULL;
@@ -8,9 +8,12 @@ INSERT INTO users (
   public_key,
   private_key_encrypted,
   created_at,
-  updated_at
+  updated_at,
+  key_algorithm,
+  signing_public_key,
+  signing_private_key_encrypted
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING *;
 
 -- name: GetUserByID :one
@@ -35,6 +38,45 @@ SET
 WHERE id = $1
 RETURNING *;
 
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserDisabled :one
+UPDATE users
+SET disabled_at = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserQuota :one
+UPDATE users
+SET quota_bytes = $2, updated_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: SetUserTokensValidAfter :exec
+UPDATE users
+SET tokens_valid_after = $2
+WHERE id = $1;
+
 -- name: DeleteUser :exec
 DELETE FROM users
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, private_key_encrypted = $3, updated_at = $4
+WHERE id = $1;
+
+-- name: RotateUserKey :one
+UPDATE users
+SET public_key = $2, private_key_encrypted = $3, key_version = key_version + 1, updated_at = $4, key_algorithm = $6
+WHERE id = $1 AND key_version = $5
+RETURNING *;
+
+-- name: SetUserSigningKey :exec
+UPDATE users
+SET signing_public_key = $2, signing_private_key_encrypted = $3, updated_at = $4
+WHERE id = $1;
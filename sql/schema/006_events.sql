@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_events_user_id ON events(user_id, id);
+
+-- Every replica LISTENs on this channel and forwards the event to its own
+-- connected clients, so the notification only needs to carry the row ID.
+-- +goose StatementBegin
+CREATE FUNCTION notify_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('vaultdrive_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER events_notify
+AFTER INSERT ON events
+FOR EACH ROW EXECUTE FUNCTION notify_event();
+
+-- +goose Down
+DROP TRIGGER events_notify ON events;
+DROP FUNCTION notify_event();
+DROP TABLE events;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_folders_owner_parent_name
+    ON folders(owner_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), name);
+
+ALTER TABLE files ADD COLUMN folder_id UUID REFERENCES folders(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_files_folder_id ON files(folder_id);
+
+-- +goose Down
+ALTER TABLE files DROP COLUMN folder_id;
+DROP TABLE folders;
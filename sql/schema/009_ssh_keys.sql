@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE ssh_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    public_key TEXT NOT NULL,  -- authorized_keys format
+    fingerprint TEXT UNIQUE NOT NULL,  -- SHA256 fingerprint used to look the key up during auth
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_ssh_keys_user_id ON ssh_keys(user_id);
+
+-- +goose Down
+DROP TABLE ssh_keys;
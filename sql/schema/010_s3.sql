@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE s3_access_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    access_key_id TEXT UNIQUE NOT NULL,
+    secret_key TEXT NOT NULL,  -- SigV4 needs the raw secret to recompute signatures
+    private_key_encrypted TEXT,  -- Set when the gateway encrypts objects: private key encrypted with the secret
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_s3_access_keys_user_id ON s3_access_keys(user_id);
+
+CREATE TABLE s3_multipart_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bucket TEXT NOT NULL,
+    object_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE s3_multipart_parts (
+    upload_id UUID NOT NULL REFERENCES s3_multipart_uploads(id) ON DELETE CASCADE,
+    part_number INTEGER NOT NULL,
+    file_path TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    etag TEXT NOT NULL,
+    encrypted_metadata TEXT,
+    wrapped_key TEXT,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (upload_id, part_number)
+);
+
+-- +goose Down
+DROP TABLE s3_multipart_parts;
+DROP TABLE s3_multipart_uploads;
+DROP TABLE s3_access_keys;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'auditor'));
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN quota_bytes BIGINT;  -- NULL falls back to USER_QUOTA_BYTES
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP;  -- Access tokens issued earlier are rejected
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN tokens_valid_after;
+ALTER TABLE users DROP COLUMN quota_bytes;
+ALTER TABLE users DROP COLUMN disabled_at;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    billing_email TEXT NOT NULL,
+    quota_bytes BIGINT,  -- NULL means unlimited
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE organization_members (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'guest')),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- Team space files belong to the organization and have no owner, so they
+-- outlive the account that uploaded them
+ALTER TABLE files ADD COLUMN org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE files ADD COLUMN uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_files_org_id ON files(org_id);
+
+-- +goose Down
+DROP INDEX idx_files_org_id;
+ALTER TABLE files DROP COLUMN uploaded_by;
+ALTER TABLE files DROP COLUMN org_id;
+DROP TABLE organization_members;
+DROP TABLE organizations;
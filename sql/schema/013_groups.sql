@@ -0,0 +1,43 @@
+-- +goose Up
+-- Groups have their own keypair. Files are shared once by wrapping the file key
+-- for the group public key, and each member holds the group private key wrapped
+-- for their own public key.
+CREATE TABLE groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    public_key TEXT NOT NULL,
+    key_algorithm TEXT NOT NULL CHECK (key_algorithm IN ('RSA-OAEP-256', 'X25519')),
+    key_version INTEGER NOT NULL DEFAULT 1,
+    -- Set when a member leaves on their own; new shares wait for an owner to rotate
+    rotation_required BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE group_members (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'member')),
+    wrapped_private_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_group_members_user_id ON group_members(user_id);
+
+CREATE TABLE group_file_keys (
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    wrapped_key TEXT NOT NULL,
+    shared_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (group_id, file_id)
+);
+
+CREATE INDEX idx_group_file_keys_file_id ON group_file_keys(file_id);
+
+-- +goose Down
+DROP TABLE group_file_keys;
+DROP TABLE group_members;
+DROP TABLE groups;
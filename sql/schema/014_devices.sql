@@ -0,0 +1,26 @@
+-- +goose Up
+-- Each device has its own keypair. The account private key (the master key) is
+-- wrapped for every approved device; new devices are approved from an existing
+-- one with a short-lived pairing code.
+CREATE TABLE devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    key_algorithm TEXT NOT NULL,
+    wrapped_master_key TEXT,
+    approved_at TIMESTAMP,
+    pairing_code_hash TEXT,
+    pairing_expires_at TIMESTAMP,
+    pairing_attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_devices_user_id ON devices(user_id);
+
+ALTER TABLE refresh_tokens ADD COLUMN device_id UUID REFERENCES devices(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN device_id;
+DROP TABLE devices;
@@ -0,0 +1,50 @@
+-- +goose Up
+-- Opt-in account recovery. The private key is also stored encrypted under an
+-- offline recovery key; proof_hash lets the server check a client knows that key
+-- without ever receiving it.
+CREATE TABLE recovery_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    recovery_key_encrypted TEXT NOT NULL,
+    proof_hash TEXT NOT NULL,
+    threshold INTEGER,  -- NULL when the recovery key is not split among trustees
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Shamir shares of the recovery key, each encrypted for its trustee's public key
+CREATE TABLE recovery_shares (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    trustee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, trustee_id)
+);
+
+CREATE INDEX idx_recovery_shares_trustee_id ON recovery_shares(trustee_id);
+
+-- A recovery in progress. Trustees approve by re-encrypting their share for the
+-- requester's one-time public key.
+CREATE TABLE recovery_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    public_key TEXT NOT NULL,
+    secret_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP
+);
+
+CREATE TABLE recovery_approvals (
+    request_id UUID NOT NULL REFERENCES recovery_requests(id) ON DELETE CASCADE,
+    trustee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (request_id, trustee_id)
+);
+
+-- +goose Down
+DROP TABLE recovery_approvals;
+DROP TABLE recovery_requests;
+DROP INDEX idx_recovery_shares_trustee_id;
+DROP TABLE recovery_shares;
+DROP TABLE recovery_keys;
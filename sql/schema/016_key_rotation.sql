@@ -0,0 +1,25 @@
+-- +goose Up
+-- Users can rotate their keypair. Previous keypairs are kept as numbered
+-- versions until every access key wrapped for them has been rewrapped, and the
+-- version each access key was wrapped for is recorded alongside it.
+ALTER TABLE users ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE file_access_keys ADD COLUMN key_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_file_access_keys_user_version ON file_access_keys(user_id, key_version);
+
+CREATE TABLE user_keys (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    public_key TEXT NOT NULL,
+    private_key_encrypted TEXT,
+    created_at TIMESTAMP NOT NULL,
+    retired_at TIMESTAMP,
+    PRIMARY KEY (user_id, version)
+);
+
+-- +goose Down
+DROP TABLE user_keys;
+DROP INDEX idx_file_access_keys_user_version;
+ALTER TABLE file_access_keys DROP COLUMN key_version;
+ALTER TABLE users DROP COLUMN key_version;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Users choose RSA-OAEP or X25519 for key wrapping and can hold an Ed25519
+-- signing keypair. Every existing key is RSA.
+ALTER TABLE users ADD COLUMN key_algorithm TEXT NOT NULL DEFAULT 'RSA-OAEP-256'
+    CHECK (key_algorithm IN ('RSA-OAEP-256', 'X25519'));
+ALTER TABLE users ADD COLUMN signing_public_key TEXT;
+ALTER TABLE users ADD COLUMN signing_private_key_encrypted TEXT;
+
+ALTER TABLE user_keys ADD COLUMN key_algorithm TEXT NOT NULL DEFAULT 'RSA-OAEP-256';
+
+-- +goose Down
+ALTER TABLE user_keys DROP COLUMN key_algorithm;
+ALTER TABLE users DROP COLUMN signing_private_key_encrypted;
+ALTER TABLE users DROP COLUMN signing_public_key;
+ALTER TABLE users DROP COLUMN key_algorithm;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Uploaders sign the ciphertext hash, file ID, version and metadata with their
+-- Ed25519 key. The signing key is kept as it was at upload time so signatures
+-- still verify after the uploader registers a new one.
+CREATE TABLE file_signatures (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    signer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    signing_public_key TEXT NOT NULL,
+    ciphertext_sha256 TEXT NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE file_signatures;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Append-only log of every (user, public key, version) binding. Entries are the
+-- leaves of a Merkle tree in idx order, so they are never updated or deleted
+-- and have no foreign keys. Existing keys are logged in registration order.
+CREATE TABLE key_log_entries (
+    idx BIGINT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    key_version INTEGER NOT NULL,
+    key_algorithm TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, key_version)
+);
+
+INSERT INTO key_log_entries (idx, user_id, key_version, key_algorithm, public_key, created_at)
+SELECT ROW_NUMBER() OVER (ORDER BY u.created_at, k.user_id, k.key_version) - 1,
+    k.user_id, k.key_version, k.key_algorithm, k.public_key, NOW()
+FROM (
+    SELECT user_id, version AS key_version, key_algorithm, public_key FROM user_keys
+    UNION
+    SELECT id, key_version, key_algorithm, public_key FROM users
+) k
+JOIN users u ON u.id = k.user_id;
+
+-- +goose Down
+DROP TABLE key_log_entries;
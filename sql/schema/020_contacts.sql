@@ -0,0 +1,18 @@
+-- +goose Up
+-- Contacts pin the fingerprint of another user's public key. verified_at is set
+-- once the fingerprint was compared out of band; a contact whose key no longer
+-- matches the pin cannot be shared with until it is verified again.
+CREATE TABLE contacts (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pinned_fingerprint TEXT NOT NULL,
+    pinned_key_version INTEGER NOT NULL,
+    verified_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, contact_id),
+    CHECK (user_id <> contact_id)
+);
+
+-- +goose Down
+DROP TABLE contacts;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- SHA-256 of each stored blob, recorded at upload and re-checked by the
+-- scrubber. Files stored before this migration have no row until their first
+-- check, which records the hash it finds. status is ok, missing, truncated,
+-- corrupted or quarantined.
+CREATE TABLE file_integrity (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    sha256 TEXT,
+    status TEXT NOT NULL DEFAULT 'ok',
+    checked_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_file_integrity_checked_at ON file_integrity(checked_at);
+
+-- +goose Down
+DROP TABLE file_integrity;
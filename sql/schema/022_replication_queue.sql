@@ -0,0 +1,17 @@
+-- +goose Up
+-- Blob copies still to be written to or removed from the replica. Jobs are
+-- keyed by blob name, so a newer operation on a blob replaces one that has not
+-- run yet; enqueued_at is the age the replication lag is measured from.
+CREATE TABLE replication_queue (
+    blob_name TEXT PRIMARY KEY,
+    operation TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    enqueued_at TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_replication_queue_next_attempt_at ON replication_queue(next_attempt_at);
+
+-- +goose Down
+DROP TABLE replication_queue;
@@ -0,0 +1,47 @@
+-- +goose Up
+-- Content-addressed chunks. With the chunk store enabled an upload is split
+-- into fixed-size chunks, each stored once under uploads/chunks by its
+-- SHA-256, and the file row points at them through file_chunks. refcount is
+-- the number of file_chunks rows using a chunk and is kept by a trigger, so
+-- deleting a file by any path releases its chunks; the reconciler removes
+-- chunks whose count dropped to zero.
+CREATE TABLE chunks (
+    hash TEXT PRIMARY KEY,
+    size BIGINT NOT NULL,
+    refcount INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_chunks_unreferenced ON chunks(hash) WHERE refcount = 0;
+
+CREATE TABLE file_chunks (
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    chunk_hash TEXT NOT NULL REFERENCES chunks(hash),
+    PRIMARY KEY (file_id, seq)
+);
+
+CREATE INDEX idx_file_chunks_chunk_hash ON file_chunks(chunk_hash);
+
+-- +goose StatementBegin
+CREATE FUNCTION count_chunk_refs() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE chunks SET refcount = refcount + 1 WHERE hash = NEW.chunk_hash;
+        RETURN NEW;
+    END IF;
+    UPDATE chunks SET refcount = refcount - 1 WHERE hash = OLD.chunk_hash;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER file_chunks_refcount
+AFTER INSERT OR DELETE ON file_chunks
+FOR EACH ROW EXECUTE FUNCTION count_chunk_refs();
+
+-- +goose Down
+DROP TRIGGER file_chunks_refcount ON file_chunks;
+DROP FUNCTION count_chunk_refs();
+DROP TABLE file_chunks;
+DROP TABLE chunks;
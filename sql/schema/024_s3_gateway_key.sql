@@ -0,0 +1,7 @@
+-- +goose Up
+-- Gateway-encryption access keys used to hold the private key encrypted with
+-- the secret stored beside it. They are now wrapped with the gateway key as
+-- well; the old copies are removed and the access keys have to be reissued.
+DELETE FROM s3_access_keys WHERE private_key_encrypted IS NOT NULL;
+
+-- +goose Down
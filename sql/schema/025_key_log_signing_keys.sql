@@ -0,0 +1,20 @@
+-- +goose Up
+-- Ed25519 signing keys are logged next to encryption keys, so a substituted
+-- signing key shows up the same way. Signing keys have no version of their
+-- own; each one a user registers gets the next number. Existing signing keys
+-- are appended after the current entries so earlier tree heads stay valid.
+ALTER TABLE key_log_entries ADD COLUMN key_use TEXT NOT NULL DEFAULT 'encryption';
+ALTER TABLE key_log_entries DROP CONSTRAINT key_log_entries_user_id_key_version_key;
+ALTER TABLE key_log_entries ADD UNIQUE (user_id, key_use, key_version);
+
+INSERT INTO key_log_entries (idx, user_id, key_version, key_algorithm, public_key, created_at, key_use)
+SELECT (SELECT COALESCE(MAX(idx) + 1, 0) FROM key_log_entries) + ROW_NUMBER() OVER (ORDER BY created_at, id) - 1,
+    id, 1, 'Ed25519', signing_public_key, NOW(), 'signing'
+FROM users
+WHERE signing_public_key IS NOT NULL;
+
+-- +goose Down
+DELETE FROM key_log_entries WHERE key_use = 'signing';
+ALTER TABLE key_log_entries DROP CONSTRAINT key_log_entries_user_id_key_use_key_version_key;
+ALTER TABLE key_log_entries ADD UNIQUE (user_id, key_version);
+ALTER TABLE key_log_entries DROP COLUMN key_use;
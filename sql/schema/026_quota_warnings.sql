@@ -0,0 +1,10 @@
+-- +goose Up
+-- Users who were sent a quota warning. The row is removed once usage drops
+-- back under the warning line, so crossing it again warns again.
+CREATE TABLE quota_warnings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    sent_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE quota_warnings;
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/Pranay0205/VaultDrive/internal/database"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const uploadDir = "uploads"
+
+// Blobs of transactional uploads are written to stagingDir and renamed into
+// uploadDir only after the rows pointing at them are committed, so a crash
+// never leaves a served path without its rows. reconcileBlobs cleans up
+// whatever a crash leaves in between.
+var stagingDir = filepath.Join(uploadDir, ".staging")
+
+// writeBlob creates filePath with the contents of src, removing it on failure
+func writeBlob(ctx context.Context, filePath string, src io.Reader) (size int64, err error) {
+	_, span := tracer.Start(ctx, "blob.write")
+	defer func() {
+		span.SetAttributes(attribute.Int64("blob.size", size))
+		recordSpanError(span, err)
+		span.End()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, err
+	}
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	activeUploads.Inc()
+	size, err = io.Copy(dst, src)
+	activeUploads.Dec()
+	uploadedBytesTotal.Add(float64(size))
+	if err != nil {
+		dst.Close()
+		os.Remove(filePath)
+		return 0, err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(filePath)
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// stagedBlob is an uploaded blob waiting for its database rows. path is where
+// it will be served from and is what the rows should record; for a chunked
+// file it is the chunked path and the staged blob is only read from.
+type stagedBlob struct {
+	path       string
+	stagedPath string
+	size       int64
+	replicated bool
+}
+
+// stageBlob writes src to the staging area
+func stageBlob(ctx context.Context, src io.Reader) (*stagedBlob, error) {
+	name := uuid.New().String()
+	blob := &stagedBlob{
+		path:       filepath.Join(uploadDir, name),
+		stagedPath: filepath.Join(stagingDir, name),
+	}
+
+	size, err := writeBlob(ctx, blob.stagedPath, src)
+	if err != nil {
+		return nil, err
+	}
+	blob.size = size
+	return blob, nil
+}
+
+// commit moves the blob into place once its rows are committed
+func (b *stagedBlob) commit() error {
+	return os.Rename(b.stagedPath, b.path)
+}
+
+// discard removes the blob unless it was committed
+func (b *stagedBlob) discard() {
+	remove := os.Remove
+	if b.replicated {
+		remove = func(name string) error { return removeBlob(context.Background(), name) }
+	}
+	err := remove(b.stagedPath)
+	if err != nil && !os.IsNotExist(err) {
+		slog.Warn("Could not remove staged blob", "path", b.stagedPath, "error", err)
+	}
+}
+
+// removeBlob deletes a stored blob and queues the removal of its replica copy
+func removeBlob(ctx context.Context, blobPath string) error {
+	// A chunked file's chunks are released with its rows
+	if isChunked(blobPath) {
+		return nil
+	}
+
+	err := os.Remove(blobPath)
+	if err == nil && replication != nil {
+		if err := replication.enqueue(context.WithoutCancel(ctx), blobName(blobPath), replicaDelete); err != nil {
+			slog.WarnContext(ctx, "Could not queue replica removal", "path", blobPath, "error", err)
+		}
+	}
+	return err
+}
+
+// blobReader counts what is read from a stored blob as downloaded. It does not
+// expose WriteTo, so copies go through Read and are counted. Its span covers
+// the blob from open to close.
+type blobReader struct {
+	file  blobFile
+	span  trace.Span
+	bytes atomic.Int64
+}
+
+// blobFile is a stored blob: a file, or the chunks of a chunked file
+type blobFile interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// openBlob opens the blob of file to serve it, restoring it from the replica
+// first if the primary copy is unfit
+func (cfg *ApiConfig) openBlob(ctx context.Context, dbFile database.File) (*blobReader, error) {
+	if replication != nil && !isChunked(dbFile.FilePath) {
+		replication.failover(ctx, dbFile)
+	}
+
+	_, span := tracer.Start(ctx, "blob.read")
+	var file blobFile
+	var err error
+	if isChunked(dbFile.FilePath) {
+		file, err = openChunkedFile(ctx, cfg.dbQueries, dbFile.ID)
+	} else {
+		file, err = os.Open(dbFile.FilePath)
+	}
+	if err != nil {
+		recordSpanError(span, err)
+		span.End()
+		return nil, err
+	}
+	return &blobReader{file: file, span: span}, nil
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	n, err := b.file.Read(p)
+	b.count(n)
+	return n, err
+}
+
+func (b *blobReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := b.file.ReadAt(p, off)
+	b.count(n)
+	return n, err
+}
+
+func (b *blobReader) count(n int) {
+	b.bytes.Add(int64(n))
+	downloadedBytesTotal.Add(float64(n))
+}
+
+func (b *blobReader) Seek(offset int64, whence int) (int64, error) {
+	return b.file.Seek(offset, whence)
+}
+
+func (b *blobReader) Close() error {
+	b.span.SetAttributes(attribute.Int64("blob.size", b.bytes.Load()))
+	b.span.End()
+	return b.file.Close()
+}
+
+// readBlob reads the whole blob of file to serve it. With a replica, a blob
+// that does not match its recorded hash is restored from the replica.
+func (cfg *ApiConfig) readBlob(ctx context.Context, file database.File) ([]byte, error) {
+	if isChunked(file.FilePath) {
+		blob, err := cfg.openBlob(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		defer blob.Close()
+		return io.ReadAll(blob)
+	}
+
+	var expected sql.NullString
+	if replication != nil {
+		expected = replication.failover(ctx, file)
+	}
+
+	_, span := tracer.Start(ctx, "blob.read")
+	defer span.End()
+
+	data, err := os.ReadFile(file.FilePath)
+	if err == nil && expected.Valid {
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != expected.String {
+			replication.restoreFrom(ctx, file, expected, integrityCorrupted)
+			data, err = os.ReadFile(file.FilePath)
+		}
+	}
+	downloadedBytesTotal.Add(float64(len(data)))
+	span.SetAttributes(attribute.Int("blob.size", len(data)))
+	recordSpanError(span, err)
+	return data, err
+}
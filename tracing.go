@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is configured with the standard OpenTelemetry variables:
+// OTEL_TRACES_EXPORTER (otlp, console or none), OTEL_SERVICE_NAME, and the
+// OTEL_EXPORTER_OTLP_* settings read by the OTLP exporter. Only the
+// http/protobuf OTLP protocol is supported.
+
+// tracer starts the spans of this service. It follows the global provider, so
+// spans are dropped until setupTracing installs one.
+var tracer = otel.Tracer("github.com/Pranay0205/VaultDrive")
+
+// setupTracing installs the configured exporter, returning nil when tracing
+// is off
+func setupTracing() (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	switch exporterName := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); exporterName {
+	case "", "none":
+		return nil, nil
+	case "console", "stdout":
+		stdout, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		exporter = stdout
+	case "otlp":
+		protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+		if protocol == "" {
+			protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+		}
+		if protocol != "" && protocol != "http/protobuf" {
+			return nil, fmt.Errorf("unsupported OTLP protocol %q, use http/protobuf", protocol)
+		}
+		otlp, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		exporter = otlp
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", exporterName)
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = "vaultdrive"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("Could not export traces", "error", err)
+	}))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider, nil
+}
+
+// recordSpanError marks span as failed when err is set
+func recordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// nameRequestSpan names the server span of a request after the route the mux
+// matched. Paths name files on the gateways, so spans carry the route in
+// their place, as the request log does.
+func nameRequestSpan(span trace.Span, route string) {
+	if route == "" {
+		return
+	}
+	span.SetName(route)
+	span.SetAttributes(
+		attribute.String("http.route", route),
+		attribute.String("url.path", redacted),
+	)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestSpanContinuesRemoteTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{id}/download", func(w http.ResponseWriter, r *http.Request) {
+		setRequestRoute(r)
+	})
+
+	r := httptest.NewRequest("GET", "/files/notes.txt/download", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	middlewareRequestLog(mux).ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /files/{id}/download" {
+		t.Errorf("span name = %q", span.Name)
+	}
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span did not continue the remote trace: %v, parent %v", span.SpanContext.TraceID(), span.Parent.SpanID())
+	}
+	for _, attr := range span.Attributes {
+		if attr.Key == "url.path" && attr.Value != attribute.StringValue(redacted) {
+			t.Errorf("span records the path %q", attr.Value.Emit())
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Uploads are streamed straight into the staging area. Their metadata comes
+// first, either as multipart fields sent before the "file" part or as headers
+// of a raw PUT, so it and the quota are checked before any file bytes are
+// read. The size must be declared up front and the body is held to it, so the
+// quota check cannot be dodged. Request bodies are capped at cfg.maxUploadBytes.
+
+const (
+	defaultMaxUploadBytes = 10 << 30
+	maxUploadFieldBytes   = 1 << 20
+	maxUploadFields       = 32
+)
+
+var (
+	errFileBeforeFields = errors.New(`metadata fields must come before the "file" part`)
+	errMissingFilePart  = errors.New(`missing "file" part`)
+	errUploadSize       = errors.New("uploaded size does not match the declared size")
+	errSizeRequired     = errors.New(`the "size" field is required`)
+	errLengthRequired   = errors.New("Content-Length is required")
+)
+
+// uploadHeaders maps the headers of a raw PUT upload to multipart field names
+var uploadHeaders = map[string]string{
+	"X-File-ID":        "file_id",
+	"X-Encrypted-Name": "encrypted_name",
+	"X-File-IV":        "iv",
+	"X-File-Salt":      "salt",
+	"X-File-Algorithm": "algorithm",
+	"X-Wrapped-Key":    "wrapped_key",
+	"X-Key-Version":    "key_version",
+	"X-File-Signature": "signature",
+}
+
+// uploadForm is an upload whose metadata has been read and whose file bytes
+// have not
+type uploadForm struct {
+	fields map[string]string
+	body   io.Reader
+	// size is the declared size of the file
+	size int64
+}
+
+func (f *uploadForm) get(name string) string {
+	return f.fields[name]
+}
+
+// limitUploadBody refuses bodies declared larger than the maximum and caps the
+// rest, so reading past it fails with *http.MaxBytesError
+func (cfg *ApiConfig) limitUploadBody(w http.ResponseWriter, r *http.Request) bool {
+	if r.ContentLength > cfg.maxUploadBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads are limited to %d bytes", cfg.maxUploadBytes), nil)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.maxUploadBytes)
+	return true
+}
+
+// readMultipartUpload reads the fields of a multipart upload up to the start
+// of its "file" part. The declared size is the "size" field.
+func readMultipartUpload(r *http.Request) (*uploadForm, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &uploadForm{fields: map[string]string{}}
+	for range maxUploadFields {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errMissingFilePart
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == "file" {
+			if len(form.fields) == 0 {
+				return nil, errFileBeforeFields
+			}
+			form.body = part
+			form.size, err = declaredUploadSize(form.fields["size"])
+			if err != nil {
+				return nil, err
+			}
+			return form, nil
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(value) > maxUploadFieldBytes {
+			return nil, fmt.Errorf("field %q is too large", part.FormName())
+		}
+		form.fields[part.FormName()] = string(value)
+	}
+	return nil, fmt.Errorf("more than %d fields", maxUploadFields)
+}
+
+// readRawUpload takes the metadata of a raw PUT upload from its headers. The
+// declared size is the Content-Length.
+func readRawUpload(r *http.Request) (*uploadForm, error) {
+	if r.ContentLength < 0 {
+		return nil, errLengthRequired
+	}
+
+	form := &uploadForm{fields: map[string]string{}, body: r.Body, size: r.ContentLength}
+	for header, field := range uploadHeaders {
+		if value := r.Header.Get(header); value != "" {
+			form.fields[field] = value
+		}
+	}
+	return form, nil
+}
+
+func declaredUploadSize(raw string) (int64, error) {
+	if raw == "" {
+		return 0, errSizeRequired
+	}
+	size, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || size < 0 {
+		return 0, errors.New("size must be a non-negative number of bytes")
+	}
+	return size, nil
+}
+
+// sizedBody fails once more than size bytes are read, so a client cannot pass
+// the quota check with a small declared size
+type sizedBody struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (b *sizedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errUploadSize
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, errUploadSize
+	}
+	return n, err
+}
+
+// uploadBody returns the file bytes of form, held to the declared size
+func (f *uploadForm) uploadBody() io.Reader {
+	return &sizedBody{r: f.body, remaining: f.size}
+}
+
+// respondWithUploadFormError reports a failure while reading the metadata of
+// an upload
+func respondWithUploadFormError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errLengthRequired) {
+		respondWithError(w, http.StatusLengthRequired, "Content-Length is required", err)
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Could not read upload", err)
+}
+
+// respondWithUploadError reports a failure while storing the file bytes
+func respondWithUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads are limited to %d bytes", maxBytesErr.Limit), err)
+	case errors.Is(err, errUploadSize):
+		respondWithError(w, http.StatusBadRequest, "Uploaded size does not match the declared size", err)
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Could not save file", err)
+	}
+}
+
+// stageUpload streams the file of form into the staging area while hashing
+// it. The quota is checked against the declared size before reading, and the
+// body is held to that size. The caller must discard the returned blob unless
+// it commits it.
+func (cfg *ApiConfig) stageUpload(w http.ResponseWriter, r *http.Request, form *uploadForm, checkQuota func(size int64) error, quotaMessage string) (*stagedBlob, string, bool) {
+	quotaAllows := func(size int64) bool {
+		err := checkQuota(size)
+		if err == errQuotaExceeded {
+			respondWithError(w, http.StatusRequestEntityTooLarge, quotaMessage, err)
+			return false
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking storage quota", err)
+			return false
+		}
+		return true
+	}
+
+	if !quotaAllows(form.size) {
+		return nil, "", false
+	}
+
+	hasher := sha256.New()
+	blob, err := stageBlob(r.Context(), io.TeeReader(form.uploadBody(), hasher))
+	if err != nil {
+		respondWithUploadError(w, err)
+		return nil, "", false
+	}
+
+	if blob.size != form.size {
+		blob.discard()
+		respondWithUploadError(w, errUploadSize)
+		return nil, "", false
+	}
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if cfg.chunkSize > 0 {
+		blob.path = chunkedPath(sum)
+	}
+	return blob, sum, true
+}
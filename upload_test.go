@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newMultipartUpload(t *testing.T, fileFirst bool, size string) (*uploadForm, error) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	writeFile := func() {
+		part, err := mw.CreateFormFile("file", "notes.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("ciphertext"))
+	}
+	if fileFirst {
+		writeFile()
+	}
+	mw.WriteField("wrapped_key", "key")
+	if size != "" {
+		mw.WriteField("size", size)
+	}
+	if !fileFirst {
+		writeFile()
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/files/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return readMultipartUpload(r)
+}
+
+func TestReadMultipartUpload(t *testing.T) {
+	form, err := newMultipartUpload(t, false, "10")
+	if err != nil {
+		t.Fatalf("readMultipartUpload() error = %v", err)
+	}
+	if form.get("wrapped_key") != "key" || form.size != 10 {
+		t.Errorf("readMultipartUpload() = %+v", form)
+	}
+	data, err := io.ReadAll(form.uploadBody())
+	if err != nil || string(data) != "ciphertext" {
+		t.Errorf("uploadBody() = %q, %v", data, err)
+	}
+
+	if _, err := newMultipartUpload(t, true, ""); !errors.Is(err, errFileBeforeFields) {
+		t.Errorf("file before fields: error = %v, want %v", err, errFileBeforeFields)
+	}
+	if _, err := newMultipartUpload(t, false, ""); !errors.Is(err, errSizeRequired) {
+		t.Errorf("missing size: error = %v, want %v", err, errSizeRequired)
+	}
+}
+
+func TestUploadBodyEnforcesDeclaredSize(t *testing.T) {
+	form, err := newMultipartUpload(t, false, "4")
+	if err != nil {
+		t.Fatalf("readMultipartUpload() error = %v", err)
+	}
+	if _, err := io.ReadAll(form.uploadBody()); !errors.Is(err, errUploadSize) {
+		t.Errorf("reading past the declared size: error = %v, want %v", err, errUploadSize)
+	}
+
+	form = &uploadForm{body: strings.NewReader("abcd"), size: 4}
+	if data, err := io.ReadAll(form.uploadBody()); err != nil || string(data) != "abcd" {
+		t.Errorf("uploadBody() = %q, %v", data, err)
+	}
+}
@@ -32,6 +32,8 @@ import {
   decryptFile,
   arrayBufferToBase64,
   base64ToArrayBuffer,
+  encryptFileName,
+  decryptFileName,
 } from "../utils/crypto";
 
 interface FileData {
@@ -51,6 +53,10 @@ export default function Files() {
   const [error, setError] = useState("");
   const [selectedFile, setSelectedFile] = useState<File | null>(null);
 
+  // Names are encrypted with each file's key, so they are decrypted once the
+  // password has been entered
+  const [fileNames, setFileNames] = useState<Record<string, string>>({});
+
   // Password-based encryption states
   const [encryptionPassword, setEncryptionPassword] = useState("");
   const [showPasswordModal, setShowPasswordModal] = useState(false);
@@ -112,7 +118,7 @@ export default function Files() {
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, [navigate]);
 
-  const fetchFiles = async () => {
+  const fetchFiles = async (): Promise<FileData[]> => {
     setLoading(true);
     setError("");
 
@@ -128,20 +134,49 @@ export default function Files() {
       if (!response.ok) {
         if (response.status === 401) {
           navigate("/login");
-          return;
+          return [];
         }
         throw new Error("Failed to fetch files");
       }
 
       const data = await response.json();
       setFiles(data || []);
+      return data || [];
     } catch (err) {
       setError(err instanceof Error ? err.message : "Failed to load files");
+      return [];
     } finally {
       setLoading(false);
     }
   };
 
+  // Decrypt the names of the files encrypted with this password. Files that
+  // were uploaded before names were encrypted keep their stored name.
+  const revealNames = async (password: string, list: FileData[]) => {
+    const names: Record<string, string> = {};
+    for (const file of list) {
+      const metadata = parseMetadata(file.metadata);
+      if (!metadata?.encrypted_name || !metadata.salt || fileNames[file.id]) {
+        continue;
+      }
+      try {
+        const salt = new Uint8Array(base64ToArrayBuffer(metadata.salt));
+        const key = await deriveKeyFromPassword(password, salt, 100000);
+        names[file.id] = await decryptFileName(metadata.encrypted_name, key);
+      } catch {
+        // Encrypted with another password
+      }
+    }
+    setFileNames((prev) => ({ ...prev, ...names }));
+  };
+
+  const displayName = (file: FileData): string => {
+    if (fileNames[file.id]) return fileNames[file.id];
+    return parseMetadata(file.metadata)?.encrypted_name
+      ? "Encrypted name"
+      : file.filename;
+  };
+
   const handleFileSelect = (e: React.ChangeEvent<HTMLInputElement>) => {
     if (e.target.files && e.target.files[0]) {
       setSelectedFile(e.target.files[0]);
@@ -194,25 +229,35 @@ export default function Files() {
         encryptionKey
       );
 
-      // 4. Prepare FormData with encrypted file
+      // 4. Encrypt the name with the same key; the file part only carries a
+      // generic name so the server never sees the real one
+      const encryptedName = await encryptFileName(
+        selectedFile.name,
+        encryptionKey
+      );
+
+      // 5. Prepare FormData, metadata first: the server checks it before
+      // reading the file part
       const formData = new FormData();
       const encryptedBlob = new Blob([encryptedData], {
         type: "application/octet-stream",
       });
-      formData.append("file", encryptedBlob, selectedFile.name);
+      formData.append("size", String(encryptedBlob.size));
 
-      // 5. Add encryption metadata (salt and IV needed for decryption)
+      // 6. Add encryption metadata (salt and IV needed for decryption)
       formData.append("iv", arrayBufferToBase64(iv));
       formData.append("salt", arrayBufferToBase64(salt));
       formData.append("algorithm", "AES-256-GCM");
+      formData.append("encrypted_name", encryptedName);
 
-      // 6. Add wrapped key (for now, we store the key derivation info)
+      // 7. Add wrapped key (for now, we store the key derivation info)
       // In a full implementation, this would be the file key wrapped with user's public key
       const wrappedKey =
         arrayBufferToBase64(salt) + ":" + arrayBufferToBase64(iv);
       formData.append("wrapped_key", wrappedKey);
+      formData.append("file", encryptedBlob, "file");
 
-      // 7. Upload to server
+      // 8. Upload to server
       const token = localStorage.getItem("token");
       const response = await fetch(`${API_URL}/files/upload`, {
         method: "POST",
@@ -237,7 +282,8 @@ export default function Files() {
       ) as HTMLInputElement;
       if (fileInput) fileInput.value = "";
 
-      await fetchFiles();
+      const list = await fetchFiles();
+      await revealNames(password, list);
       return true;
     } catch (err) {
       setError(err instanceof Error ? err.message : "Failed to upload file");
@@ -345,18 +391,28 @@ export default function Files() {
       const decryptedData = await decryptFile(encryptedData, encryptionKey, iv);
       console.log("Decryption successful. Size:", decryptedData.byteLength);
 
-      // 7. Create blob and trigger download
+      // 7. Decrypt the name, which is only stored encrypted
+      let filename = pendingDownload.filename;
+      if (metadataObj.encrypted_name) {
+        filename = await decryptFileName(
+          metadataObj.encrypted_name,
+          encryptionKey
+        );
+      }
+
+      // 8. Create blob and trigger download
       const decryptedBlob = new Blob([decryptedData]);
       const url = window.URL.createObjectURL(decryptedBlob);
       const a = document.createElement("a");
       a.href = url;
-      a.download = pendingDownload.filename;
+      a.download = filename;
       document.body.appendChild(a);
       a.click();
       window.URL.revokeObjectURL(url);
       document.body.removeChild(a);
 
       setPendingDownload(null);
+      await revealNames(password, files);
       return true;
     } catch (err) {
       console.error("Download/Decryption error:", err);
@@ -683,7 +739,7 @@ export default function Files() {
                           <File className="w-5 h-5 text-muted-foreground shrink-0" />
                           <div className="flex-1 min-w-0">
                             <p className="font-medium truncate">
-                              {file.filename}
+                              {displayName(file)}
                             </p>
                             <div className="flex gap-3 text-sm text-muted-foreground">
                               <span>{formatFileSize(file.file_size)}</span>
@@ -724,7 +780,7 @@ export default function Files() {
                             size="sm"
                             variant="outline"
                             onClick={() =>
-                              handleShareClick(file.id, file.filename)
+                              handleShareClick(file.id, displayName(file))
                             }
                             className="gap-2 border-purple-500 text-purple-600 hover:bg-purple-50 dark:hover:bg-purple-950 dark:text-purple-400"
                           >
@@ -734,7 +790,10 @@ export default function Files() {
                             size="sm"
                             variant="outline"
                             onClick={() =>
-                              handleManageSharesClick(file.id, file.filename)
+                              handleManageSharesClick(
+                                file.id,
+                                displayName(file)
+                              )
                             }
                             className="gap-2 border-orange-500 text-orange-600 hover:bg-orange-50 dark:hover:bg-orange-950 dark:text-orange-400"
                           >
@@ -746,7 +805,7 @@ export default function Files() {
                             onClick={() =>
                               handleDownload(
                                 file.id,
-                                file.filename,
+                                displayName(file),
                                 file.metadata
                               )
                             }
@@ -758,7 +817,7 @@ export default function Files() {
                             size="sm"
                             variant="outline"
                             onClick={() =>
-                              handleDeleteClick(file.id, file.filename)
+                              handleDeleteClick(file.id, displayName(file))
                             }
                             className="gap-2 border-red-500 text-red-600 hover:bg-red-50 dark:hover:bg-red-950 dark:text-red-400"
                           >
@@ -21,6 +21,7 @@ import {
   deriveKeyFromPassword,
   decryptFile,
   base64ToArrayBuffer,
+  decryptFileName,
 } from "../utils/crypto";
 import { API_URL } from "../utils/api";
 
@@ -151,12 +152,21 @@ export default function SharedFiles() {
       // 6. Decrypt the file
       const decryptedData = await decryptFile(encryptedData, encryptionKey, iv);
 
-      // 7. Create blob and trigger download
+      // 7. Decrypt the name, which is only stored encrypted
+      let filename = pendingDownload.filename;
+      if (metadataObj.encrypted_name) {
+        filename = await decryptFileName(
+          metadataObj.encrypted_name,
+          encryptionKey
+        );
+      }
+
+      // 8. Create blob and trigger download
       const decryptedBlob = new Blob([decryptedData]);
       const url = window.URL.createObjectURL(decryptedBlob);
       const a = document.createElement("a");
       a.href = url;
-      a.download = pendingDownload.filename;
+      a.download = filename;
       document.body.appendChild(a);
       a.click();
       window.URL.revokeObjectURL(url);
@@ -268,6 +268,66 @@ export async function decryptMetadata(
   return JSON.parse(metadataString);
 }
 
+// Encrypted names are NUL-padded to one of these sizes, so the server only
+// learns a rough length
+const ENCRYPTED_NAME_BUCKETS = [64, 128, 256, 512];
+
+/**
+ * Encrypt a file name with the file key for the encrypted_name upload field:
+ * base64 of the IV followed by the AES-GCM ciphertext of the padded name
+ */
+export async function encryptFileName(
+  name: string,
+  key: CryptoKey
+): Promise<string> {
+  const encoded = new TextEncoder().encode(name);
+  const size = ENCRYPTED_NAME_BUCKETS.find(
+    (bucket) => encoded.length <= bucket
+  );
+  if (!size || encoded.length === 0) {
+    throw new Error("File names must be 1 to 512 bytes long");
+  }
+
+  const padded = new Uint8Array(size);
+  padded.set(encoded);
+
+  const iv = generateIV();
+  const encrypted = await window.crypto.subtle.encrypt(
+    {
+      name: "AES-GCM",
+      iv: iv as any,
+    },
+    key,
+    padded
+  );
+
+  const blob = new Uint8Array(iv.length + encrypted.byteLength);
+  blob.set(iv);
+  blob.set(new Uint8Array(encrypted), iv.length);
+  return arrayBufferToBase64(blob);
+}
+
+/**
+ * Decrypt an encrypted_name with the file key
+ */
+export async function decryptFileName(
+  encryptedName: string,
+  key: CryptoKey
+): Promise<string> {
+  const blob = new Uint8Array(base64ToArrayBuffer(encryptedName));
+
+  const decrypted = await window.crypto.subtle.decrypt(
+    {
+      name: "AES-GCM",
+      iv: blob.slice(0, 12) as any,
+    },
+    key,
+    blob.slice(12)
+  );
+
+  return new TextDecoder().decode(decrypted).replace(/\0+$/, "");
+}
+
 // Hash function for file integrity verification
 export async function hashFile(data: ArrayBuffer): Promise<string> {
   const hashBuffer = await window.crypto.subtle.digest("SHA-256", data);
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVWriterLimit(t *testing.T) {
+	writer := &vaultFileWriter{vfs: &vaultFS{cfg: &ApiConfig{maxUploadBytes: 8}}}
+
+	if _, err := writer.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("world")); !errors.Is(err, errWebDAVTooLarge) {
+		t.Errorf("Write past the limit error = %v, want errWebDAVTooLarge", err)
+	}
+	if _, err := writer.Write([]byte("!!!")); err != nil || writer.buf.Len() != 8 {
+		t.Errorf("Write up to the limit = %v, len %d", err, writer.buf.Len())
+	}
+}
+
+func TestWebDAVRejectsOversizedPut(t *testing.T) {
+	g := &webdavGateway{cfg: &ApiConfig{maxUploadBytes: 8}}
+
+	r := httptest.NewRequest(http.MethodPut, "/dav/big.txt", strings.NewReader("0123456789"))
+	w := httptest.NewRecorder()
+	if g.admitUpload(w, r, &gatewaySession{}) {
+		t.Fatal("admitUpload accepted a body over the limit")
+	}
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}